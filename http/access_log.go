@@ -0,0 +1,233 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// AccessLogFormat 访问日志格式
+type AccessLogFormat int
+
+const (
+	// AccessLogCommon Common Log Format
+	AccessLogCommon AccessLogFormat = iota
+	// AccessLogCombined Combined Log Format
+	AccessLogCombined
+	// AccessLogJSON 每行一个JSON对象
+	AccessLogJSON
+	// AccessLogTemplate 用户自定义text/template模板
+	AccessLogTemplate
+)
+
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLogEntry 单条访问日志
+type AccessLogEntry struct {
+	Time         time.Time     `json:"time"`
+	RemoteAddr   string        `json:"remote_addr"`
+	User         string        `json:"user,omitempty"`
+	Method       string        `json:"method"`
+	URI          string        `json:"uri"`
+	Proto        string        `json:"proto"`
+	Status       int           `json:"status"`
+	Bytes        int           `json:"bytes"`
+	Latency      time.Duration `json:"latency"`
+	RoutePattern string        `json:"route_pattern,omitempty"`
+	UserAgent    string        `json:"user_agent,omitempty"`
+	Referer      string        `json:"referer,omitempty"`
+	RequestID    string        `json:"request_id,omitempty"`
+}
+
+// AccessLog 访问日志中间件
+type AccessLog struct {
+	writer     io.Writer
+	format     AccessLogFormat
+	template   *template.Template
+	sampleRate float64
+	excludes   []string
+	skipper    func(req *http.Request) bool
+	writeLock  sync.Mutex
+}
+
+// AccessLogOption configures an AccessLog
+type AccessLogOption func(*AccessLog)
+
+// WithAccessLogWriter sets the log sink, see NewRotateWriter and NewAsyncWriter
+func WithAccessLogWriter(writer io.Writer) AccessLogOption {
+	return func(a *AccessLog) {
+		a.writer = writer
+	}
+}
+
+// WithAccessLogFormat sets one of the built-in formats
+func WithAccessLogFormat(format AccessLogFormat) AccessLogOption {
+	return func(a *AccessLog) {
+		a.format = format
+	}
+}
+
+// WithAccessLogTemplate sets a text/template rendered with an AccessLogEntry
+func WithAccessLogTemplate(text string) AccessLogOption {
+	return func(a *AccessLog) {
+		tpl, err := template.New("access_log").Parse(text)
+		if err != nil {
+			panicInfo(fmt.Sprintf("illegal access log template, err:%s", err.Error()))
+		}
+		a.format = AccessLogTemplate
+		a.template = tpl
+	}
+}
+
+// WithAccessLogSampling logs only rate (0~1] of successful requests, errors are always logged
+func WithAccessLogSampling(rate float64) AccessLogOption {
+	return func(a *AccessLog) {
+		a.sampleRate = rate
+	}
+}
+
+// WithAccessLogExclude skips requests whose path equals or is under one of the prefixes
+func WithAccessLogExclude(paths ...string) AccessLogOption {
+	return func(a *AccessLog) {
+		a.excludes = append(a.excludes, paths...)
+	}
+}
+
+// WithAccessLogSkipper skips requests for which skipper returns true
+func WithAccessLogSkipper(skipper func(req *http.Request) bool) AccessLogOption {
+	return func(a *AccessLog) {
+		a.skipper = skipper
+	}
+}
+
+// NewAccessLog creates a new AccessLog with optional configuration
+func NewAccessLog(opts ...AccessLogOption) *AccessLog {
+	a := &AccessLog{
+		writer:     os.Stdout,
+		format:     AccessLogCombined,
+		sampleRate: 1,
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (s *AccessLog) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	if s.skip(req) {
+		ctx.Next()
+		return
+	}
+
+	start := time.Now()
+	recorder := trackRoutePattern(ctx)
+	principal := trackPrincipal(ctx)
+	rw := res.(ResponseWriter)
+
+	ctx.Next()
+
+	status := rw.Status()
+	if status < http.StatusBadRequest && s.sampleRate < 1 && rand.Float64() >= s.sampleRate {
+		return
+	}
+
+	entry := &AccessLogEntry{
+		Time:         start,
+		RemoteAddr:   clientIP(ctx.Context(), req),
+		Method:       req.Method,
+		URI:          req.RequestURI,
+		Proto:        req.Proto,
+		Status:       status,
+		Bytes:        rw.Size(),
+		Latency:      time.Since(start),
+		RoutePattern: recorder.pattern,
+		UserAgent:    req.UserAgent(),
+		Referer:      req.Referer(),
+		RequestID:    GetRequestID(ctx.Context()),
+	}
+	if entry.URI == "" {
+		entry.URI = req.URL.RequestURI()
+	}
+	if principal.principal != nil {
+		entry.User = principal.principal.ID
+	} else if val, ok := GetPrincipal(ctx.Context()); ok {
+		entry.User = val.ID
+	}
+
+	s.write(entry)
+}
+
+func (s *AccessLog) skip(req *http.Request) bool {
+	if s.skipper != nil && s.skipper(req) {
+		return true
+	}
+
+	for _, val := range s.excludes {
+		if req.URL.Path == val || strings.HasPrefix(req.URL.Path, strings.TrimRight(val, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *AccessLog) write(entry *AccessLogEntry) {
+	var buf bytes.Buffer
+	switch s.format {
+	case AccessLogJSON:
+		_ = json.NewEncoder(&buf).Encode(entry)
+	case AccessLogTemplate:
+		if err := s.template.Execute(&buf, entry); err != nil {
+			slog.Error("render access log failed", "err", err)
+			return
+		}
+		if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+			buf.WriteByte('\n')
+		}
+	case AccessLogCommon:
+		writeCommonLog(&buf, entry)
+		buf.WriteByte('\n')
+	default:
+		writeCommonLog(&buf, entry)
+		fmt.Fprintf(&buf, " %q %q\n", orDash(entry.Referer), orDash(entry.UserAgent))
+	}
+
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	if _, err := s.writer.Write(buf.Bytes()); err != nil {
+		slog.Error("write access log failed", "err", err)
+	}
+}
+
+func writeCommonLog(buf *bytes.Buffer, entry *AccessLogEntry) {
+	size := "-"
+	if entry.Bytes > 0 {
+		size = fmt.Sprintf("%d", entry.Bytes)
+	}
+	fmt.Fprintf(buf, "%s - %s [%s] \"%s %s %s\" %d %s",
+		orDash(entry.RemoteAddr),
+		orDash(entry.User),
+		entry.Time.Format(clfTimeLayout),
+		entry.Method,
+		entry.URI,
+		entry.Proto,
+		entry.Status,
+		size)
+}
+
+func orDash(val string) string {
+	if val == "" {
+		return "-"
+	}
+	return val
+}
@@ -0,0 +1,176 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func runAccessLog(t *testing.T, accessLog *AccessLog, target string) {
+	t.Helper()
+
+	registry := NewRouteRegistry()
+	registry.AddHandler("/users/:id", GET, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		_, _ = res.Write([]byte("hello"))
+	})
+
+	chains := NewMiddleWareChains()
+	chains.Append(NewRequestID(WithRequestIDGenerator(func() string { return "rid-1" })))
+	chains.Append(accessLog)
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set("Referer", "http://example.com/")
+	NewRequestContext(chains.GetHandlers(), registry, context.Background(), httptest.NewRecorder(), req).Run()
+}
+
+func TestAccessLogCombinedFormat(t *testing.T) {
+	var buf bytes.Buffer
+	runAccessLog(t, NewAccessLog(WithAccessLogWriter(&buf)), "/users/12")
+
+	line := buf.String()
+	if !strings.Contains(line, `"GET /users/12 HTTP/1.1" 200 5 "http://example.com/" "test-agent"`) {
+		t.Fatalf("unexpected combined log line: %q", line)
+	}
+}
+
+func TestAccessLogJSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	runAccessLog(t, NewAccessLog(WithAccessLogWriter(&buf), WithAccessLogFormat(AccessLogJSON)), "/users/12")
+
+	entry := AccessLogEntry{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("decode json log failed: %v", err)
+	}
+	if entry.RoutePattern != "/users/:id" || entry.RequestID != "rid-1" || entry.Status != http.StatusOK || entry.Bytes != 5 {
+		t.Fatalf("unexpected json entry: %+v", entry)
+	}
+}
+
+func TestAccessLogTemplateAndExclude(t *testing.T) {
+	var buf bytes.Buffer
+	accessLog := NewAccessLog(
+		WithAccessLogWriter(&buf),
+		WithAccessLogTemplate("{{.Method}} {{.RoutePattern}} {{.Status}}"),
+		WithAccessLogExclude("/healthz"))
+
+	runAccessLog(t, accessLog, "/users/12")
+	runAccessLog(t, accessLog, "/healthz")
+	if buf.String() != "GET /users/:id 200\n" {
+		t.Fatalf("unexpected template output: %q", buf.String())
+	}
+}
+
+func TestAccessLogSamplingKeepsErrors(t *testing.T) {
+	var buf bytes.Buffer
+	accessLog := NewAccessLog(WithAccessLogWriter(&buf), WithAccessLogSampling(0))
+
+	runAccessLog(t, accessLog, "/users/12")
+	runAccessLog(t, accessLog, "/missing")
+	if strings.Count(buf.String(), "\n") != 1 || !strings.Contains(buf.String(), " 404 ") {
+		t.Fatalf("sampling should drop successes and keep errors: %q", buf.String())
+	}
+}
+
+func TestAccessLogRecordsPrincipal(t *testing.T) {
+	var buf bytes.Buffer
+	registry := NewRouteRegistry()
+	api := NewRouteGroup(registry, "/api")
+	api.AddHandler("/me", GET, okHandler, func(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+		ctx.Update(WithPrincipal(ctx.Context(), &Principal{ID: "alice", Scheme: "Bearer"}))
+		ctx.Next()
+	})
+
+	chains := NewMiddleWareChains()
+	chains.Append(NewAccessLog(WithAccessLogWriter(&buf), WithAccessLogFormat(AccessLogJSON)))
+	req := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+	NewRequestContext(chains.GetHandlers(), registry, context.Background(), httptest.NewRecorder(), req).Run()
+
+	entry := AccessLogEntry{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("decode json log failed: %v", err)
+	}
+	if entry.User != "alice" {
+		t.Fatalf("principal set by route middleware should be logged, got %q", entry.User)
+	}
+}
+
+func TestRotateWriterRotatesBySize(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "logs", "access.log")
+	writer, err := NewRotateWriter(filePath, WithRotateSize(10), WithRotateBackups(1))
+	if err != nil {
+		t.Fatalf("NewRotateWriter failed: %v", err)
+	}
+	defer func() { _ = writer.Close() }()
+
+	for idx := 0; idx < 3; idx++ {
+		if _, err := writer.Write([]byte("0123456789")); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+
+	backups, _ := filepath.Glob(filePath + ".*")
+	if len(backups) != 1 {
+		t.Fatalf("backups = %d, want 1", len(backups))
+	}
+	content, _ := os.ReadFile(filePath)
+	if string(content) != "0123456789" {
+		t.Fatalf("current file = %q", string(content))
+	}
+}
+
+func TestAsyncWriterFlushesOnClose(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "access.log")
+	fileWriter, err := NewRotateWriter(filePath, WithRotateInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("NewRotateWriter failed: %v", err)
+	}
+
+	writer := NewAsyncWriter(fileWriter, 16)
+	_, _ = writer.Write([]byte("line-1\n"))
+	_, _ = writer.Write([]byte("line-2\n"))
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if _, err := writer.Write([]byte("late\n")); err == nil {
+		t.Fatal("write after close should fail")
+	}
+
+	content, _ := os.ReadFile(filePath)
+	if string(content) != "line-1\nline-2\n" {
+		t.Fatalf("file content = %q", string(content))
+	}
+}
+
+type countingCloser struct {
+	bytes.Buffer
+	closed int
+}
+
+func (c *countingCloser) Close() error {
+	c.closed++
+	if c.closed > 1 {
+		return os.ErrClosed
+	}
+	return nil
+}
+
+func TestAsyncWriterCloseOnce(t *testing.T) {
+	closer := &countingCloser{}
+	writer := NewAsyncWriter(closer, 4)
+	for idx := 0; idx < 2; idx++ {
+		if err := writer.Close(); err != nil {
+			t.Fatalf("close %d failed: %v", idx, err)
+		}
+	}
+	if closer.closed != 1 {
+		t.Fatalf("underlying writer closed %d times, want 1", closer.closed)
+	}
+}
@@ -0,0 +1,134 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// ErrInvalidCredentials is returned when basic auth or API key credentials are rejected
+var ErrInvalidCredentials = errors.New("http: invalid credentials")
+
+// BasicAuth HTTP Basic认证中间件
+type BasicAuth struct {
+	store CredentialStore
+	realm string
+}
+
+// BasicAuthOption configures a BasicAuth
+type BasicAuthOption func(*BasicAuth)
+
+// WithBasicAuthRealm sets the realm reported in WWW-Authenticate
+func WithBasicAuthRealm(realm string) BasicAuthOption {
+	return func(b *BasicAuth) {
+		b.realm = realm
+	}
+}
+
+// NewBasicAuth creates a new BasicAuth checking credentials against store
+func NewBasicAuth(store CredentialStore, opts ...BasicAuthOption) *BasicAuth {
+	b := &BasicAuth{store: store, realm: "Restricted"}
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+func (s *BasicAuth) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	username, password, ok := req.BasicAuth()
+	if !ok || !s.store.VerifyPassword(username, password) {
+		if ok {
+			slog.WarnContext(ctx.Context(), "basic auth failed", "user", username, "ip", clientIP(ctx.Context(), req), "path", req.URL.Path)
+		}
+		res.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, s.realm))
+		RenderError(ctx.Context(), res, req, http.StatusUnauthorized, ErrInvalidCredentials)
+		return
+	}
+
+	ctx.Update(WithPrincipal(ctx.Context(), &Principal{ID: username, Scheme: "Basic"}))
+	ctx.Next()
+}
+
+// DefaultAPIKeyHeader 默认读取API key的请求头
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// APIKeyAuth API key认证中间件, 依次从请求头、查询参数、cookie中读取API key
+type APIKeyAuth struct {
+	store      CredentialStore
+	header     string
+	queryParam string
+	cookieName string
+}
+
+// APIKeyAuthOption configures an APIKeyAuth
+type APIKeyAuthOption func(*APIKeyAuth)
+
+// WithAPIKeyHeader sets the header carrying the key, empty disables it, default X-API-Key
+func WithAPIKeyHeader(header string) APIKeyAuthOption {
+	return func(a *APIKeyAuth) {
+		a.header = header
+	}
+}
+
+// WithAPIKeyQuery also accepts the key from the named query parameter
+func WithAPIKeyQuery(name string) APIKeyAuthOption {
+	return func(a *APIKeyAuth) {
+		a.queryParam = name
+	}
+}
+
+// WithAPIKeyCookie also accepts the key from the named cookie
+func WithAPIKeyCookie(name string) APIKeyAuthOption {
+	return func(a *APIKeyAuth) {
+		a.cookieName = name
+	}
+}
+
+// NewAPIKeyAuth creates a new APIKeyAuth checking keys against store
+func NewAPIKeyAuth(store CredentialStore, opts ...APIKeyAuthOption) *APIKeyAuth {
+	a := &APIKeyAuth{store: store, header: DefaultAPIKeyHeader}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (s *APIKeyAuth) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	key := s.extractKey(req)
+	if key == "" {
+		RenderError(ctx.Context(), res, req, http.StatusUnauthorized, ErrInvalidCredentials)
+		return
+	}
+
+	id, ok := s.store.LookupAPIKey(key)
+	if !ok {
+		slog.WarnContext(ctx.Context(), "api key auth failed", "ip", clientIP(ctx.Context(), req), "path", req.URL.Path)
+		RenderError(ctx.Context(), res, req, http.StatusUnauthorized, ErrInvalidCredentials)
+		return
+	}
+
+	ctx.Update(WithPrincipal(ctx.Context(), &Principal{ID: id, Scheme: "APIKey"}))
+	ctx.Next()
+}
+
+func (s *APIKeyAuth) extractKey(req *http.Request) string {
+	if s.header != "" {
+		if key := req.Header.Get(s.header); key != "" {
+			return key
+		}
+	}
+	if s.queryParam != "" {
+		if key := req.URL.Query().Get(s.queryParam); key != "" {
+			return key
+		}
+	}
+	if s.cookieName != "" {
+		if cookie, err := req.Cookie(s.cookieName); err == nil {
+			return cookie.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func principalHandler(principal **Principal) RouteHandleFunc {
+	return func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		*principal, _ = GetPrincipal(ctx)
+		res.WriteHeader(http.StatusOK)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	store := NewMemoryCredentialStore()
+	store.AddUser("admin", "s3cret")
+	auth := NewBasicAuth(store, WithBasicAuthRealm("admin"))
+
+	var logBuf bytes.Buffer
+	oldLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logBuf, nil)))
+	defer slog.SetDefault(oldLogger)
+
+	var principal *Principal
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.SetBasicAuth("admin", "s3cret")
+	if w := serveWithMiddleware(auth, principalHandler(&principal), req); w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if principal == nil || principal.ID != "admin" || principal.Scheme != "Basic" {
+		t.Fatalf("unexpected principal: %+v", principal)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.SetBasicAuth("admin", "wrong")
+	w := serveWithMiddleware(auth, principalHandler(&principal), req)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != `Basic realm="admin", charset="UTF-8"` {
+		t.Fatalf("status = %d, WWW-Authenticate = %q", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+	if !strings.Contains(logBuf.String(), "ip=192.0.2.1") {
+		t.Fatalf("failed attempt should be logged with client ip: %s", logBuf.String())
+	}
+}
+
+func TestAPIKeyAuthSources(t *testing.T) {
+	store := NewMemoryCredentialStore()
+	store.AddAPIKey("key-123", "svc-a")
+	auth := NewAPIKeyAuth(store, WithAPIKeyQuery("api_key"), WithAPIKeyCookie("api_key"))
+
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/", nil),
+		httptest.NewRequest(http.MethodGet, "/?api_key=key-123", nil),
+		httptest.NewRequest(http.MethodGet, "/", nil),
+	}
+	requests[0].Header.Set(DefaultAPIKeyHeader, "key-123")
+	requests[2].AddCookie(&http.Cookie{Name: "api_key", Value: "key-123"})
+	for idx, req := range requests {
+		var principal *Principal
+		if w := serveWithMiddleware(auth, principalHandler(&principal), req); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d", idx, w.Code)
+		}
+		if principal == nil || principal.ID != "svc-a" || principal.Scheme != "APIKey" {
+			t.Fatalf("request %d: unexpected principal %+v", idx, principal)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(DefaultAPIKeyHeader, "key-124")
+	var principal *Principal
+	if w := serveWithMiddleware(auth, principalHandler(&principal), req); w.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong key status = %d", w.Code)
+	}
+}
+
+func TestHtpasswdStore(t *testing.T) {
+	bcryptHash, bcryptErr := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if bcryptErr != nil {
+		t.Fatalf("generate bcrypt failed: %v", bcryptErr)
+	}
+	keyDigest := sha256.Sum256([]byte("key-123"))
+
+	filePath := filepath.Join(t.TempDir(), ".htpasswd")
+	content := strings.Join([]string{
+		"# comment",
+		"apr:$apr1$abcdefgh$h9FWgUz3n9YxylKLlR5SQ/",
+		"sha:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=",
+		"plain:secret",
+		"bcrypt:$2y$" + strings.TrimPrefix(string(bcryptHash), "$2a$"),
+		"unknown:$5$rounds=5000$unsupported",
+		"des:rqXexS6ZhobKA",
+		"apikey:billing:{SHA256}" + hex.EncodeToString(keyDigest[:]),
+	}, "\n")
+	if err := os.WriteFile(filePath, []byte(content), 0o600); err != nil {
+		t.Fatalf("write htpasswd failed: %v", err)
+	}
+
+	store, err := NewHtpasswdStore(filePath)
+	if err != nil {
+		t.Fatalf("NewHtpasswdStore failed: %v", err)
+	}
+	for _, user := range []string{"apr", "sha", "bcrypt"} {
+		if !store.VerifyPassword(user, "secret") {
+			t.Errorf("%s should verify", user)
+		}
+		if store.VerifyPassword(user, "Secret") {
+			t.Errorf("%s should reject wrong password", user)
+		}
+	}
+	if store.VerifyPassword("unknown", "secret") || store.VerifyPassword("nobody", "secret") {
+		t.Fatal("unregistered hash formats and unknown users must fail")
+	}
+	if store.VerifyPassword("des", "rqXexS6ZhobKA") {
+		t.Fatal("legacy crypt(3) hashes must not be compared as plaintext")
+	}
+	if store.VerifyPassword("plain", "secret") {
+		t.Fatal("plaintext entries must be rejected by default")
+	}
+	if cost, costErr := bcrypt.Cost([]byte(store.dummyHash)); costErr != nil || cost != bcrypt.MinCost {
+		t.Fatalf("unknown users should be checked against a bcrypt hash of the same cost, got %d %v", cost, costErr)
+	}
+	if id, ok := store.LookupAPIKey("key-123"); !ok || id != "billing" {
+		t.Fatalf("api key lookup = %q, %v", id, ok)
+	}
+	if _, ok := store.LookupAPIKey("secret"); ok {
+		t.Fatal("user passwords must not be accepted as api keys")
+	}
+
+	plainStore, plainErr := NewHtpasswdStore(filePath, WithHtpasswdPlaintext(true))
+	if plainErr != nil {
+		t.Fatalf("NewHtpasswdStore failed: %v", plainErr)
+	}
+	if !plainStore.VerifyPassword("plain", "secret") || plainStore.VerifyPassword("plain", "Secret") {
+		t.Fatal("plaintext entries should verify when enabled")
+	}
+
+	future := time.Now().Add(time.Minute)
+	if err := os.WriteFile(filePath, []byte("plain:changed\n"), 0o600); err != nil {
+		t.Fatalf("rewrite htpasswd failed: %v", err)
+	}
+	_ = os.Chtimes(filePath, future, future)
+	if plainStore.VerifyPassword("plain", "secret") || !plainStore.VerifyPassword("plain", "changed") {
+		t.Fatal("htpasswd changes should be reloaded")
+	}
+}
@@ -0,0 +1,304 @@
+package http
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultMaxBodySize        = 1024 * 1024 * 10
+	DefaultMaxDecompressRatio = 100
+)
+
+// minRatioCheckSize 解压后数据小于该值时不做压缩比检查, 避免小请求误判
+const minRatioCheckSize = 64 * 1024
+
+// DecoderFactory 创建请求体解码器
+type DecoderFactory func(r io.Reader) (io.ReadCloser, error)
+
+var (
+	decoderFactories = map[string]DecoderFactory{
+		EncodingGzip: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+		EncodingDeflate: func(r io.Reader) (io.ReadCloser, error) {
+			return zlib.NewReader(r)
+		},
+	}
+	decoderFactoriesLock sync.RWMutex
+)
+
+// RegisterDecoder 注册请求体Content-Encoding解码器, 同名解码器会被覆盖
+func RegisterDecoder(name string, factory DecoderFactory) {
+	decoderFactoriesLock.Lock()
+	defer decoderFactoriesLock.Unlock()
+
+	decoderFactories[strings.ToLower(name)] = factory
+}
+
+func getDecoderFactory(name string) DecoderFactory {
+	decoderFactoriesLock.RLock()
+	defer decoderFactoriesLock.RUnlock()
+
+	return decoderFactories[name]
+}
+
+// BodyLimit 请求体限制中间件, 负责解压请求体并限制请求体大小
+//
+// 全局和路由(分组)可以同时使用, 路由级配置会覆盖全局配置,
+// 超出限制时返回 413 Payload Too Large
+type BodyLimit struct {
+	maxSize    int64
+	maxRatio   float64
+	decompress bool
+}
+
+// BodyLimitOption configures a BodyLimit
+type BodyLimitOption func(*BodyLimit)
+
+// WithBodyMaxSize sets the maximum request body size, a non-positive value disables the limit
+func WithBodyMaxSize(size int64) BodyLimitOption {
+	return func(b *BodyLimit) {
+		b.maxSize = size
+	}
+}
+
+// WithBodyMaxRatio sets the maximum decompressed/compressed ratio, a non-positive value disables the guard
+func WithBodyMaxRatio(ratio float64) BodyLimitOption {
+	return func(b *BodyLimit) {
+		b.maxRatio = ratio
+	}
+}
+
+// WithBodyDecompression enables or disables transparent request body decompression
+func WithBodyDecompression(enabled bool) BodyLimitOption {
+	return func(b *BodyLimit) {
+		b.decompress = enabled
+	}
+}
+
+// NewBodyLimit creates a new BodyLimit with optional configuration
+func NewBodyLimit(opts ...BodyLimitOption) *BodyLimit {
+	b := &BodyLimit{
+		maxSize:    DefaultMaxBodySize,
+		maxRatio:   DefaultMaxDecompressRatio,
+		decompress: true,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+func (s *BodyLimit) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	if req.Body == nil || req.Body == http.NoBody {
+		ctx.Next()
+		return
+	}
+
+	// 外层已经接管请求体, 这里只更新限制
+	if reader, ok := req.Body.(*bodyLimitReader); ok {
+		reader.configure(s)
+		ctx.Next()
+		return
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Encoding")))
+	if encoding == "identity" {
+		encoding = ""
+	}
+	if encoding != "" && s.decompress && getDecoderFactory(encoding) == nil {
+		RenderError(ctx.Context(), res, req, http.StatusUnsupportedMediaType, ErrUnsupportedContentEncoding)
+		return
+	}
+
+	reader := &bodyLimitReader{
+		raw:           req.Body,
+		contentLength: req.ContentLength,
+	}
+	reader.configure(s)
+	if encoding != "" && s.decompress {
+		reader.encoding = encoding
+		req.Header.Del("Content-Encoding")
+		req.Header.Del("Content-Length")
+		req.ContentLength = -1
+	}
+	req.Body = reader
+
+	lw := &bodyLimitWriter{ResponseWriter: res, reader: reader, ctx: ctx, req: req}
+	if restore, ok := replaceResponseWriter(ctx, lw); ok {
+		defer restore()
+	}
+
+	ctx.Next()
+}
+
+// bodyLimitReader 参考 magicCommon 的 maxBytesReader, 同时限制原始数据和解压后的数据
+type bodyLimitReader struct {
+	raw           io.ReadCloser
+	contentLength int64
+	encoding      string
+	decoder       io.ReadCloser
+	maxSize       int64
+	maxRatio      float64
+	rawSize       int64
+	readSize      int64
+	exceeded      bool
+	err           error
+}
+
+func (l *bodyLimitReader) configure(limit *BodyLimit) {
+	l.maxSize = limit.maxSize
+	l.maxRatio = limit.maxRatio
+}
+
+// checkDeclaredSize 按当前限制检查请求声明的Content-Length
+func (l *bodyLimitReader) checkDeclaredSize() bool {
+	if l.exceeded {
+		return true
+	}
+	if l.maxSize > 0 && l.contentLength > l.maxSize {
+		_ = l.fail(ErrRequestBodyTooLarge)
+		return true
+	}
+	return false
+}
+
+func (l *bodyLimitReader) fail(err error) error {
+	l.exceeded = true
+	l.err = err
+	return err
+}
+
+func (l *bodyLimitReader) Read(buffer []byte) (n int, err error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	if len(buffer) == 0 {
+		return 0, nil
+	}
+	if l.checkDeclaredSize() {
+		return 0, l.err
+	}
+
+	if l.encoding != "" && l.decoder == nil {
+		decoderVal, decoderErr := getDecoderFactory(l.encoding)(rawCounter{l})
+		if decoderErr != nil {
+			l.err = decoderErr
+			return 0, decoderErr
+		}
+		l.decoder = decoderVal
+	}
+
+	var reader io.Reader = rawCounter{l}
+	if l.decoder != nil {
+		reader = l.decoder
+	}
+
+	// 多读一个字节用于判断是否超出限制
+	if l.maxSize > 0 && int64(len(buffer)) > l.maxSize-l.readSize+1 {
+		buffer = buffer[:l.maxSize-l.readSize+1]
+	}
+	n, err = reader.Read(buffer)
+	if l.err != nil {
+		return 0, l.err
+	}
+
+	l.readSize += int64(n)
+	if l.maxSize > 0 && l.readSize > l.maxSize {
+		n -= int(l.readSize - l.maxSize)
+		l.readSize = l.maxSize
+		return n, l.fail(ErrRequestBodyTooLarge)
+	}
+	if l.decoder != nil && l.maxRatio > 0 && l.readSize > minRatioCheckSize &&
+		float64(l.readSize) > float64(l.rawSize)*l.maxRatio {
+		return n, l.fail(ErrDecompressionBomb)
+	}
+
+	if err != nil {
+		l.err = err
+	}
+	return n, err
+}
+
+func (l *bodyLimitReader) Close() error {
+	if l.decoder != nil {
+		_ = l.decoder.Close()
+	}
+	return l.raw.Close()
+}
+
+// rawCounter 统计并限制从连接读取的原始字节数
+type rawCounter struct {
+	limit *bodyLimitReader
+}
+
+func (r rawCounter) Read(buffer []byte) (int, error) {
+	l := r.limit
+	if l.maxSize > 0 && int64(len(buffer)) > l.maxSize-l.rawSize+1 {
+		buffer = buffer[:l.maxSize-l.rawSize+1]
+	}
+
+	n, err := l.raw.Read(buffer)
+	l.rawSize += int64(n)
+	if l.maxSize > 0 && l.rawSize > l.maxSize {
+		return 0, l.fail(ErrRequestBodyTooLarge)
+	}
+	return n, err
+}
+
+// bodyLimitWriter 请求体超出限制后, 把处理器的响应替换为 413
+type bodyLimitWriter struct {
+	http.ResponseWriter
+	reader   *bodyLimitReader
+	ctx      RequestContext
+	req      *http.Request
+	written  bool
+	rejected bool
+}
+
+func (w *bodyLimitWriter) WriteHeader(code int) {
+	if code >= 100 && code < 200 {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.rejected || w.written {
+		return
+	}
+	w.written = true
+	if w.reader.checkDeclaredSize() {
+		w.rejected = true
+		w.Header().Set("Connection", "close")
+		RenderError(w.ctx.Context(), w.ResponseWriter, w.req, http.StatusRequestEntityTooLarge, w.reader.err)
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *bodyLimitWriter) Write(data []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.rejected {
+		return len(data), nil
+	}
+
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyLimitWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *bodyLimitWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, _ = writer.Write(data)
+	if err := writer.Close(); err != nil {
+		t.Fatalf("gzip close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func echoBodyHandler(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		res.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	_, _ = res.Write(body)
+}
+
+func TestBodyLimitDecompressesGzipBody(t *testing.T) {
+	payload := []byte(`{"name":"magicEngine"}`)
+	req := httptest.NewRequest(http.MethodPost, "/json", bytes.NewReader(gzipBytes(t, payload)))
+	req.Header.Set("Content-Encoding", "gzip")
+
+	w := serveWithMiddleware(NewBodyLimit(), echoBodyHandler, req)
+	if w.Code != http.StatusOK || w.Body.String() != string(payload) {
+		t.Fatalf("got %d %q, want decoded payload", w.Code, w.Body.String())
+	}
+}
+
+func TestBodyLimitRejectsLargeBody(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/json", strings.NewReader(strings.Repeat("x", 128)))
+	w := serveWithMiddleware(NewBodyLimit(WithBodyMaxSize(64)), echoBodyHandler, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", w.Code)
+	}
+
+	// 未声明长度的请求在读取时才能发现超限
+	req = httptest.NewRequest(http.MethodPost, "/json", io.NopCloser(strings.NewReader(strings.Repeat("x", 128))))
+	req.ContentLength = -1
+	w = serveWithMiddleware(NewBodyLimit(WithBodyMaxSize(64)), echoBodyHandler, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", w.Code)
+	}
+}
+
+func TestBodyLimitDecompressionBomb(t *testing.T) {
+	payload := bytes.Repeat([]byte{0}, 1024*1024)
+	req := httptest.NewRequest(http.MethodPost, "/json", bytes.NewReader(gzipBytes(t, payload)))
+	req.Header.Set("Content-Encoding", "gzip")
+
+	var readErr error
+	w := serveWithMiddleware(NewBodyLimit(WithBodyMaxRatio(10)), func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		_, readErr = io.ReadAll(req.Body)
+		res.WriteHeader(http.StatusBadRequest)
+	}, req)
+	if !errors.Is(readErr, ErrDecompressionBomb) {
+		t.Fatalf("read error = %v, want ErrDecompressionBomb", readErr)
+	}
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", w.Code)
+	}
+}
+
+func TestBodyLimitUnsupportedEncoding(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/json", strings.NewReader("data"))
+	req.Header.Set("Content-Encoding", "br")
+	w := serveWithMiddleware(NewBodyLimit(), echoBodyHandler, req)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("status = %d, want 415", w.Code)
+	}
+}
+
+func TestBodyLimitRouteGroupOverridesGlobal(t *testing.T) {
+	registry := NewRouteRegistry()
+	group := NewRouteGroup(registry, "/upload", NewBodyLimit(WithBodyMaxSize(1024)))
+	group.AddHandler("/file", POST, echoBodyHandler)
+	registry.AddHandler("/json", POST, echoBodyHandler)
+
+	chains := NewMiddleWareChains()
+	chains.Append(NewBodyLimit(WithBodyMaxSize(16)))
+
+	body := strings.Repeat("x", 128)
+	req := httptest.NewRequest(http.MethodPost, "/upload/file", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	NewRequestContext(chains.GetHandlers(), registry, context.Background(), w, req).Run()
+	if w.Code != http.StatusOK || w.Body.String() != body {
+		t.Fatalf("group route status = %d, want 200", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/json", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	NewRequestContext(chains.GetHandlers(), registry, context.Background(), w, req).Run()
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("global route status = %d, want 413", w.Code)
+	}
+}
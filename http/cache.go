@@ -0,0 +1,487 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/muidea/magicCommon/foundation/helper"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	DefaultCacheMaxBytes          = 64 << 20
+	DefaultCacheMaxEntrySize      = 1 << 20
+	DefaultCacheStatusHeader      = "X-Cache"
+	DefaultCacheRevalidateTimeout = 30 * time.Second
+)
+
+// 调试响应头X-Cache的取值
+const (
+	CacheHit    = "HIT"
+	CacheMiss   = "MISS"
+	CacheStale  = "STALE"
+	CacheBypass = "BYPASS"
+)
+
+type cacheTagsKey struct{}
+
+// cacheTagRecorder 收集handler为响应设置的缓存标签
+type cacheTagRecorder struct {
+	tags []string
+}
+
+// AddCacheTags 为当前响应添加缓存标签, 之后可以通过ResponseCache.PurgeByTag清除
+func AddCacheTags(ctx context.Context, tags ...string) {
+	if recorder, ok := helper.GetValueFromContext[*cacheTagRecorder](ctx, cacheTagsKey{}); ok {
+		recorder.tags = append(recorder.tags, tags...)
+	}
+}
+
+// ResponseCache GET/HEAD响应缓存中间件
+//
+// 只缓存200响应, 有效期取自Cache-Control的s-maxage或max-age, 不缓存no-store、private和带Set-Cookie的响应.
+// 同一个key的并发未命中合并为一次处理; 过期但在stale-while-revalidate窗口内时先返回旧响应,
+// 再在后台goroutine中刷新缓存, 同一条目同时只有一个刷新.
+type ResponseCache struct {
+	store             *cacheStore
+	group             singleflight.Group
+	maxEntrySize      int64
+	defaultTTL        time.Duration
+	revalidateTimeout time.Duration
+	statusHeader      string
+	skipper           func(req *http.Request) bool
+	now               func() time.Time
+}
+
+// ResponseCacheOption configures a ResponseCache
+type ResponseCacheOption func(*ResponseCache)
+
+// WithCacheMaxBytes sets the total size of cached responses, default DefaultCacheMaxBytes
+func WithCacheMaxBytes(size int64) ResponseCacheOption {
+	return func(c *ResponseCache) {
+		c.store.maxBytes = size
+	}
+}
+
+// WithCacheMaxEntrySize sets the largest response body cached, default DefaultCacheMaxEntrySize
+func WithCacheMaxEntrySize(size int64) ResponseCacheOption {
+	return func(c *ResponseCache) {
+		c.maxEntrySize = size
+	}
+}
+
+// WithCacheDefaultTTL caches responses without max-age/s-maxage for ttl, default 0 only caches explicit responses
+func WithCacheDefaultTTL(ttl time.Duration) ResponseCacheOption {
+	return func(c *ResponseCache) {
+		c.defaultTTL = ttl
+	}
+}
+
+// WithCacheRevalidateTimeout bounds a background stale-while-revalidate refresh, default DefaultCacheRevalidateTimeout
+func WithCacheRevalidateTimeout(timeout time.Duration) ResponseCacheOption {
+	return func(c *ResponseCache) {
+		c.revalidateTimeout = timeout
+	}
+}
+
+// WithCacheStatusHeader sets the debug header carrying HIT/MISS/STALE/BYPASS, empty disables it
+func WithCacheStatusHeader(name string) ResponseCacheOption {
+	return func(c *ResponseCache) {
+		c.statusHeader = name
+	}
+}
+
+// WithCacheSkipper bypasses the cache for requests for which skipper returns true
+func WithCacheSkipper(skipper func(req *http.Request) bool) ResponseCacheOption {
+	return func(c *ResponseCache) {
+		c.skipper = skipper
+	}
+}
+
+// NewResponseCache creates a new ResponseCache with optional configuration
+func NewResponseCache(opts ...ResponseCacheOption) *ResponseCache {
+	c := &ResponseCache{
+		store:             newCacheStore(DefaultCacheMaxBytes),
+		maxEntrySize:      DefaultCacheMaxEntrySize,
+		revalidateTimeout: DefaultCacheRevalidateTimeout,
+		statusHeader:      DefaultCacheStatusHeader,
+		now:               time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// PurgeByPattern 清除命中指定路由规则的缓存, 规则与GetRoutePattern的返回值一致
+func (s *ResponseCache) PurgeByPattern(pattern string) int {
+	return s.store.purge(func(entry *cacheEntry) bool {
+		return entry.pattern == pattern
+	})
+}
+
+// PurgeByTag 清除带有指定标签的缓存
+func (s *ResponseCache) PurgeByTag(tag string) int {
+	return s.store.purge(func(entry *cacheEntry) bool {
+		return entry.hasTag(tag)
+	})
+}
+
+// PurgeAll 清除全部缓存
+func (s *ResponseCache) PurgeAll() int {
+	return s.store.purge(func(*cacheEntry) bool {
+		return true
+	})
+}
+
+// Stats 当前缓存的条目数和字节数
+func (s *ResponseCache) Stats() (int, int64) {
+	return s.store.stats()
+}
+
+func (s *ResponseCache) setStatus(res http.ResponseWriter, status string) {
+	if s.statusHeader != "" {
+		res.Header().Set(s.statusHeader, status)
+	}
+}
+
+func (s *ResponseCache) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	if (req.Method != GET && req.Method != HEAD) || (s.skipper != nil && s.skipper(req)) {
+		ctx.Next()
+		return
+	}
+
+	reqDirectives := parseCacheControl(req.Header.Get("Cache-Control"))
+	if _, ok := reqDirectives["no-store"]; ok {
+		s.setStatus(res, CacheBypass)
+		ctx.Next()
+		return
+	}
+
+	base := cacheBaseKey(req)
+	key := base
+	if fields, ok := s.store.varyFields(base); ok {
+		key = cacheVaryKey(base, fields, req)
+		if _, noCache := reqDirectives["no-cache"]; !noCache {
+			if s.lookup(ctx, res, req, key) {
+				return
+			}
+		}
+	}
+
+	// HEAD请求没有响应体, 不用于填充缓存
+	if req.Method == HEAD {
+		s.setStatus(res, CacheMiss)
+		ctx.Next()
+		return
+	}
+
+	leader := false
+	val, _, _ := s.group.Do(key, func() (any, error) {
+		leader = true
+		return s.fetch(ctx, res, req, base), nil
+	})
+	if leader {
+		return
+	}
+
+	// 复用并发请求的结果, 结果不可缓存或Vary不一致时自行处理
+	if entry, ok := val.(*cacheEntry); ok && entry != nil && cacheVaryKey(base, entry.varyFields, req) == entry.key {
+		s.serve(res, req, entry, CacheHit, 0)
+		return
+	}
+	s.fetch(ctx, res, req, base)
+}
+
+// lookup 查找缓存, 命中时输出响应并返回true
+func (s *ResponseCache) lookup(ctx RequestContext, res http.ResponseWriter, req *http.Request, key string) bool {
+	entry := s.store.get(key)
+	if entry == nil {
+		return false
+	}
+
+	age := s.now().Sub(entry.storedAt)
+	if age <= entry.ttl {
+		s.serve(res, req, entry, CacheHit, age)
+		return true
+	}
+	if age > entry.ttl+entry.swr {
+		return false
+	}
+
+	s.serve(res, req, entry, CacheStale, age)
+	if req.Method == GET && entry.revalidating.CompareAndSwap(false, true) {
+		s.revalidate(ctx, req, entry)
+	}
+	return true
+}
+
+// revalidate 旧响应已输出, 在后台用独立的上下文和请求执行后续处理刷新缓存, 新响应不发送给客户端;
+// 无法复制处理链的自定义 RequestContext 只能在当前请求中同步刷新, 也不支持替换ResponseWriter时不刷新
+func (s *ResponseCache) revalidate(ctx RequestContext, req *http.Request, stale *cacheEntry) {
+	cw := &cacheWriter{header: http.Header{}, maxSize: s.maxEntrySize}
+	detachable, ok := ctx.(detachableContext)
+	if !ok {
+		defer stale.revalidating.Store(false)
+		s.capture(ctx, req, stale.base, cw)
+		return
+	}
+
+	bgCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx.Context()), s.revalidateTimeout)
+	bgReq := req.Clone(bgCtx)
+	bgReq.Header.Del("If-None-Match")
+	bgReq.Header.Del("If-Modified-Since")
+	bgRequestCtx := detachable.detach(bgCtx, cw, bgReq)
+	go func() {
+		defer func() {
+			cancel()
+			stale.revalidating.Store(false)
+			if info := recover(); info != nil {
+				slog.ErrorContext(bgCtx, "cache revalidate panic", "key", stale.key, "panic", info)
+			}
+		}()
+		s.capture(bgRequestCtx, bgReq, stale.base, cw)
+	}()
+}
+
+// fetch 执行后续处理, 响应同时输出给客户端和写入缓存
+func (s *ResponseCache) fetch(ctx RequestContext, res http.ResponseWriter, req *http.Request, base string) *cacheEntry {
+	if _, ok := ctx.(ResponseWriterUpdater); !ok {
+		ctx.Next()
+		return nil
+	}
+
+	s.setStatus(res, CacheMiss)
+	cw := &cacheWriter{ResponseWriter: res, preset: res.Header().Clone(), maxSize: s.maxEntrySize}
+	return s.capture(ctx, req, base, cw)
+}
+
+// capture 执行后续处理并写入缓存, ctx 不支持替换ResponseWriter时不执行处理链, 返回nil
+func (s *ResponseCache) capture(ctx RequestContext, req *http.Request, base string, cw *cacheWriter) *cacheEntry {
+	recorder := trackRoutePattern(ctx)
+	tags := &cacheTagRecorder{}
+	ctx.Update(context.WithValue(ctx.Context(), cacheTagsKey{}, tags))
+
+	restore, ok := replaceResponseWriter(ctx, cw)
+	if !ok {
+		return nil
+	}
+	ctx.Next()
+	restore()
+
+	entry := s.newEntry(req, base, cw, recorder.pattern, tags.tags)
+	if entry != nil {
+		s.store.set(entry)
+	}
+	return entry
+}
+
+// newEntry 根据响应和Cache-Control生成缓存条目, 不可缓存时返回nil
+func (s *ResponseCache) newEntry(req *http.Request, base string, cw *cacheWriter, pattern string, tags []string) *cacheEntry {
+	if cw.status != http.StatusOK || cw.overflow {
+		return nil
+	}
+
+	header := cw.snapshot
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	for _, val := range []string{"no-store", "private", "no-cache"} {
+		if _, ok := directives[val]; ok {
+			return nil
+		}
+	}
+	if header.Get("Set-Cookie") != "" {
+		return nil
+	}
+
+	_, public := directives["public"]
+	sMaxAge, hasSMaxAge := directives["s-maxage"]
+	if req.Header.Get("Authorization") != "" && !public && !hasSMaxAge {
+		return nil
+	}
+
+	ttl := s.defaultTTL
+	if hasSMaxAge {
+		ttl = parseDirectiveSeconds(sMaxAge)
+	} else if maxAge, ok := directives["max-age"]; ok {
+		ttl = parseDirectiveSeconds(maxAge)
+	}
+	if ttl <= 0 {
+		return nil
+	}
+
+	varyFields := []string{}
+	for _, val := range header.Values("Vary") {
+		for _, field := range strings.Split(val, ",") {
+			field = strings.TrimSpace(field)
+			if field == "*" {
+				return nil
+			}
+			if field != "" {
+				varyFields = append(varyFields, http.CanonicalHeaderKey(field))
+			}
+		}
+	}
+
+	header.Del("Age")
+
+	entry := &cacheEntry{
+		key:        cacheVaryKey(base, varyFields, req),
+		base:       base,
+		varyFields: varyFields,
+		status:     cw.status,
+		header:     header,
+		body:       cw.body.Bytes(),
+		storedAt:   s.now(),
+		ttl:        ttl,
+		swr:        parseDirectiveSeconds(directives["stale-while-revalidate"]),
+		pattern:    pattern,
+		tags:       tags,
+	}
+	entry.size = int64(len(entry.key) + len(entry.body))
+	for key, values := range header {
+		entry.size += int64(len(key))
+		for _, val := range values {
+			entry.size += int64(len(val))
+		}
+	}
+	return entry
+}
+
+// serve 输出缓存的响应, 命中If-None-Match/If-Modified-Since时返回304
+func (s *ResponseCache) serve(res http.ResponseWriter, req *http.Request, entry *cacheEntry, status string, age time.Duration) {
+	header := res.Header()
+	for key, values := range entry.header {
+		header[key] = slices.Clone(values)
+	}
+	header.Set("Age", strconv.Itoa(int(age/time.Second)))
+	s.setStatus(res, status)
+
+	lastModified, _ := http.ParseTime(entry.header.Get("Last-Modified"))
+	if notModified(req, entry.header.Get("ETag"), lastModified) {
+		writeNotModified(res, "", time.Time{})
+		return
+	}
+
+	res.WriteHeader(entry.status)
+	if req.Method != HEAD {
+		_, _ = res.Write(entry.body)
+	}
+}
+
+func cacheBaseKey(req *http.Request) string {
+	return req.Host + req.URL.RequestURI()
+}
+
+func cacheVaryKey(base string, fields []string, req *http.Request) string {
+	if len(fields) == 0 {
+		return base
+	}
+
+	var builder strings.Builder
+	builder.WriteString(base)
+	for _, field := range fields {
+		builder.WriteString("\n")
+		builder.WriteString(field)
+		builder.WriteString(":")
+		builder.WriteString(strings.Join(req.Header.Values(field), ","))
+	}
+	return builder.String()
+}
+
+// parseCacheControl 解析Cache-Control, 返回小写指令名到值的映射
+func parseCacheControl(val string) map[string]string {
+	directives := map[string]string{}
+	for _, item := range strings.Split(val, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(item), "=")
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			directives[name] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return directives
+}
+
+func parseDirectiveSeconds(val string) time.Duration {
+	seconds, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// cacheWriter 记录响应用于缓存; ResponseWriter为nil时只记录不输出, 用于后台刷新
+//
+// preset为执行后续处理前已有的响应头, 由外层中间件按请求设置(如X-Request-ID), 不写入缓存.
+type cacheWriter struct {
+	http.ResponseWriter
+	header   http.Header
+	preset   http.Header
+	snapshot http.Header
+	body     bytes.Buffer
+	status   int
+	maxSize  int64
+	overflow bool
+}
+
+func (w *cacheWriter) Header() http.Header {
+	if w.ResponseWriter == nil {
+		return w.header
+	}
+	return w.ResponseWriter.Header()
+}
+
+func (w *cacheWriter) WriteHeader(code int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = code
+	w.snapshot = http.Header{}
+	for key, values := range w.Header() {
+		if !slices.Equal(w.preset[key], values) {
+			w.snapshot[key] = slices.Clone(values)
+		}
+	}
+	if w.ResponseWriter != nil {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *cacheWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if !w.overflow {
+		if int64(w.body.Len()+len(data)) > w.maxSize {
+			w.overflow = true
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(data)
+		}
+	}
+
+	if w.ResponseWriter == nil {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *cacheWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *cacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
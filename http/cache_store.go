@@ -0,0 +1,133 @@
+package http
+
+import (
+	"container/list"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cacheEntry 一条缓存的响应
+type cacheEntry struct {
+	key        string
+	base       string
+	varyFields []string
+	status     int
+	header     http.Header
+	body       []byte
+	storedAt   time.Time
+	ttl        time.Duration
+	swr        time.Duration
+	pattern    string
+	tags       []string
+	size       int64
+
+	revalidating atomic.Bool
+}
+
+func (e *cacheEntry) hasTag(tag string) bool {
+	return slices.Contains(e.tags, tag)
+}
+
+// cacheStore 按字节数限制容量的LRU
+//
+// vary记录每个基础key最近一次响应的Vary字段, 用于计算请求对应的完整key.
+type cacheStore struct {
+	mu        sync.Mutex
+	maxBytes  int64
+	curBytes  int64
+	lru       *list.List
+	items     map[string]*list.Element
+	vary      map[string][]string
+	baseCount map[string]int
+}
+
+func newCacheStore(maxBytes int64) *cacheStore {
+	return &cacheStore{
+		maxBytes:  maxBytes,
+		lru:       list.New(),
+		items:     map[string]*list.Element{},
+		vary:      map[string][]string{},
+		baseCount: map[string]int{},
+	}
+}
+
+func (s *cacheStore) varyFields(base string) ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fields, ok := s.vary[base]
+	return fields, ok
+}
+
+func (s *cacheStore) get(key string) *cacheEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+	s.lru.MoveToFront(elem)
+	return elem.Value.(*cacheEntry)
+}
+
+func (s *cacheStore) set(entry *cacheEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry.size > s.maxBytes {
+		return
+	}
+	if elem, ok := s.items[entry.key]; ok {
+		s.removeLocked(elem)
+	}
+
+	s.items[entry.key] = s.lru.PushFront(entry)
+	s.vary[entry.base] = entry.varyFields
+	s.baseCount[entry.base]++
+	s.curBytes += entry.size
+
+	for s.curBytes > s.maxBytes {
+		s.removeLocked(s.lru.Back())
+	}
+}
+
+func (s *cacheStore) removeLocked(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	s.lru.Remove(elem)
+	delete(s.items, entry.key)
+	s.curBytes -= entry.size
+
+	s.baseCount[entry.base]--
+	if s.baseCount[entry.base] <= 0 {
+		delete(s.baseCount, entry.base)
+		delete(s.vary, entry.base)
+	}
+}
+
+// purge 删除满足条件的缓存, 返回删除的数量
+func (s *cacheStore) purge(match func(entry *cacheEntry) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for elem := s.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if match(elem.Value.(*cacheEntry)) {
+			s.removeLocked(elem)
+			count++
+		}
+		elem = next
+	}
+	return count
+}
+
+func (s *cacheStore) stats() (int, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.items), s.curBytes
+}
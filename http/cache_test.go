@@ -0,0 +1,212 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingHandler 返回带序号的可缓存响应
+func countingHandler(counter *atomic.Int32, cacheControl string) RouteHandleFunc {
+	return func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		val := counter.Add(1)
+		res.Header().Set("Cache-Control", cacheControl)
+		res.Header().Set("Vary", "Accept-Language")
+		_, _ = fmt.Fprintf(res, "v%d:%s", val, req.Header.Get("Accept-Language"))
+	}
+}
+
+func cacheGet(cache *ResponseCache, handler RouteHandleFunc, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/items", nil)
+	for idx := 0; idx+1 < len(headers); idx += 2 {
+		req.Header.Set(headers[idx], headers[idx+1])
+	}
+	return serveWithMiddleware(cache, handler, req)
+}
+
+func TestResponseCacheHitAndVary(t *testing.T) {
+	now := time.Now()
+	cache := NewResponseCache()
+	cache.now = func() time.Time { return now }
+
+	counter := &atomic.Int32{}
+	handler := countingHandler(counter, "public, max-age=60")
+
+	w := cacheGet(cache, handler, "Accept-Language", "en")
+	if w.Body.String() != "v1:en" || w.Header().Get("X-Cache") != CacheMiss {
+		t.Fatalf("unexpected first response %q %v", w.Body.String(), w.Header())
+	}
+
+	now = now.Add(10 * time.Second)
+	w = cacheGet(cache, handler, "Accept-Language", "en")
+	if w.Body.String() != "v1:en" || w.Header().Get("X-Cache") != CacheHit || w.Header().Get("Age") != "10" {
+		t.Fatalf("unexpected cached response %q %v", w.Body.String(), w.Header())
+	}
+
+	w = cacheGet(cache, handler, "Accept-Language", "zh")
+	if w.Body.String() != "v2:zh" || w.Header().Get("X-Cache") != CacheMiss {
+		t.Fatalf("vary should separate entries, got %q", w.Body.String())
+	}
+
+	w = cacheGet(cache, handler, "Accept-Language", "en", "Cache-Control", "no-store")
+	if w.Body.String() != "v3:en" || w.Header().Get("X-Cache") != CacheBypass {
+		t.Fatalf("no-store request should bypass cache, got %q", w.Body.String())
+	}
+
+	now = now.Add(time.Minute)
+	if w = cacheGet(cache, handler, "Accept-Language", "en"); w.Body.String() != "v4:en" {
+		t.Fatalf("expired entry should be refreshed, got %q", w.Body.String())
+	}
+}
+
+func TestResponseCacheNotStored(t *testing.T) {
+	cache := NewResponseCache()
+
+	for _, val := range []string{"no-store", "private, max-age=60", ""} {
+		counter := &atomic.Int32{}
+		handler := countingHandler(counter, val)
+		cacheGet(cache, handler)
+		if w := cacheGet(cache, handler); w.Body.String() != "v2:" {
+			t.Errorf("%q should not be cached, got %q", val, w.Body.String())
+		}
+	}
+
+	counter := &atomic.Int32{}
+	handler := countingHandler(counter, "max-age=60")
+	cacheGet(cache, handler, "Authorization", "Bearer x")
+	if w := cacheGet(cache, handler, "Authorization", "Bearer x"); w.Body.String() != "v2:" {
+		t.Errorf("authorized response without public should not be cached, got %q", w.Body.String())
+	}
+}
+
+func TestResponseCacheStaleWhileRevalidate(t *testing.T) {
+	now := time.Now()
+	cache := NewResponseCache()
+	cache.now = func() time.Time { return now }
+
+	counter := &atomic.Int32{}
+	release := make(chan struct{})
+	cacheable := countingHandler(counter, "max-age=10, stale-while-revalidate=30")
+	handler := func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		if counter.Load() > 0 {
+			<-release
+		}
+		cacheable(ctx, res, req)
+	}
+	cacheGet(cache, handler)
+
+	now = now.Add(20 * time.Second)
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		done <- cacheGet(cache, handler)
+	}()
+	select {
+	case w := <-done:
+		if w.Body.String() != "v1:" || w.Header().Get("X-Cache") != CacheStale {
+			t.Fatalf("stale entry should be served, got %q %v", w.Body.String(), w.Header())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stale response should not wait for revalidation")
+	}
+	if w := cacheGet(cache, handler); w.Body.String() != "v1:" {
+		t.Fatalf("only one revalidation should run, got %q", w.Body.String())
+	}
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		w := cacheGet(cache, handler)
+		if w.Body.String() == "v2:" && w.Header().Get("X-Cache") == CacheHit {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("revalidated entry should be served, got %q", w.Body.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if counter.Load() != 2 {
+		t.Fatalf("handler called %d times, want 2", counter.Load())
+	}
+}
+
+func TestResponseCachePurge(t *testing.T) {
+	cache := NewResponseCache()
+
+	counter := &atomic.Int32{}
+	handler := func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		AddCacheTags(ctx, "items")
+		countingHandler(counter, "max-age=60")(ctx, res, req)
+	}
+	cacheGet(cache, handler)
+	if count, size := cache.Stats(); count != 1 || size <= 0 {
+		t.Fatalf("unexpected stats %d %d", count, size)
+	}
+
+	if cache.PurgeByTag("users") != 0 || cache.PurgeByTag("items") != 1 {
+		t.Fatal("purge by tag failed")
+	}
+	cacheGet(cache, handler)
+	if cache.PurgeByPattern("/other") != 0 || cache.PurgeByPattern("/items") != 1 {
+		t.Fatal("purge by pattern failed")
+	}
+}
+
+func TestResponseCacheEvictAndConditional(t *testing.T) {
+	cache := NewResponseCache(WithCacheMaxBytes(400))
+
+	handler := func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Cache-Control", "max-age=60")
+		res.Header().Set("ETag", `"v1"`)
+		_, _ = res.Write(make([]byte, 150))
+	}
+	for _, path := range []string{"/a", "/b", "/c"} {
+		serveWithMiddleware(cache, handler, httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil))
+	}
+	if count, size := cache.Stats(); count != 2 || size > 400 {
+		t.Fatalf("lru should evict oldest entry, got %d %d", count, size)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/c", nil)
+	req.Header.Set("If-None-Match", `"v1"`)
+	if w := serveWithMiddleware(cache, handler, req); w.Code != http.StatusNotModified || w.Header().Get("X-Cache") != CacheHit {
+		t.Fatalf("cached etag should answer 304, got %d", w.Code)
+	}
+}
+
+func TestResponseCacheCoalesce(t *testing.T) {
+	cache := NewResponseCache()
+
+	counter := &atomic.Int32{}
+	release := make(chan struct{})
+	handler := func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		<-release
+		countingHandler(counter, "max-age=60")(ctx, res, req)
+	}
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 5)
+	for idx := range bodies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bodies[idx] = cacheGet(cache, handler).Body.String()
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if counter.Load() != 1 {
+		t.Fatalf("concurrent misses should be coalesced, handler called %d", counter.Load())
+	}
+	for _, val := range bodies {
+		if val != "v1:" {
+			t.Fatalf("unexpected body %q", val)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package http
+
+import (
+	"net/netip"
+)
+
+type cidrNode struct {
+	children [2]*cidrNode
+	terminal bool
+}
+
+// CIDRSet IPv4/IPv6网段集合, 基于二进制前缀树, 匹配耗时只与地址位数有关
+type CIDRSet struct {
+	v4   *cidrNode
+	v6   *cidrNode
+	size int
+}
+
+// NewCIDRSet 创建网段集合, 单个IP视为单主机网段
+func NewCIDRSet(cidrs ...string) (*CIDRSet, error) {
+	s := &CIDRSet{v4: &cidrNode{}, v6: &cidrNode{}}
+	for _, val := range cidrs {
+		if err := s.Add(val); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Add 添加网段, 非并发安全, 需在开始匹配前完成
+func (s *CIDRSet) Add(cidr string) error {
+	prefix, prefixErr := parsePrefix(cidr)
+	if prefixErr != nil {
+		return prefixErr
+	}
+
+	addr := prefix.Addr().Unmap()
+	bits := prefix.Bits()
+	node := s.v6
+	if addr.Is4() {
+		node = s.v4
+		if prefix.Addr().Is4In6() {
+			bits = max(bits-96, 0)
+		}
+	}
+
+	raw := addr.AsSlice()
+	for idx := 0; idx < bits; idx++ {
+		bit := raw[idx/8] >> (7 - idx%8) & 1
+		if node.children[bit] == nil {
+			node.children[bit] = &cidrNode{}
+		}
+		node = node.children[bit]
+	}
+	if !node.terminal {
+		node.terminal = true
+		s.size++
+	}
+	return nil
+}
+
+// Len 网段数量
+func (s *CIDRSet) Len() int {
+	return s.size
+}
+
+// Contains 判断地址是否属于集合中的任一网段
+func (s *CIDRSet) Contains(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+
+	addr = addr.Unmap()
+	node := s.v6
+	if addr.Is4() {
+		node = s.v4
+	}
+
+	raw := addr.AsSlice()
+	for idx := 0; ; idx++ {
+		if node.terminal {
+			return true
+		}
+		if idx == len(raw)*8 {
+			return false
+		}
+		node = node.children[raw[idx/8]>>(7-idx%8)&1]
+		if node == nil {
+			return false
+		}
+	}
+}
+
+// ContainsString 判断字符串形式的地址是否属于集合, 无法解析时返回false
+func (s *CIDRSet) ContainsString(val string) bool {
+	addr, ok := parseHopAddr(val)
+	return ok && s.Contains(addr)
+}
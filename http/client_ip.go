@@ -0,0 +1,279 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/muidea/magicCommon/foundation/helper"
+)
+
+// ClientInfoKey context中保存客户端信息的key
+type ClientInfoKey struct{}
+
+// ClientInfo 经可信代理解析后的客户端信息
+type ClientInfo struct {
+	// IP 客户端IP
+	IP string
+	// Scheme 客户端使用的协议, http或https
+	Scheme string
+	// Host 客户端请求的Host
+	Host string
+}
+
+// GetClientInfo 获取TrustedProxies中间件解析的客户端信息
+func GetClientInfo(ctx context.Context) (*ClientInfo, bool) {
+	info, ok := helper.GetValueFromContext[*ClientInfo](ctx, ClientInfoKey{})
+	return info, ok && info != nil
+}
+
+// GetClientIP 获取客户端IP, 未经TrustedProxies解析时返回空
+func GetClientIP(ctx context.Context) string {
+	if info, ok := GetClientInfo(ctx); ok {
+		return info.IP
+	}
+	return ""
+}
+
+// clientIP 优先使用解析后的客户端IP, 否则使用连接的对端地址
+func clientIP(ctx context.Context, req *http.Request) string {
+	if ip := GetClientIP(ctx); ip != "" {
+		return ip
+	}
+	return remoteIP(req)
+}
+
+// requestHost 优先使用经可信代理解析的Host
+func requestHost(ctx context.Context, req *http.Request) string {
+	if info, ok := GetClientInfo(ctx); ok && info.Host != "" {
+		return info.Host
+	}
+	return req.Host
+}
+
+// requestScheme 优先使用经可信代理解析的协议
+func requestScheme(ctx context.Context, req *http.Request) string {
+	if info, ok := GetClientInfo(ctx); ok && info.Scheme != "" {
+		return info.Scheme
+	}
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+func remoteIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// TrustedProxies 可信代理中间件, 从X-Forwarded-For或Forwarded头解析真实的客户端IP、协议和Host
+//
+// 只有对端地址属于可信网段时才会读取转发头, 并从右向左遍历, 在第一个不可信的跳停止.
+type TrustedProxies struct {
+	prefixes []netip.Prefix
+}
+
+// TrustedProxiesOption configures a TrustedProxies
+type TrustedProxiesOption func(*TrustedProxies)
+
+// WithTrustedProxyCIDRs adds trusted proxy networks, a bare IP is treated as a single host
+func WithTrustedProxyCIDRs(cidrs ...string) TrustedProxiesOption {
+	return func(t *TrustedProxies) {
+		for _, val := range cidrs {
+			prefix, err := parsePrefix(val)
+			if err != nil {
+				panicInfo(fmt.Sprintf("illegal trusted proxy cidr %s, err:%s", val, err.Error()))
+			}
+			t.prefixes = append(t.prefixes, prefix)
+		}
+	}
+}
+
+// NewTrustedProxies creates a new TrustedProxies, without trusted networks the peer address is always the client
+func NewTrustedProxies(opts ...TrustedProxiesOption) *TrustedProxies {
+	t := &TrustedProxies{}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+func parsePrefix(val string) (netip.Prefix, error) {
+	val = strings.TrimSpace(val)
+	if strings.Contains(val, "/") {
+		prefix, err := netip.ParsePrefix(val)
+		if err != nil {
+			return prefix, err
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(val)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func (s *TrustedProxies) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	ctx.Update(context.WithValue(ctx.Context(), ClientInfoKey{}, s.Resolve(req)))
+	ctx.Next()
+}
+
+func (s *TrustedProxies) trusted(val string) bool {
+	addr, ok := parseHopAddr(val)
+	if !ok {
+		return false
+	}
+
+	for _, prefix := range s.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve 解析请求的客户端信息
+func (s *TrustedProxies) Resolve(req *http.Request) *ClientInfo {
+	info := &ClientInfo{IP: remoteIP(req), Scheme: "http", Host: req.Host}
+	if req.TLS != nil {
+		info.Scheme = "https"
+	}
+	if !s.trusted(info.IP) {
+		return info
+	}
+
+	if forwarded := req.Header.Values("Forwarded"); len(forwarded) > 0 {
+		s.resolveForwarded(info, parseForwarded(forwarded))
+		return info
+	}
+
+	if xff := req.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := []string{}
+		for _, val := range xff {
+			for _, item := range strings.Split(val, ",") {
+				hops = append(hops, strings.TrimSpace(item))
+			}
+		}
+		info.IP = s.walkHops(info.IP, hops)
+	} else if realIP := strings.TrimSpace(req.Header.Get("X-Real-IP")); realIP != "" {
+		if addr, ok := parseHopAddr(realIP); ok {
+			info.IP = addr.String()
+		}
+	}
+
+	if proto := strings.ToLower(strings.TrimSpace(req.Header.Get("X-Forwarded-Proto"))); proto == "http" || proto == "https" {
+		info.Scheme = proto
+	}
+	if host := strings.TrimSpace(req.Header.Get("X-Forwarded-Host")); host != "" {
+		info.Host = host
+	}
+	return info
+}
+
+// walkHops 从右向左遍历转发链, 返回第一个不可信的跳; 无法解析的跳视为不可信, 返回上一个已知地址
+func (s *TrustedProxies) walkHops(peer string, hops []string) string {
+	client := peer
+	for idx := len(hops) - 1; idx >= 0; idx-- {
+		addr, ok := parseHopAddr(hops[idx])
+		if !ok {
+			return client
+		}
+
+		client = addr.String()
+		if !s.trusted(client) {
+			return client
+		}
+	}
+	return client
+}
+
+func (s *TrustedProxies) resolveForwarded(info *ClientInfo, elements []map[string]string) {
+	client := info.IP
+	var chosen map[string]string
+	for idx := len(elements) - 1; idx >= 0; idx-- {
+		addr, ok := parseHopAddr(elements[idx]["for"])
+		if !ok {
+			break
+		}
+
+		client = addr.String()
+		chosen = elements[idx]
+		if !s.trusted(client) {
+			break
+		}
+	}
+
+	info.IP = client
+	if chosen == nil {
+		return
+	}
+	if proto := strings.ToLower(chosen["proto"]); proto == "http" || proto == "https" {
+		info.Scheme = proto
+	}
+	if host := chosen["host"]; host != "" {
+		info.Host = host
+	}
+}
+
+// parseForwarded 解析RFC 7239 Forwarded头, 每个元素是一组小写参数名到值的映射
+func parseForwarded(values []string) []map[string]string {
+	elements := []map[string]string{}
+	for _, val := range values {
+		for _, element := range splitQuoted(val, ',') {
+			params := map[string]string{}
+			for _, pair := range splitQuoted(element, ';') {
+				name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				params[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+			}
+			elements = append(elements, params)
+		}
+	}
+	return elements
+}
+
+// splitQuoted 按sep切分, 忽略引号内的分隔符
+func splitQuoted(val string, sep byte) []string {
+	ret := []string{}
+	quoted := false
+	start := 0
+	for idx := 0; idx < len(val); idx++ {
+		switch val[idx] {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				ret = append(ret, val[start:idx])
+				start = idx + 1
+			}
+		}
+	}
+	return append(ret, val[start:])
+}
+
+// parseHopAddr 解析转发链中的地址, 兼容带端口和IPv6方括号的写法
+func parseHopAddr(val string) (netip.Addr, bool) {
+	val = strings.TrimSpace(val)
+	if addrPort, err := netip.ParseAddrPort(val); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(val, "["), "]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
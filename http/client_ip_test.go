@@ -0,0 +1,98 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedProxiesUntrustedPeer(t *testing.T) {
+	proxies := NewTrustedProxies(WithTrustedProxyCIDRs("10.0.0.0/8"))
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.RemoteAddr = "203.0.113.7:4000"
+	req.Header.Set("X-Forwarded-For", "1.1.1.1")
+	req.Header.Set("X-Real-IP", "1.1.1.1")
+	req.Header.Set("X-Forwarded-Host", "evil.com")
+
+	info := proxies.Resolve(req)
+	if info.IP != "203.0.113.7" || info.Host != "example.com" || info.Scheme != "http" {
+		t.Fatalf("unexpected client info %+v", info)
+	}
+}
+
+func TestTrustedProxiesXForwardedFor(t *testing.T) {
+	proxies := NewTrustedProxies(WithTrustedProxyCIDRs("10.0.0.0/8", "192.168.1.1"))
+
+	req := httptest.NewRequest(http.MethodGet, "http://internal/", nil)
+	req.RemoteAddr = "10.0.0.2:4000"
+	// 最左边的地址由客户端伪造, 应在第一个不可信的跳停止
+	req.Header.Set("X-Forwarded-For", "6.6.6.6, 198.51.100.9")
+	req.Header.Add("X-Forwarded-For", "192.168.1.1, 10.0.0.3")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "example.com")
+
+	info := proxies.Resolve(req)
+	if info.IP != "198.51.100.9" || info.Scheme != "https" || info.Host != "example.com" {
+		t.Fatalf("unexpected client info %+v", info)
+	}
+
+	req.Header.Set("X-Forwarded-For", "10.1.1.1, 10.0.0.3")
+	if info := proxies.Resolve(req); info.IP != "10.1.1.1" {
+		t.Fatalf("all trusted hops should resolve to leftmost, got %s", info.IP)
+	}
+
+	req.Header.Set("X-Forwarded-For", "garbage, 10.0.0.3")
+	if info := proxies.Resolve(req); info.IP != "10.0.0.3" {
+		t.Fatalf("unparsable hop should stop at previous hop, got %s", info.IP)
+	}
+}
+
+func TestTrustedProxiesForwarded(t *testing.T) {
+	proxies := NewTrustedProxies(WithTrustedProxyCIDRs("10.0.0.0/8", "2001:db8::/32"))
+
+	req := httptest.NewRequest(http.MethodGet, "http://internal/", nil)
+	req.RemoteAddr = "[2001:db8::1]:4000"
+	req.Header.Set("Forwarded", `for=1.2.3.4, for="[2001:db8:cafe::17]:4711";proto=https;host="shop.example.com", for=10.0.0.5;proto=http`)
+	req.Header.Set("X-Forwarded-For", "9.9.9.9")
+
+	info := proxies.Resolve(req)
+	if info.IP != "1.2.3.4" {
+		t.Fatalf("unexpected client ip %s", info.IP)
+	}
+
+	req.Header.Set("Forwarded", `for=192.0.2.60;proto=https;host=example.com, for=10.0.0.5;proto=http`)
+	info = proxies.Resolve(req)
+	if info.IP != "192.0.2.60" || info.Scheme != "https" || info.Host != "example.com" {
+		t.Fatalf("unexpected client info %+v", info)
+	}
+}
+
+func TestTrustedProxiesMiddleware(t *testing.T) {
+	proxies := NewTrustedProxies(WithTrustedProxyCIDRs("127.0.0.1"))
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/ip", nil)
+	req.RemoteAddr = "127.0.0.1:5000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	got := ""
+	serveWithMiddleware(proxies, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		got = GetClientIP(ctx)
+		if key := KeyByClientIP()(ctx, req); key != got {
+			t.Errorf("rate limit key %s, want %s", key, got)
+		}
+	}, req)
+	if got != "198.51.100.1" {
+		t.Fatalf("unexpected client ip %s", got)
+	}
+}
+
+func TestTrustedProxiesIllegalCIDR(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("illegal cidr should panic")
+		}
+	}()
+	NewTrustedProxies(WithTrustedProxyCIDRs("10.0.0.0/33"))
+}
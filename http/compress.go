@@ -0,0 +1,462 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+const defaultCompressMinSize = 1024
+
+// Encoder 响应内容编码器, 可复用(Reset)以便池化
+type Encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// EncoderFactory 按压缩级别创建Encoder
+type EncoderFactory func(w io.Writer, level int) (Encoder, error)
+
+var (
+	encoderFactories     = map[string]EncoderFactory{}
+	encoderFactoriesLock sync.RWMutex
+)
+
+func init() {
+	RegisterEncoder(EncodingGzip, func(w io.Writer, level int) (Encoder, error) {
+		return gzip.NewWriterLevel(w, level)
+	})
+	RegisterEncoder(EncodingDeflate, func(w io.Writer, level int) (Encoder, error) {
+		return zlib.NewWriterLevel(w, level)
+	})
+}
+
+// RegisterEncoder 注册Content-Encoding编码器, 同名编码器会被覆盖
+func RegisterEncoder(name string, factory EncoderFactory) {
+	encoderFactoriesLock.Lock()
+	defer encoderFactoriesLock.Unlock()
+
+	encoderFactories[strings.ToLower(name)] = factory
+}
+
+func getEncoderFactory(name string) EncoderFactory {
+	encoderFactoriesLock.RLock()
+	defer encoderFactoriesLock.RUnlock()
+
+	return encoderFactories[name]
+}
+
+var defaultCompressTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/problem+json",
+	"image/svg+xml",
+}
+
+// Compression 响应压缩中间件, 根据Accept-Encoding协商编码
+type Compression struct {
+	level        int
+	minSize      int
+	encodings    []string
+	allowTypes   []string
+	denyTypes    []string
+	encoderPools map[string]*sync.Pool
+}
+
+// CompressionOption configures a Compression
+type CompressionOption func(*Compression)
+
+// WithCompressionLevel sets the compression level passed to the encoder
+func WithCompressionLevel(level int) CompressionOption {
+	return func(c *Compression) {
+		c.level = level
+	}
+}
+
+// WithCompressionMinSize sets the minimum body size to compress
+func WithCompressionMinSize(size int) CompressionOption {
+	return func(c *Compression) {
+		c.minSize = size
+	}
+}
+
+// WithCompressionEncodings sets the supported encodings in server preference order
+func WithCompressionEncodings(encodings ...string) CompressionOption {
+	return func(c *Compression) {
+		c.encodings = c.encodings[:0]
+		for _, val := range encodings {
+			c.encodings = append(c.encodings, strings.ToLower(val))
+		}
+	}
+}
+
+// WithCompressionTypes sets the compressible content types, a trailing '/' matches a whole type
+func WithCompressionTypes(contentTypes ...string) CompressionOption {
+	return func(c *Compression) {
+		c.allowTypes = contentTypes
+	}
+}
+
+// WithCompressionExcludeTypes sets content types that must never be compressed
+func WithCompressionExcludeTypes(contentTypes ...string) CompressionOption {
+	return func(c *Compression) {
+		c.denyTypes = contentTypes
+	}
+}
+
+// NewCompression creates a new Compression with optional configuration
+func NewCompression(opts ...CompressionOption) *Compression {
+	c := &Compression{
+		level:        gzip.DefaultCompression,
+		minSize:      defaultCompressMinSize,
+		encodings:    []string{EncodingGzip, EncodingDeflate},
+		allowTypes:   defaultCompressTypes,
+		encoderPools: map[string]*sync.Pool{},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	for _, name := range c.encodings {
+		factory := getEncoderFactory(name)
+		if factory == nil {
+			continue
+		}
+
+		level := c.level
+		c.encoderPools[name] = &sync.Pool{
+			New: func() any {
+				encoder, err := factory(io.Discard, level)
+				if err != nil {
+					return nil
+				}
+				return encoder
+			},
+		}
+	}
+
+	return c
+}
+
+func (s *Compression) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	if req.Method == HEAD || req.Header.Get("Range") != "" {
+		ctx.Next()
+		return
+	}
+
+	cw := &compressWriter{
+		ResponseWriter: res,
+		compression:    s,
+		encoding:       s.negotiate(req.Header.Get("Accept-Encoding")),
+	}
+	restore, ok := replaceResponseWriter(ctx, cw)
+	if !ok {
+		ctx.Next()
+		return
+	}
+	defer func() {
+		restore()
+		cw.Close()
+	}()
+
+	ctx.Next()
+}
+
+type acceptEncoding struct {
+	name    string
+	quality float64
+}
+
+func parseAcceptEncoding(header string) []acceptEncoding {
+	var ret []acceptEncoding
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		quality := 1.0
+		params = strings.TrimSpace(params)
+		if qVal, ok := strings.CutPrefix(params, "q="); ok {
+			if val, err := strconv.ParseFloat(qVal, 64); err == nil {
+				quality = val
+			}
+		}
+		ret = append(ret, acceptEncoding{name: name, quality: quality})
+	}
+	return ret
+}
+
+// negotiate 选出客户端可接受且服务端优先级最高的编码, 无可用编码时返回空
+func (s *Compression) negotiate(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	accepts := parseAcceptEncoding(header)
+	wildcard := -1.0
+	qualities := map[string]float64{}
+	for _, val := range accepts {
+		if val.name == "*" {
+			wildcard = val.quality
+			continue
+		}
+		qualities[val.name] = val.quality
+	}
+
+	type candidate struct {
+		name     string
+		quality  float64
+		priority int
+	}
+	var candidates []candidate
+	for idx, name := range s.encodings {
+		if _, ok := s.encoderPools[name]; !ok {
+			continue
+		}
+		quality, ok := qualities[name]
+		if !ok {
+			quality = wildcard
+		}
+		if quality > 0 {
+			candidates = append(candidates, candidate{name: name, quality: quality, priority: idx})
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].quality != candidates[j].quality {
+			return candidates[i].quality > candidates[j].quality
+		}
+		return candidates[i].priority < candidates[j].priority
+	})
+	return candidates[0].name
+}
+
+func (s *Compression) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, val := range s.denyTypes {
+		if matchContentType(mediaType, val) {
+			return false
+		}
+	}
+	for _, val := range s.allowTypes {
+		if matchContentType(mediaType, val) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchContentType(mediaType, pattern string) bool {
+	pattern = strings.ToLower(pattern)
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(mediaType, pattern)
+	}
+	return mediaType == pattern
+}
+
+func (s *Compression) getEncoder(name string, w io.Writer) Encoder {
+	pool, ok := s.encoderPools[name]
+	if !ok {
+		return nil
+	}
+
+	encoder, _ := pool.Get().(Encoder)
+	if encoder == nil {
+		return nil
+	}
+	encoder.Reset(w)
+	return encoder
+}
+
+func (s *Compression) putEncoder(name string, encoder Encoder) {
+	encoder.Reset(io.Discard)
+	s.encoderPools[name].Put(encoder)
+}
+
+// compressWriter 在输出达到minSize或被Flush时决定是否压缩
+type compressWriter struct {
+	http.ResponseWriter
+	compression *Compression
+	encoding    string
+	encoder     Encoder
+	status      int
+	decided     bool
+	buffer      bytes.Buffer
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status != 0 {
+		return
+	}
+
+	w.status = code
+	if !bodyAllowedForStatus(code) {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.decided {
+		return w.writeThrough(data)
+	}
+
+	w.buffer.Write(data)
+	if w.buffer.Len() >= w.compression.minSize {
+		w.decide(true)
+		if err := w.flushBuffer(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressWriter) writeThrough(data []byte) (int, error) {
+	if w.encoder != nil {
+		return w.encoder.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *compressWriter) flushBuffer() error {
+	if w.buffer.Len() == 0 {
+		return nil
+	}
+
+	_, err := w.writeThrough(w.buffer.Bytes())
+	w.buffer.Reset()
+	return err
+}
+
+// decide 确定是否启用压缩并写出响应头, sizeReached表示输出已满足最小长度
+func (w *compressWriter) decide(sizeReached bool) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	header := w.Header()
+	if bodyAllowedForStatus(w.status) && w.status != http.StatusPartialContent &&
+		header.Get("Content-Encoding") == "" && header.Get("Content-Range") == "" {
+		contentType := header.Get("Content-Type")
+		if contentType == "" && w.buffer.Len() > 0 {
+			contentType = http.DetectContentType(w.buffer.Bytes())
+			header.Set("Content-Type", contentType)
+		}
+
+		if w.compression.compressible(contentType) {
+			addVary(header, "Accept-Encoding")
+			if sizeReached && w.encoding != "" {
+				w.encoder = w.compression.getEncoder(w.encoding, w.ResponseWriter)
+			}
+		}
+	}
+
+	if w.encoder != nil {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		header.Del("Accept-Ranges")
+		// 压缩后的表示与原始字节不同, 强ETag降级为弱ETag
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.decide(true)
+		_ = w.flushBuffer()
+	}
+
+	if w.encoder != nil {
+		_ = w.encoder.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close 输出剩余缓冲并归还Encoder
+func (w *compressWriter) Close() {
+	if w.status == 0 && w.buffer.Len() == 0 {
+		return
+	}
+
+	if !w.decided {
+		w.decide(w.buffer.Len() >= w.compression.minSize)
+	}
+	_ = w.flushBuffer()
+
+	if w.encoder != nil {
+		_ = w.encoder.Close()
+		w.compression.putEncoder(w.encoding, w.encoder)
+		w.encoder = nil
+	}
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent:
+		return false
+	case status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+func addVary(header http.Header, field string) {
+	for _, val := range header.Values("Vary") {
+		for _, item := range strings.Split(val, ",") {
+			item = strings.TrimSpace(item)
+			if item == "*" || strings.EqualFold(item, field) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", field)
+}
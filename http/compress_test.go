@@ -0,0 +1,137 @@
+package http
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveWithMiddleware(handler MiddleWareHandler, routeFunc RouteHandleFunc, req *http.Request) *httptest.ResponseRecorder {
+	registry := NewRouteRegistry()
+	registry.AddHandler(req.URL.Path, req.Method, routeFunc)
+
+	chains := NewMiddleWareChains()
+	chains.Append(handler)
+
+	w := httptest.NewRecorder()
+	NewRequestContext(chains.GetHandlers(), registry, context.Background(), w, req).Run()
+	return w
+}
+
+func TestCompressionNegotiate(t *testing.T) {
+	c := NewCompression()
+
+	cases := map[string]string{
+		"":                          "",
+		"gzip":                      EncodingGzip,
+		"deflate, gzip;q=0.5":       EncodingDeflate,
+		"gzip;q=0, deflate;q=0":     "",
+		"*":                         EncodingGzip,
+		"br, *;q=0.1":               EncodingGzip,
+		"identity":                  "",
+		"GZIP;q=0.8, deflate;q=0.8": EncodingGzip,
+	}
+	for header, want := range cases {
+		if got := c.negotiate(header); got != want {
+			t.Errorf("negotiate(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestCompressionCompressesLargeBody(t *testing.T) {
+	body := strings.Repeat("hello compression ", 200)
+	req := httptest.NewRequest(http.MethodGet, "/data", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	var innerRW ResponseWriter
+	w := serveWithMiddleware(NewCompression(), func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		innerRW = res.(ResponseWriter)
+		res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = res.Write([]byte(body))
+	}, req)
+
+	if w.Header().Get("Content-Encoding") != EncodingGzip {
+		t.Fatalf("Content-Encoding = %q, want gzip", w.Header().Get("Content-Encoding"))
+	}
+	if w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("Vary = %q, want Accept-Encoding", w.Header().Get("Vary"))
+	}
+	if innerRW.Size() != len(body) || innerRW.Status() != http.StatusOK {
+		t.Fatalf("inner accounting = %d/%d, want %d/200", innerRW.Size(), innerRW.Status(), len(body))
+	}
+
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader failed: %v", err)
+	}
+	decoded, _ := io.ReadAll(reader)
+	if string(decoded) != body {
+		t.Fatal("decoded body mismatch")
+	}
+}
+
+func TestCompressionSkipsSmallAndExcludedBodies(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/small", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := serveWithMiddleware(NewCompression(), func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		_, _ = res.Write([]byte(`{"ok":true}`))
+	}, req)
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != `{"ok":true}` {
+		t.Fatalf("small body should not be compressed, got %q", w.Header().Get("Content-Encoding"))
+	}
+	if w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("Vary = %q, want Accept-Encoding", w.Header().Get("Vary"))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/image", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w = serveWithMiddleware(NewCompression(WithCompressionMinSize(0)), func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "image/png")
+		_, _ = res.Write([]byte(strings.Repeat("x", 4096)))
+	}, req)
+	if w.Header().Get("Content-Encoding") != "" || w.Header().Get("Vary") != "" {
+		t.Fatal("image/png should not be compressed")
+	}
+}
+
+func TestCompressionFlushStreamsSSE(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := serveWithMiddleware(NewCompression(), func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "text/event-stream")
+		_, _ = res.Write([]byte("data: 1\n\n"))
+		res.(http.Flusher).Flush()
+	}, req)
+	if !w.Flushed || w.Header().Get("Content-Encoding") != EncodingGzip {
+		t.Fatalf("expected flushed gzip stream, flushed=%v encoding=%q", w.Flushed, w.Header().Get("Content-Encoding"))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w = serveWithMiddleware(NewCompression(WithCompressionExcludeTypes("text/event-stream")), func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "text/event-stream")
+		_, _ = res.Write([]byte("data: 1\n\n"))
+		res.(http.Flusher).Flush()
+	}, req)
+	if !w.Flushed || w.Header().Get("Content-Encoding") != "" || w.Body.String() != "data: 1\n\n" {
+		t.Fatal("excluded SSE stream should pass through uncompressed")
+	}
+}
+
+func TestCompressionSkipsRangeRequests(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/file", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Range", "bytes=0-9")
+	w := serveWithMiddleware(NewCompression(WithCompressionMinSize(0)), func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "text/plain")
+		_, _ = res.Write([]byte(strings.Repeat("x", 4096)))
+	}, req)
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Fatal("ranged request should not be compressed")
+	}
+}
@@ -0,0 +1,239 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrServerOverloaded is returned when a request is shed by a ConcurrencyLimit
+var ErrServerOverloaded = errors.New("http: server overloaded")
+
+// AdaptiveConcurrency 自适应并发配置, 按AIMD根据观测到的延迟调整并发上限
+//
+// 请求耗时不超过TargetLatency时, 每完成约limit个请求上限加1; 超过时上限乘以Backoff,
+// 每个Window内最多减少一次, 避免同一批慢请求连续把上限压到MinLimit.
+type AdaptiveConcurrency struct {
+	MinLimit      int
+	MaxLimit      int
+	TargetLatency time.Duration
+	// Backoff 乘性减少系数, 取值(0,1), 默认0.9
+	Backoff float64
+	// Window 两次乘性减少的最小间隔, 默认TargetLatency(至少10ms)
+	Window time.Duration
+}
+
+func (a AdaptiveConcurrency) normalize(limit int) AdaptiveConcurrency {
+	if a.MinLimit <= 0 {
+		a.MinLimit = 1
+	}
+	if a.MaxLimit < limit {
+		a.MaxLimit = limit
+	}
+	if a.Backoff <= 0 || a.Backoff >= 1 {
+		a.Backoff = 0.9
+	}
+	if a.Window <= 0 {
+		a.Window = max(a.TargetLatency, 10*time.Millisecond)
+	}
+	return a
+}
+
+type concurrencyWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// ConcurrencyLimit 并发限制中间件, 注册在分组上即为该分组的隔离舱
+//
+// 超过上限的请求进入有界队列等待, 队列已满或等待超时时返回503和Retry-After.
+type ConcurrencyLimit struct {
+	name         string
+	limit        float64
+	queueSize    int
+	queueTimeout time.Duration
+	retryAfter   time.Duration
+	adaptive     *AdaptiveConcurrency
+
+	mu           sync.Mutex
+	inFlight     int
+	waiters      []*concurrencyWaiter
+	lastDecrease time.Time
+}
+
+// ConcurrencyLimitOption configures a ConcurrencyLimit
+type ConcurrencyLimitOption func(*ConcurrencyLimit)
+
+// WithConcurrencyName sets the name written to logs
+func WithConcurrencyName(name string) ConcurrencyLimitOption {
+	return func(c *ConcurrencyLimit) {
+		c.name = name
+	}
+}
+
+// WithConcurrencyQueue lets up to size requests wait at most timeout for a slot, timeout <= 0 waits until the request is canceled; default no queue
+func WithConcurrencyQueue(size int, timeout time.Duration) ConcurrencyLimitOption {
+	return func(c *ConcurrencyLimit) {
+		c.queueSize = size
+		c.queueTimeout = timeout
+	}
+}
+
+// WithConcurrencyRetryAfter sets the Retry-After sent with 503, default 1 second
+func WithConcurrencyRetryAfter(retryAfter time.Duration) ConcurrencyLimitOption {
+	return func(c *ConcurrencyLimit) {
+		c.retryAfter = retryAfter
+	}
+}
+
+// WithAdaptiveConcurrency adjusts the limit from observed latency, the initial limit is the one passed to NewConcurrencyLimit
+func WithAdaptiveConcurrency(adaptive AdaptiveConcurrency) ConcurrencyLimitOption {
+	return func(c *ConcurrencyLimit) {
+		c.adaptive = &adaptive
+	}
+}
+
+// NewConcurrencyLimit creates a new ConcurrencyLimit allowing limit requests in flight
+func NewConcurrencyLimit(limit int, opts ...ConcurrencyLimitOption) *ConcurrencyLimit {
+	if limit <= 0 {
+		limit = 1
+	}
+
+	c := &ConcurrencyLimit{
+		limit:      float64(limit),
+		retryAfter: time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.adaptive != nil {
+		adaptive := c.adaptive.normalize(limit)
+		c.adaptive = &adaptive
+	}
+
+	return c
+}
+
+// Limit 当前并发上限
+func (s *ConcurrencyLimit) Limit() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return int(s.limit)
+}
+
+// InFlight 当前正在处理的请求数
+func (s *ConcurrencyLimit) InFlight() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.inFlight
+}
+
+// QueueLen 当前排队的请求数
+func (s *ConcurrencyLimit) QueueLen() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.waiters)
+}
+
+func (s *ConcurrencyLimit) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	if !s.acquire(ctx.Context()) {
+		slog.WarnContext(ctx.Context(), "request shed", "name", s.name, "method", req.Method, "path", req.URL.Path, "limit", s.Limit())
+		res.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(s.retryAfter)))
+		RenderError(ctx.Context(), res, req, http.StatusServiceUnavailable, ErrServerOverloaded)
+		return
+	}
+
+	start := time.Now()
+	defer func() {
+		s.release(time.Since(start))
+	}()
+	ctx.Next()
+}
+
+// acquire 获取执行名额, 需要时排队等待
+func (s *ConcurrencyLimit) acquire(ctx context.Context) bool {
+	s.mu.Lock()
+	if s.inFlight < int(s.limit) && len(s.waiters) == 0 {
+		s.inFlight++
+		s.mu.Unlock()
+		return true
+	}
+	if len(s.waiters) >= s.queueSize {
+		s.mu.Unlock()
+		return false
+	}
+
+	waiter := &concurrencyWaiter{ready: make(chan struct{})}
+	s.waiters = append(s.waiters, waiter)
+	s.mu.Unlock()
+
+	// 未设置排队超时时一直等到请求结束
+	var expired <-chan time.Time
+	if s.queueTimeout > 0 {
+		timer := time.NewTimer(s.queueTimeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case <-waiter.ready:
+		return true
+	case <-expired:
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 超时与分配名额同时发生时以分配为准
+	if waiter.granted {
+		return true
+	}
+	for idx, val := range s.waiters {
+		if val == waiter {
+			s.waiters = append(s.waiters[:idx], s.waiters[idx+1:]...)
+			break
+		}
+	}
+	return false
+}
+
+func (s *ConcurrencyLimit) release(elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.inFlight--
+	if s.adaptive != nil {
+		s.adjustLocked(elapsed, time.Now())
+	}
+
+	for len(s.waiters) > 0 && s.inFlight < int(s.limit) {
+		waiter := s.waiters[0]
+		s.waiters = s.waiters[1:]
+		waiter.granted = true
+		s.inFlight++
+		close(waiter.ready)
+	}
+}
+
+// adjustLocked AIMD: 延迟达标时加性增加, 超标时每个窗口最多乘性减少一次
+func (s *ConcurrencyLimit) adjustLocked(elapsed time.Duration, now time.Time) {
+	if elapsed <= s.adaptive.TargetLatency {
+		s.limit = min(s.limit+1/s.limit, float64(s.adaptive.MaxLimit))
+		return
+	}
+	if now.Sub(s.lastDecrease) < s.adaptive.Window {
+		return
+	}
+	s.lastDecrease = now
+	s.limit = max(s.limit*s.adaptive.Backoff, float64(s.adaptive.MinLimit))
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// serveBlocked 在后台发起请求, handler阻塞直到release关闭
+func serveBlocked(limiter *ConcurrencyLimit, started chan<- struct{}, release <-chan struct{}) <-chan int {
+	done := make(chan int, 1)
+	go func() {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/slow", nil)
+		w := serveWithMiddleware(limiter, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+			started <- struct{}{}
+			<-release
+			_, _ = res.Write([]byte("ok"))
+		}, req)
+		done <- w.Code
+	}()
+	return done
+}
+
+func TestConcurrencyLimitShed(t *testing.T) {
+	limiter := NewConcurrencyLimit(1, WithConcurrencyRetryAfter(3*time.Second))
+
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	done := serveBlocked(limiter, started, release)
+	<-started
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/slow", nil)
+	w := serveWithMiddleware(limiter, okHandler, req)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "3" {
+		t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Fatalf("unexpected status %d", code)
+	}
+	if limiter.InFlight() != 0 {
+		t.Fatalf("in flight should be released, got %d", limiter.InFlight())
+	}
+}
+
+func TestConcurrencyLimitQueue(t *testing.T) {
+	limiter := NewConcurrencyLimit(1, WithConcurrencyQueue(1, time.Second))
+
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	first := serveBlocked(limiter, started, release)
+	<-started
+	second := serveBlocked(limiter, started, release)
+	for limiter.QueueLen() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 队列已满
+	w := serveWithMiddleware(limiter, okHandler, httptest.NewRequest(http.MethodGet, "http://example.com/slow", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("overflow should be rejected, got %d", w.Code)
+	}
+
+	close(release)
+	if <-first != http.StatusOK || <-second != http.StatusOK {
+		t.Fatal("queued request should be served after release")
+	}
+}
+
+func TestConcurrencyLimitQueueTimeout(t *testing.T) {
+	limiter := NewConcurrencyLimit(1, WithConcurrencyQueue(1, 10*time.Millisecond))
+
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	done := serveBlocked(limiter, started, release)
+	<-started
+
+	w := serveWithMiddleware(limiter, okHandler, httptest.NewRequest(http.MethodGet, "http://example.com/slow", nil))
+	if w.Code != http.StatusServiceUnavailable || limiter.QueueLen() != 0 {
+		t.Fatalf("queue timeout should reject, got %d queue %d", w.Code, limiter.QueueLen())
+	}
+
+	close(release)
+	<-done
+}
+
+func TestConcurrencyLimitAdaptive(t *testing.T) {
+	limiter := NewConcurrencyLimit(4, WithAdaptiveConcurrency(AdaptiveConcurrency{
+		MinLimit:      2,
+		MaxLimit:      6,
+		TargetLatency: 50 * time.Millisecond,
+	}))
+
+	now := time.Now()
+	for idx := 0; idx < 50; idx++ {
+		limiter.adjustLocked(time.Second, now)
+	}
+	if limiter.Limit() != 3 {
+		t.Fatalf("a burst of slow requests should decrease the limit once per window, got %d", limiter.Limit())
+	}
+	for idx := 1; idx <= 10; idx++ {
+		limiter.adjustLocked(time.Second, now.Add(time.Duration(idx)*50*time.Millisecond))
+	}
+	if limiter.Limit() != 2 {
+		t.Fatalf("sustained slow requests should decrease limit to min, got %d", limiter.Limit())
+	}
+
+	var wg sync.WaitGroup
+	for idx := 0; idx < 100; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serveWithMiddleware(limiter, okHandler, httptest.NewRequest(http.MethodGet, "http://example.com/fast", nil))
+		}()
+	}
+	wg.Wait()
+	if limiter.Limit() <= 2 || limiter.Limit() > 6 {
+		t.Fatalf("fast requests should increase limit within max, got %d", limiter.Limit())
+	}
+}
@@ -3,6 +3,8 @@ package http
 import (
 	"context"
 	"net/http"
+
+	"github.com/muidea/magicEngine/trace"
 )
 
 type RequestContext interface {
@@ -14,6 +16,26 @@ type RequestContext interface {
 	Run()
 }
 
+// ResponseWriterUpdater RequestContext 的可选接口, 中间件通过它替换后续处理链使用的ResponseWriter
+type ResponseWriterUpdater interface {
+	// UpdateResponseWriter 替换后续中间件和路由使用的ResponseWriter, 返回替换前的ResponseWriter
+	UpdateResponseWriter(res http.ResponseWriter) ResponseWriter
+}
+
+// replaceResponseWriter 替换后续处理链使用的ResponseWriter并返回恢复函数,
+// ctx 未实现 ResponseWriterUpdater 时返回false, 调用方跳过需要包装响应的处理
+func replaceResponseWriter(ctx RequestContext, res http.ResponseWriter) (func(), bool) {
+	updater, ok := ctx.(ResponseWriterUpdater)
+	if !ok {
+		return nil, false
+	}
+
+	preRW := updater.UpdateResponseWriter(res)
+	return func() {
+		updater.UpdateResponseWriter(preRW)
+	}, true
+}
+
 type baseContext struct {
 	rw    ResponseWriter
 	req   *http.Request
@@ -24,6 +46,16 @@ func (c *baseContext) Written() bool {
 	return c.rw.Written()
 }
 
+func (c *baseContext) UpdateResponseWriter(res http.ResponseWriter) ResponseWriter {
+	preRW := c.rw
+	rw, ok := res.(ResponseWriter)
+	if !ok {
+		rw = NewResponseWriter(res)
+	}
+	c.rw = rw
+	return preRW
+}
+
 func (c *baseContext) incrementIndex() {
 	c.index++
 }
@@ -32,6 +64,11 @@ func (c *baseContext) getIndex() int {
 	return c.index
 }
 
+// detachableContext 可以复制出独立的上下文, 在请求结束后继续执行剩余的处理链
+type detachableContext interface {
+	detach(ctx context.Context, res http.ResponseWriter, req *http.Request) RequestContext
+}
+
 type requestContext struct {
 	baseContext
 	middlewareChainsFuncs []MiddleWareHandleFunc
@@ -49,6 +86,13 @@ func NewRequestContext(middlewareChains []MiddleWareHandleFunc, routeRegistry Ro
 	}
 }
 
+func (c *requestContext) detach(ctx context.Context, res http.ResponseWriter, req *http.Request) RequestContext {
+	clone := *c
+	clone.baseContext = baseContext{rw: NewResponseWriter(res), req: req, index: c.index}
+	clone.context = ctx
+	return &clone
+}
+
 func (c *requestContext) Update(ctx context.Context) {
 	c.context = ctx
 }
@@ -87,7 +131,7 @@ func (c *requestContext) Run() {
 			http.Error(c.baseContext.rw, "", http.StatusNoContent)
 		}
 	} else {
-		http.NotFound(c.baseContext.rw, c.baseContext.req)
+		RenderError(c.Context(), c.baseContext.rw, c.baseContext.req, http.StatusNotFound, ErrURLNotFound)
 	}
 }
 
@@ -108,6 +152,13 @@ func NewRouteContext(reqCtx context.Context, chainsHandler []MiddleWareHandler,
 	}
 }
 
+func (c *routeContext) detach(ctx context.Context, res http.ResponseWriter, req *http.Request) RequestContext {
+	clone := *c
+	clone.baseContext = baseContext{rw: NewResponseWriter(res), req: req, index: c.index}
+	clone.context = ctx
+	return &clone
+}
+
 func (c *routeContext) Update(ctx context.Context) {
 	c.context = ctx
 }
@@ -132,7 +183,7 @@ func (c *routeContext) Written() bool {
 func (c *routeContext) Run() {
 	totalSize := len(c.middlewareChainsHandler)
 	for c.baseContext.index < totalSize {
-		c.middlewareChainsHandler[c.baseContext.index].MiddleWareHandle(c, c.baseContext.rw, c.baseContext.req)
+		c.runMiddleware(c.middlewareChainsHandler[c.baseContext.index])
 		c.baseContext.index++
 		if c.Written() {
 			return
@@ -148,3 +199,21 @@ func (c *routeContext) Run() {
 		http.Error(c.baseContext.rw, "", http.StatusNoContent)
 	}
 }
+
+// runMiddleware 执行路由中间件, 请求被追踪时为其创建子span
+func (c *routeContext) runMiddleware(handler MiddleWareHandler) {
+	parentSpan := trace.SpanFromContext(c.context)
+	spanCtx, span := startMiddlewareSpan(c.context, handler)
+	if span == nil {
+		handler.MiddleWareHandle(c, c.baseContext.rw, c.baseContext.req)
+		return
+	}
+
+	c.context = spanCtx
+	defer func() {
+		span.End()
+		// 保留中间件写入的值, 后续中间件重新挂到父span下
+		c.context = trace.ContextWithSpan(c.context, parentSpan)
+	}()
+	handler.MiddleWareHandle(c, c.baseContext.rw, c.baseContext.req)
+}
@@ -89,3 +89,49 @@ func TestResponseWriter_Write(t *testing.T) {
 		t.Errorf("expected status %d, got %d", http.StatusOK, rw.Status())
 	}
 }
+
+// plainRequestContext 只实现 RequestContext, 不支持替换ResponseWriter
+type plainRequestContext struct {
+	ctx     context.Context
+	next    func()
+	written bool
+}
+
+func (c *plainRequestContext) Update(ctx context.Context) { c.ctx = ctx }
+func (c *plainRequestContext) Context() context.Context   { return c.ctx }
+func (c *plainRequestContext) Value(key any) any          { return c.ctx.Value(key) }
+func (c *plainRequestContext) Written() bool              { return c.written }
+func (c *plainRequestContext) Run()                       {}
+func (c *plainRequestContext) Next() {
+	c.written = true
+	c.next()
+}
+
+func TestMiddlewaresWithoutResponseWriterUpdater(t *testing.T) {
+	var _ ResponseWriterUpdater = &requestContext{}
+	var _ ResponseWriterUpdater = &routeContext{}
+
+	middlewares := map[string]MiddleWareHandler{
+		"compression": NewCompression(WithCompressionMinSize(0)),
+		"etag":        NewETag(),
+		"cache":       NewResponseCache(),
+		"idempotency": NewIdempotency(),
+	}
+	for name, middleware := range middlewares {
+		req := httptest.NewRequest(http.MethodGet, "/plain", nil)
+		if name == "idempotency" {
+			req = httptest.NewRequest(http.MethodPost, "/plain", nil)
+			req.Header.Set(DefaultIdempotencyHeader, "k1")
+		}
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		ctx := &plainRequestContext{ctx: context.Background(), next: func() {
+			_, _ = w.Write([]byte("hello"))
+		}}
+
+		middleware.MiddleWareHandle(ctx, w, req)
+		if w.Body.String() != "hello" || w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%s should fall back to the plain handler chain, got %q %v", name, w.Body.String(), w.Header())
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// CredentialStore 凭证存储, 实现必须使用常量时间比较
+type CredentialStore interface {
+	// VerifyPassword 校验用户名和密码
+	VerifyPassword(username, password string) bool
+	// LookupAPIKey 查找API key对应的调用方ID
+	LookupAPIKey(key string) (string, bool)
+}
+
+// PasswordVerifier 校验密码与哈希是否匹配
+type PasswordVerifier func(hashed, password string) bool
+
+var (
+	passwordVerifiers = map[string]PasswordVerifier{
+		"{SHA}":  verifySHA1Password,
+		"$apr1$": verifyAPR1Password,
+		"$2a$":   verifyBcryptPassword,
+		"$2b$":   verifyBcryptPassword,
+		"$2y$":   verifyBcryptPassword,
+	}
+	passwordVerifiersLock sync.RWMutex
+)
+
+// RegisterPasswordVerifier 按哈希前缀注册密码校验函数, 内置 {SHA}、$apr1$ 和 bcrypt($2a$/$2b$/$2y$)
+func RegisterPasswordVerifier(prefix string, verifier PasswordVerifier) {
+	passwordVerifiersLock.Lock()
+	defer passwordVerifiersLock.Unlock()
+
+	passwordVerifiers[prefix] = verifier
+}
+
+// VerifyPassword 按哈希前缀选择校验函数, 未注册的格式(包括明文和crypt(3) DES)总是失败
+func VerifyPassword(hashed, password string) bool {
+	verifier, ok := lookupPasswordVerifier(hashed)
+	return ok && verifier(hashed, password)
+}
+
+func lookupPasswordVerifier(hashed string) (PasswordVerifier, bool) {
+	passwordVerifiersLock.RLock()
+	defer passwordVerifiersLock.RUnlock()
+
+	for prefix, verifier := range passwordVerifiers {
+		if strings.HasPrefix(hashed, prefix) {
+			return verifier, true
+		}
+	}
+	return nil, false
+}
+
+// constantTimeEqual 比较摘要, 避免长度差异泄露信息
+func constantTimeEqual(a, b string) bool {
+	aDigest := sha256.Sum256([]byte(a))
+	bDigest := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(aDigest[:], bDigest[:]) == 1
+}
+
+func verifySHA1Password(hashed, password string) bool {
+	digest := sha1.Sum([]byte(password))
+	return constantTimeEqual(strings.TrimPrefix(hashed, "{SHA}"), base64.StdEncoding.EncodeToString(digest[:]))
+}
+
+func verifyAPR1Password(hashed, password string) bool {
+	salt, _, ok := strings.Cut(strings.TrimPrefix(hashed, "$apr1$"), "$")
+	if !ok {
+		return false
+	}
+	return constantTimeEqual(hashed, apr1Crypt(password, salt))
+}
+
+func verifyBcryptPassword(hashed, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password)) == nil
+}
+
+const apr1Alphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// apr1Crypt Apache htpasswd -m 使用的MD5哈希
+func apr1Crypt(password, salt string) string {
+	const magic = "$apr1$"
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	pw := []byte(password)
+
+	alt := md5.Sum([]byte(password + salt + password))
+	ctx := md5.New()
+	ctx.Write([]byte(password + magic + salt))
+	for idx := len(pw); idx > 0; idx -= 16 {
+		ctx.Write(alt[:min(idx, 16)])
+	}
+	for idx := len(pw); idx > 0; idx >>= 1 {
+		if idx&1 == 1 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write(pw[:1])
+		}
+	}
+	final := ctx.Sum(nil)
+
+	for idx := 0; idx < 1000; idx++ {
+		round := md5.New()
+		if idx&1 == 1 {
+			round.Write(pw)
+		} else {
+			round.Write(final)
+		}
+		if idx%3 != 0 {
+			round.Write([]byte(salt))
+		}
+		if idx%7 != 0 {
+			round.Write(pw)
+		}
+		if idx&1 == 1 {
+			round.Write(final)
+		} else {
+			round.Write(pw)
+		}
+		final = round.Sum(nil)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(magic + salt + "$")
+	to64 := func(val uint32, size int) {
+		for ; size > 0; size-- {
+			buf.WriteByte(apr1Alphabet[val&0x3f])
+			val >>= 6
+		}
+	}
+	for _, group := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		to64(uint32(final[group[0]])<<16|uint32(final[group[1]])<<8|uint32(final[group[2]]), 4)
+	}
+	to64(uint32(final[11]), 2)
+	return buf.String()
+}
+
+// MemoryCredentialStore 内存凭证存储, 只保存密码和API key的摘要
+type MemoryCredentialStore struct {
+	users   map[string][sha256.Size]byte
+	apiKeys map[[sha256.Size]byte]string
+	mu      sync.RWMutex
+}
+
+// NewMemoryCredentialStore 新建内存凭证存储
+func NewMemoryCredentialStore() *MemoryCredentialStore {
+	return &MemoryCredentialStore{
+		users:   map[string][sha256.Size]byte{},
+		apiKeys: map[[sha256.Size]byte]string{},
+	}
+}
+
+// AddUser 添加或更新用户
+func (s *MemoryCredentialStore) AddUser(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[username] = sha256.Sum256([]byte(password))
+}
+
+// AddAPIKey 添加API key, id为调用方标识
+func (s *MemoryCredentialStore) AddAPIKey(key, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apiKeys[sha256.Sum256([]byte(key))] = id
+}
+
+// RemoveUser 删除用户
+func (s *MemoryCredentialStore) RemoveUser(username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.users, username)
+}
+
+// RemoveAPIKey 删除API key
+func (s *MemoryCredentialStore) RemoveAPIKey(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.apiKeys, sha256.Sum256([]byte(key)))
+}
+
+func (s *MemoryCredentialStore) VerifyPassword(username, password string) bool {
+	s.mu.RLock()
+	expected, ok := s.users[username]
+	s.mu.RUnlock()
+
+	digest := sha256.Sum256([]byte(password))
+	// 用户不存在时同样做一次比较, 避免通过耗时区分用户名
+	return subtle.ConstantTimeCompare(expected[:], digest[:]) == 1 && ok
+}
+
+func (s *MemoryCredentialStore) LookupAPIKey(key string) (string, bool) {
+	digest := sha256.Sum256([]byte(key))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, found := "", false
+	for candidate, val := range s.apiKeys {
+		if subtle.ConstantTimeCompare(candidate[:], digest[:]) == 1 {
+			id, found = val, true
+		}
+	}
+	return id, found
+}
+
+// apiKeyLinePrefix htpasswd文件中API key行的前缀
+const apiKeyLinePrefix = "apikey:"
+
+// HtpasswdStore 基于htpasswd文件的凭证存储, 文件修改后自动重新加载
+//
+// API key与用户密码分开保存, 格式为 "apikey:调用方ID:{SHA256}十六进制摘要"
+// (摘要可用 printf %s "$KEY" | sha256sum 生成), 按摘要索引查找, 不会与用户密码比较.
+// 未注册的哈希格式在加载时记录日志并忽略, 明文密码需要 WithHtpasswdPlaintext 开启.
+type HtpasswdStore struct {
+	filePath       string
+	allowPlaintext bool
+	entries        map[string]htpasswdEntry
+	apiKeys        map[[sha256.Size]byte]string
+	dummyHash      string
+	modTime        time.Time
+	size           int64
+	mu             sync.RWMutex
+}
+
+type htpasswdEntry struct {
+	hashed string
+	plain  bool
+}
+
+// HtpasswdOption configures a HtpasswdStore
+type HtpasswdOption func(*HtpasswdStore)
+
+// WithHtpasswdPlaintext accepts entries without a registered hash prefix as plaintext passwords, default false
+func WithHtpasswdPlaintext(allow bool) HtpasswdOption {
+	return func(s *HtpasswdStore) {
+		s.allowPlaintext = allow
+	}
+}
+
+// NewHtpasswdStore 加载htpasswd文件
+func NewHtpasswdStore(filePath string, opts ...HtpasswdOption) (*HtpasswdStore, error) {
+	s := &HtpasswdStore{filePath: filePath}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload 重新加载htpasswd文件
+func (s *HtpasswdStore) Reload() error {
+	fileInfo, fileErr := os.Stat(s.filePath)
+	if fileErr != nil {
+		return fileErr
+	}
+	content, contentErr := os.ReadFile(s.filePath)
+	if contentErr != nil {
+		return contentErr
+	}
+
+	entries := map[string]htpasswdEntry{}
+	apiKeys := map[[sha256.Size]byte]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if keyLine, isKey := strings.CutPrefix(line, apiKeyLinePrefix); isKey {
+			id, digest, ok := parseAPIKeyLine(keyLine)
+			if !ok {
+				return fmt.Errorf("illegal api key line %d in %s", lineNo, s.filePath)
+			}
+			apiKeys[digest] = id
+			continue
+		}
+
+		username, hashed, ok := strings.Cut(line, ":")
+		if !ok || username == "" {
+			return fmt.Errorf("illegal htpasswd line %d in %s", lineNo, s.filePath)
+		}
+		if _, known := lookupPasswordVerifier(hashed); known {
+			entries[username] = htpasswdEntry{hashed: hashed}
+			continue
+		}
+		if s.allowPlaintext && !strings.HasPrefix(hashed, "$") {
+			entries[username] = htpasswdEntry{hashed: hashed, plain: true}
+			continue
+		}
+		slog.Warn("unsupported htpasswd hash format, entry ignored", "file", s.filePath, "line", lineNo, "user", username)
+	}
+
+	s.mu.RLock()
+	dummyHash := s.dummyHash
+	s.mu.RUnlock()
+	dummyHash = htpasswdDummyHash(entries, dummyHash)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = entries
+	s.apiKeys = apiKeys
+	s.dummyHash = dummyHash
+	s.modTime = fileInfo.ModTime()
+	s.size = fileInfo.Size()
+	return nil
+}
+
+func (s *HtpasswdStore) reloadIfChanged() {
+	fileInfo, fileErr := os.Stat(s.filePath)
+	if fileErr != nil {
+		return
+	}
+
+	s.mu.RLock()
+	changed := !fileInfo.ModTime().Equal(s.modTime) || fileInfo.Size() != s.size
+	s.mu.RUnlock()
+	if !changed {
+		return
+	}
+
+	if err := s.Reload(); err != nil {
+		slog.Error("reload htpasswd failed", "file", s.filePath, "err", err)
+	}
+}
+
+func (s *HtpasswdStore) VerifyPassword(username, password string) bool {
+	s.reloadIfChanged()
+
+	s.mu.RLock()
+	entry, ok := s.entries[username]
+	dummyHash := s.dummyHash
+	s.mu.RUnlock()
+	if !ok {
+		// 用户不存在时用同样代价的哈希做一次校验, 避免通过耗时区分用户名
+		VerifyPassword(dummyHash, password)
+		return false
+	}
+	if entry.plain {
+		return constantTimeEqual(entry.hashed, password)
+	}
+	return VerifyPassword(entry.hashed, password)
+}
+
+// htpasswdDummyHash 返回校验不存在用户时使用的哈希: 文件中有bcrypt时按其中最高cost生成,
+// 否则使用文件中的其他哈希; cost未变化时复用current
+func htpasswdDummyHash(entries map[string]htpasswdEntry, current string) string {
+	cost, other := 0, ""
+	for _, entry := range entries {
+		if entry.plain {
+			continue
+		}
+		if entryCost, costErr := bcrypt.Cost([]byte(entry.hashed)); costErr == nil {
+			cost = max(cost, entryCost)
+		} else if other == "" || entry.hashed < other {
+			other = entry.hashed
+		}
+	}
+	if cost == 0 {
+		return other
+	}
+	if currentCost, costErr := bcrypt.Cost([]byte(current)); costErr == nil && currentCost == cost {
+		return current
+	}
+
+	dummyHash, dummyErr := bcrypt.GenerateFromPassword([]byte("magicEngine-htpasswd-dummy"), cost)
+	if dummyErr != nil {
+		return other
+	}
+	return string(dummyHash)
+}
+
+func (s *HtpasswdStore) LookupAPIKey(key string) (string, bool) {
+	s.reloadIfChanged()
+
+	digest := sha256.Sum256([]byte(key))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.apiKeys[digest]
+	return id, ok
+}
+
+// parseAPIKeyLine 解析 "调用方ID:{SHA256}十六进制摘要"
+func parseAPIKeyLine(line string) (string, [sha256.Size]byte, bool) {
+	var digest [sha256.Size]byte
+
+	id, hashed, ok := strings.Cut(line, ":")
+	hexDigest, hasPrefix := strings.CutPrefix(hashed, "{SHA256}")
+	if !ok || id == "" || !hasPrefix {
+		return "", digest, false
+	}
+	raw, rawErr := hex.DecodeString(strings.ToLower(hexDigest))
+	if rawErr != nil || len(raw) != sha256.Size {
+		return "", digest, false
+	}
+	copy(digest[:], raw)
+	return id, digest, true
+}
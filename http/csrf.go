@@ -0,0 +1,330 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/muidea/magicCommon/foundation/helper"
+)
+
+var (
+	// ErrCSRFTokenInvalid is returned when an unsafe request carries a missing or wrong CSRF token
+	ErrCSRFTokenInvalid = errors.New("http: csrf token missing or invalid")
+
+	// ErrCSRFOriginMismatch is returned when Origin/Referer of an unsafe request is not trusted
+	ErrCSRFOriginMismatch = errors.New("http: csrf origin not allowed")
+)
+
+// CSRFMode CSRF token保存方式
+type CSRFMode int
+
+const (
+	// CSRFDoubleSubmit token保存在cookie中, 提交的token必须与cookie一致
+	CSRFDoubleSubmit CSRFMode = iota
+	// CSRFSynchronizer token保存在会话中, 需要先注册Sessions中间件
+	CSRFSynchronizer
+)
+
+const (
+	csrfTokenSize     = 32
+	csrfSessionKey    = "_csrf"
+	DefaultCSRFHeader = "X-CSRF-Token"
+	DefaultCSRFField  = "csrf_token"
+)
+
+// CSRFTokenKey context中保存当前请求CSRF token的key
+type CSRFTokenKey struct{}
+
+// GetCSRFToken 获取用于模板或前端提交的token, 每次请求的值不同但都有效
+func GetCSRFToken(ctx context.Context) string {
+	token, _ := helper.GetValueFromContext[string](ctx, CSRFTokenKey{})
+	return token
+}
+
+// CSRFTemplateField 返回可直接放入表单的隐藏字段
+func CSRFTemplateField(ctx context.Context) template.HTML {
+	return template.HTML(`<input type="hidden" name="` + DefaultCSRFField + `" value="` + template.HTMLEscapeString(GetCSRFToken(ctx)) + `">`)
+}
+
+// CSRF CSRF防护中间件
+type CSRF struct {
+	mode           CSRFMode
+	cookieName     string
+	cookiePath     string
+	cookieDomain   string
+	cookieSecure   bool
+	cookieSameSite http.SameSite
+	header         string
+	formField      string
+	trustedOrigins []string
+	exemptPrefixes []string
+	exemptGroups   []RouteGroup
+	skipper        func(req *http.Request) bool
+}
+
+// CSRFOption configures a CSRF
+type CSRFOption func(*CSRF)
+
+// WithCSRFMode sets the token storage mode, default CSRFDoubleSubmit
+func WithCSRFMode(mode CSRFMode) CSRFOption {
+	return func(c *CSRF) {
+		c.mode = mode
+	}
+}
+
+// WithCSRFCookie sets the cookie used by double-submit mode, default "csrf_token" on path "/"
+func WithCSRFCookie(name, path, domain string, secure bool, sameSite http.SameSite) CSRFOption {
+	return func(c *CSRF) {
+		c.cookieName = name
+		c.cookiePath = path
+		c.cookieDomain = domain
+		c.cookieSecure = secure
+		c.cookieSameSite = sameSite
+	}
+}
+
+// WithCSRFHeader sets the request/response header carrying the token, default X-CSRF-Token
+func WithCSRFHeader(header string) CSRFOption {
+	return func(c *CSRF) {
+		c.header = header
+	}
+}
+
+// WithCSRFFormField sets the form field carrying the token, default csrf_token
+func WithCSRFFormField(field string) CSRFOption {
+	return func(c *CSRF) {
+		c.formField = field
+	}
+}
+
+// WithCSRFTrustedOrigins accepts cross-origin unsafe requests from the given hosts, e.g. "admin.example.com"
+func WithCSRFTrustedOrigins(hosts ...string) CSRFOption {
+	return func(c *CSRF) {
+		c.trustedOrigins = append(c.trustedOrigins, hosts...)
+	}
+}
+
+// WithCSRFExemptPaths skips requests whose path equals or is under one of the prefixes
+func WithCSRFExemptPaths(prefixes ...string) CSRFOption {
+	return func(c *CSRF) {
+		c.exemptPrefixes = append(c.exemptPrefixes, prefixes...)
+	}
+}
+
+// WithCSRFExemptGroups skips routes registered in the groups, e.g. API groups using bearer tokens
+func WithCSRFExemptGroups(groups ...RouteGroup) CSRFOption {
+	return func(c *CSRF) {
+		c.exemptGroups = append(c.exemptGroups, groups...)
+	}
+}
+
+// WithCSRFSkipper skips requests for which skipper returns true
+func WithCSRFSkipper(skipper func(req *http.Request) bool) CSRFOption {
+	return func(c *CSRF) {
+		c.skipper = skipper
+	}
+}
+
+// NewCSRF creates a new CSRF with optional configuration
+func NewCSRF(opts ...CSRFOption) *CSRF {
+	c := &CSRF{
+		mode:           CSRFDoubleSubmit,
+		cookieName:     "csrf_token",
+		cookiePath:     "/",
+		cookieSameSite: http.SameSiteLaxMode,
+		header:         DefaultCSRFHeader,
+		formField:      DefaultCSRFField,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func (s *CSRF) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	if s.skip(req) {
+		ctx.Next()
+		return
+	}
+
+	secret, secretErr := s.loadSecret(ctx.Context(), res, req)
+	if secretErr != nil {
+		slog.ErrorContext(ctx.Context(), "load csrf secret failed", "err", secretErr)
+		RenderError(ctx.Context(), res, req, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if !isSafeMethod(req.Method) {
+		if err := s.checkOrigin(ctx.Context(), req); err != nil {
+			s.reject(ctx, res, req, err)
+			return
+		}
+		if !s.checkToken(req, secret) {
+			s.reject(ctx, res, req, ErrCSRFTokenInvalid)
+			return
+		}
+	}
+
+	token := maskCSRFToken(secret)
+	res.Header().Set(s.header, token)
+	addVary(res.Header(), "Cookie")
+	ctx.Update(context.WithValue(ctx.Context(), CSRFTokenKey{}, token))
+	ctx.Next()
+}
+
+func (s *CSRF) reject(ctx RequestContext, res http.ResponseWriter, req *http.Request, err error) {
+	slog.WarnContext(ctx.Context(), "csrf check failed", "method", req.Method, "path", req.URL.Path, "ip", clientIP(ctx.Context(), req), "err", err)
+	RenderError(ctx.Context(), res, req, http.StatusForbidden, err)
+}
+
+func (s *CSRF) skip(req *http.Request) bool {
+	if s.skipper != nil && s.skipper(req) {
+		return true
+	}
+
+	return matchPathPrefix(req.URL.Path, s.exemptPrefixes) || matchPathPrefix(req.URL.Path, groupRoutePrefixes(s.exemptGroups))
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case GET, HEAD, OPTIONS, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// loadSecret 读取当前token, 不存在时生成并保存
+func (s *CSRF) loadSecret(ctx context.Context, res http.ResponseWriter, req *http.Request) ([]byte, error) {
+	if s.mode == CSRFSynchronizer {
+		session, ok := GetSession(ctx)
+		if !ok {
+			return nil, errors.New("csrf: synchronizer mode requires the Sessions middleware")
+		}
+		if val, ok := session.Get(csrfSessionKey); ok {
+			if secret := decodeCSRFSecret(val); secret != nil {
+				return secret, nil
+			}
+		}
+
+		secret := newCSRFSecret()
+		session.Set(csrfSessionKey, base64.RawURLEncoding.EncodeToString(secret))
+		return secret, nil
+	}
+
+	if cookie, err := req.Cookie(s.cookieName); err == nil {
+		if secret := decodeCSRFSecret(cookie.Value); secret != nil {
+			return secret, nil
+		}
+	}
+
+	secret := newCSRFSecret()
+	http.SetCookie(res, &http.Cookie{
+		Name:     s.cookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(secret),
+		Path:     s.cookiePath,
+		Domain:   s.cookieDomain,
+		Secure:   s.cookieSecure,
+		HttpOnly: true,
+		SameSite: s.cookieSameSite,
+	})
+	return secret, nil
+}
+
+// checkOrigin 校验Origin, 没有Origin时HTTPS请求必须带同源Referer
+func (s *CSRF) checkOrigin(ctx context.Context, req *http.Request) error {
+	if origin := req.Header.Get("Origin"); origin != "" {
+		if origin == "null" || !s.trustedHost(ctx, req, origin) {
+			return ErrCSRFOriginMismatch
+		}
+		return nil
+	}
+
+	if referer := req.Header.Get("Referer"); referer != "" {
+		if !s.trustedHost(ctx, req, referer) {
+			return ErrCSRFOriginMismatch
+		}
+		return nil
+	}
+
+	if req.TLS != nil || requestScheme(ctx, req) == "https" {
+		return ErrCSRFOriginMismatch
+	}
+	return nil
+}
+
+func (s *CSRF) trustedHost(ctx context.Context, req *http.Request, rawURL string) bool {
+	urlVal, urlErr := url.Parse(rawURL)
+	if urlErr != nil || urlVal.Host == "" {
+		return false
+	}
+
+	host := strings.ToLower(urlVal.Host)
+	return host == strings.ToLower(requestHost(ctx, req)) || slices.ContainsFunc(s.trustedOrigins, func(val string) bool {
+		return strings.EqualFold(val, host)
+	})
+}
+
+func (s *CSRF) checkToken(req *http.Request, secret []byte) bool {
+	token := req.Header.Get(s.header)
+	if token == "" {
+		contentType := req.Header.Get("Content-Type")
+		if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") || strings.HasPrefix(contentType, "multipart/form-data") {
+			token = req.FormValue(s.formField)
+		}
+	}
+
+	submitted := unmaskCSRFToken(token)
+	return submitted != nil && subtle.ConstantTimeCompare(submitted, secret) == 1
+}
+
+func newCSRFSecret() []byte {
+	secret := make([]byte, csrfTokenSize)
+	_, _ = rand.Read(secret)
+	return secret
+}
+
+func decodeCSRFSecret(val any) []byte {
+	str, ok := val.(string)
+	if !ok {
+		return nil
+	}
+	secret, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil || len(secret) != csrfTokenSize {
+		return nil
+	}
+	return secret
+}
+
+// maskCSRFToken 每次输出随机掩码后的token, 防止BREACH类压缩旁路攻击
+func maskCSRFToken(secret []byte) string {
+	masked := make([]byte, csrfTokenSize*2)
+	_, _ = rand.Read(masked[:csrfTokenSize])
+	for idx := 0; idx < csrfTokenSize; idx++ {
+		masked[csrfTokenSize+idx] = masked[idx] ^ secret[idx]
+	}
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+func unmaskCSRFToken(token string) []byte {
+	masked, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(masked) != csrfTokenSize*2 {
+		return nil
+	}
+
+	secret := make([]byte, csrfTokenSize)
+	for idx := 0; idx < csrfTokenSize; idx++ {
+		secret[idx] = masked[idx] ^ masked[csrfTokenSize+idx]
+	}
+	return secret
+}
@@ -0,0 +1,132 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func okHandler(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+	_, _ = res.Write([]byte("ok"))
+}
+
+func TestCSRFDoubleSubmit(t *testing.T) {
+	csrf := NewCSRF()
+
+	w := serveWithMiddleware(csrf, okHandler, httptest.NewRequest(http.MethodGet, "http://example.com/form", nil))
+	token := w.Header().Get(DefaultCSRFHeader)
+	cookies := w.Result().Cookies()
+	if token == "" || len(cookies) != 1 {
+		t.Fatalf("safe request should issue token and cookie, header = %q, cookies = %v", token, cookies)
+	}
+
+	form := url.Values{DefaultCSRFField: {token}}
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/form", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", "http://example.com")
+	req.AddCookie(cookies[0])
+	if w := serveWithMiddleware(csrf, okHandler, req); w.Code != http.StatusOK {
+		t.Fatalf("valid form token status = %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "http://example.com/form", nil)
+	req.Header.Set(DefaultCSRFHeader, maskCSRFToken(newCSRFSecret()))
+	req.AddCookie(cookies[0])
+	if w := serveWithMiddleware(csrf, okHandler, req); w.Code != http.StatusForbidden {
+		t.Fatalf("wrong token status = %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "http://example.com/form", nil)
+	req.Header.Set(DefaultCSRFHeader, token)
+	req.Header.Set("Origin", "http://evil.example")
+	req.AddCookie(cookies[0])
+	if w := serveWithMiddleware(csrf, okHandler, req); w.Code != http.StatusForbidden {
+		t.Fatalf("cross origin status = %d", w.Code)
+	}
+}
+
+func TestCSRFSynchronizerWithSession(t *testing.T) {
+	store := NewMemorySessionStore(time.Hour, time.Hour)
+	defer store.Close()
+
+	registry := NewRouteRegistry()
+	api := NewRouteGroup(registry, "/api")
+	registry.AddHandler("/form", GET, okHandler)
+	registry.AddHandler("/form", POST, okHandler)
+	api.AddHandler("/items", POST, okHandler)
+
+	chains := NewMiddleWareChains()
+	chains.Append(NewSessions(store))
+	chains.Append(NewCSRF(WithCSRFMode(CSRFSynchronizer), WithCSRFExemptGroups(api), WithCSRFTrustedOrigins("admin.example.com")))
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		NewRequestContext(chains.GetHandlers(), registry, context.Background(), w, req).Run()
+		return w
+	}
+
+	w := serve(httptest.NewRequest(http.MethodGet, "http://example.com/form", nil))
+	token := w.Header().Get(DefaultCSRFHeader)
+	sessionCookie := w.Result().Cookies()[0]
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/form", nil)
+	req.Header.Set(DefaultCSRFHeader, token)
+	req.Header.Set("Referer", "https://admin.example.com/page")
+	req.AddCookie(sessionCookie)
+	if w := serve(req); w.Code != http.StatusOK {
+		t.Fatalf("synchronizer token status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "http://example.com/form", nil)
+	req.Header.Set(DefaultCSRFHeader, token)
+	if w := serve(req); w.Code != http.StatusForbidden {
+		t.Fatalf("token without session status = %d", w.Code)
+	}
+
+	if w := serve(httptest.NewRequest(http.MethodPost, "http://example.com/api/items", nil)); w.Code != http.StatusOK {
+		t.Fatalf("exempt group status = %d", w.Code)
+	}
+}
+
+func TestCSRFExemptGroupWithApiVersion(t *testing.T) {
+	registry := NewRouteRegistry()
+	registry.SetApiVersion("/api/v1")
+	hooks := NewRouteGroup(registry, "/hooks")
+	hooks.Group("/github").AddHandler("/push", POST, okHandler)
+	registry.AddHandler("/form", POST, okHandler)
+	registry.SetApiVersion("/api/v2")
+	hooks.AddHandler("/items", POST, okHandler)
+
+	chains := NewMiddleWareChains()
+	chains.Append(NewCSRF(WithCSRFExemptGroups(hooks)))
+	serve := func(target string) int {
+		w := httptest.NewRecorder()
+		NewRequestContext(chains.GetHandlers(), registry, context.Background(), w, httptest.NewRequest(http.MethodPost, target, nil)).Run()
+		return w.Code
+	}
+
+	for _, target := range []string{"http://example.com/api/v1/hooks/github/push", "http://example.com/api/v2/hooks/items"} {
+		if code := serve(target); code != http.StatusOK {
+			t.Errorf("exempt group route %s status = %d", target, code)
+		}
+	}
+	if code := serve("http://example.com/api/v1/form"); code != http.StatusForbidden {
+		t.Fatalf("route outside the group should be checked, status = %d", code)
+	}
+}
+
+func TestCSRFHTTPSRequiresOriginOrReferer(t *testing.T) {
+	csrf := NewCSRF()
+	w := serveWithMiddleware(csrf, okHandler, httptest.NewRequest(http.MethodGet, "https://example.com/", nil))
+	token := w.Header().Get(DefaultCSRFHeader)
+
+	req := httptest.NewRequest(http.MethodPost, "https://example.com/", nil)
+	req.Header.Set(DefaultCSRFHeader, token)
+	req.AddCookie(w.Result().Cookies()[0])
+	if w := serveWithMiddleware(csrf, okHandler, req); w.Code != http.StatusForbidden {
+		t.Fatalf("https request without origin status = %d", w.Code)
+	}
+}
@@ -0,0 +1,168 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ErrorPageData 错误页模板和JSON构造函数使用的数据
+type ErrorPageData struct {
+	Status     int
+	StatusText string
+	Message    string
+	RequestID  string
+	Path       string
+}
+
+type errorPage struct {
+	tmpl    *template.Template
+	content []byte
+	load    func() ([]byte, error)
+}
+
+// ErrorPages 按状态码和内容类型输出错误响应, 通过 SetErrorRenderer(pages.Render) 安装后,
+// 路由未命中、方法不允许、静态文件和 Recovery 输出的错误都会使用它
+//
+// 页面先按精确状态码查找, 再按整百状态码查找(如500覆盖全部5xx); 接受HTML的客户端输出模板或文件,
+// 接受JSON的客户端输出JSON, 没有匹配的页面时使用默认渲染.
+type ErrorPages struct {
+	pages map[int]*errorPage
+	jsons map[int]func(*ErrorPageData) any
+}
+
+// ErrorPagesOption configures an ErrorPages
+type ErrorPagesOption func(*ErrorPages)
+
+// WithErrorTemplate renders the page from an html/template executed with *ErrorPageData
+func WithErrorTemplate(status int, tmpl *template.Template) ErrorPagesOption {
+	return func(p *ErrorPages) {
+		p.pages[status] = &errorPage{tmpl: tmpl}
+	}
+}
+
+// WithErrorFile serves a static HTML file, it is read once when the ErrorPages is created
+func WithErrorFile(status int, filePath string) ErrorPagesOption {
+	return func(p *ErrorPages) {
+		p.pages[status] = &errorPage{load: func() ([]byte, error) { return os.ReadFile(filePath) }}
+	}
+}
+
+// WithErrorFS serves an HTML file from a file system such as embed.FS
+func WithErrorFS(status int, fsys fs.FS, name string) ErrorPagesOption {
+	return func(p *ErrorPages) {
+		p.pages[status] = &errorPage{load: func() ([]byte, error) { return fs.ReadFile(fsys, name) }}
+	}
+}
+
+// WithErrorJSON sets the JSON body for JSON clients, default {"status", "error", "request_id"}
+func WithErrorJSON(status int, body func(*ErrorPageData) any) ErrorPagesOption {
+	return func(p *ErrorPages) {
+		p.jsons[status] = body
+	}
+}
+
+// NewErrorPages creates a new ErrorPages, returns an error when a page file cannot be read
+func NewErrorPages(opts ...ErrorPagesOption) (*ErrorPages, error) {
+	p := &ErrorPages{
+		pages: map[int]*errorPage{},
+		jsons: map[int]func(*ErrorPageData) any{},
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	for status, page := range p.pages {
+		if page.load == nil {
+			continue
+		}
+		contentVal, contentErr := page.load()
+		if contentErr != nil {
+			return nil, fmt.Errorf("http: load error page %d: %w", status, contentErr)
+		}
+		page.content = contentVal
+	}
+
+	return p, nil
+}
+
+// lookupErrorPage 先按精确状态码, 再按整百状态码查找
+func lookupErrorPage[T any](items map[int]T, status int) (T, bool) {
+	if val, ok := items[status]; ok {
+		return val, true
+	}
+	val, ok := items[status-status%100]
+	return val, ok
+}
+
+// Render 实现 ErrorRenderer
+func (s *ErrorPages) Render(ctx context.Context, res http.ResponseWriter, req *http.Request, status int, err error) {
+	data := &ErrorPageData{
+		Status:     status,
+		StatusText: http.StatusText(status),
+		Message:    http.StatusText(status),
+		RequestID:  GetRequestID(ctx),
+	}
+	if err != nil {
+		data.Message = err.Error()
+	}
+	if req != nil {
+		data.Path = req.URL.Path
+	}
+
+	if acceptsJSON(req) {
+		if body, ok := lookupErrorPage(s.jsons, status); ok {
+			res.Header().Set("Content-Type", "application/json; charset=utf-8")
+			res.Header().Set("X-Content-Type-Options", "nosniff")
+			res.WriteHeader(status)
+			_ = json.NewEncoder(res).Encode(body(data))
+			return
+		}
+		defaultErrorRenderer(ctx, res, req, status, err)
+		return
+	}
+
+	if page, ok := lookupErrorPage(s.pages, status); ok && acceptsHTML(req) {
+		content, contentErr := page.render(data)
+		if contentErr == nil {
+			res.Header().Set("Content-Type", "text/html; charset=utf-8")
+			res.Header().Set("X-Content-Type-Options", "nosniff")
+			res.WriteHeader(status)
+			_, _ = res.Write(content)
+			return
+		}
+		slog.ErrorContext(ctx, "render error page failed", "status", status, "err", contentErr)
+	}
+
+	defaultErrorRenderer(ctx, res, req, status, err)
+}
+
+func (s *errorPage) render(data *ErrorPageData) ([]byte, error) {
+	if s.tmpl == nil {
+		return s.content, nil
+	}
+
+	// 先渲染到缓冲区, 模板出错时还可以输出默认错误
+	buf := &bytes.Buffer{}
+	if err := s.tmpl.Execute(buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func acceptsHTML(req *http.Request) bool {
+	if req == nil {
+		return false
+	}
+
+	accept := req.Header.Get("Accept")
+	return strings.Contains(accept, "text/html") || strings.Contains(accept, "application/xhtml+xml")
+}
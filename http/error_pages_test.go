@@ -0,0 +1,82 @@
+package http
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestErrorPages(t *testing.T) {
+	pagePath := filepath.Join(t.TempDir(), "5xx.html")
+	if err := os.WriteFile(pagePath, []byte("<h1>server error</h1>"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	pages, pagesErr := NewErrorPages(
+		WithErrorTemplate(http.StatusNotFound, template.Must(template.New("404").Parse("<p>{{.Path}} {{.StatusText}}</p>"))),
+		WithErrorFS(http.StatusMethodNotAllowed, fstest.MapFS{"405.html": {Data: []byte("<p>method</p>")}}, "405.html"),
+		WithErrorFile(http.StatusInternalServerError, pagePath),
+		WithErrorJSON(http.StatusNotFound, func(data *ErrorPageData) any {
+			return map[string]any{"code": "not_found", "path": data.Path}
+		}),
+	)
+	if pagesErr != nil {
+		t.Fatal(pagesErr)
+	}
+	SetErrorRenderer(pages.Render)
+	defer SetErrorRenderer(nil)
+
+	registry := NewRouteRegistry()
+	registry.AddHandler("/items/:id", GET, okHandler)
+	registry.AddHandler("/items/:id", PUT, okHandler)
+	registry.AddHandler("/boom", GET, func(context.Context, http.ResponseWriter, *http.Request) { panic("boom") })
+
+	serve := func(method, path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Accept", accept)
+		chains := NewMiddleWareChains()
+		chains.Append(NewRecovery())
+		w := httptest.NewRecorder()
+		NewRequestContext(chains.GetHandlers(), registry, context.Background(), w, req).Run()
+		return w
+	}
+
+	w := serve(http.MethodGet, "/missing", "text/html")
+	if w.Code != http.StatusNotFound || w.Body.String() != "<p>/missing Not Found</p>" {
+		t.Fatalf("unexpected 404 page %d %q", w.Code, w.Body.String())
+	}
+	if w = serve(http.MethodGet, "/missing", "application/json"); !strings.Contains(w.Body.String(), `"code":"not_found"`) {
+		t.Fatalf("unexpected 404 json %q", w.Body.String())
+	}
+	if w = serve(http.MethodGet, "/missing", "text/plain"); w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "<p>") {
+		t.Fatalf("plain client should get text, got %q", w.Body.String())
+	}
+
+	w = serve(http.MethodPost, "/items/1", "text/html")
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, PUT" || w.Body.String() != "<p>method</p>" {
+		t.Fatalf("unexpected 405 %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+
+	prevEnv := Env
+	Env = Prod
+	defer func() { Env = prevEnv }()
+	w = serve(http.MethodGet, "/boom", "text/html")
+	if w.Code != http.StatusInternalServerError || w.Body.String() != "<h1>server error</h1>" {
+		t.Fatalf("panic should render 5xx page, got %d %q", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	pages.Render(context.Background(), w, httptest.NewRequest(http.MethodGet, "/up", nil), http.StatusBadGateway, nil)
+	if w.Body.String() != "Bad Gateway\n" {
+		t.Fatalf("client without html accept should get text, got %q", w.Body.String())
+	}
+
+	if _, err := NewErrorPages(WithErrorFile(http.StatusNotFound, filepath.Join(t.TempDir(), "none.html"))); err == nil {
+		t.Fatal("missing page file should fail")
+	}
+}
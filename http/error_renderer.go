@@ -0,0 +1,113 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ErrorRenderer 统一输出中间件产生的错误响应
+type ErrorRenderer func(ctx context.Context, res http.ResponseWriter, req *http.Request, status int, err error)
+
+var (
+	errorRenderer     ErrorRenderer = defaultErrorRenderer
+	errorRendererLock sync.RWMutex
+)
+
+// SetErrorRenderer 设置全局错误渲染器, nil 表示恢复默认渲染器
+func SetErrorRenderer(renderer ErrorRenderer) {
+	errorRendererLock.Lock()
+	defer errorRendererLock.Unlock()
+
+	if renderer == nil {
+		renderer = defaultErrorRenderer
+	}
+	errorRenderer = renderer
+}
+
+// RenderError 使用全局错误渲染器输出错误响应
+func RenderError(ctx context.Context, res http.ResponseWriter, req *http.Request, status int, err error) {
+	errorRendererLock.RLock()
+	renderer := errorRenderer
+	errorRendererLock.RUnlock()
+
+	renderer(ctx, res, req, status, err)
+}
+
+type errorBody struct {
+	Status    int    `json:"status"`
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// defaultErrorRenderer 对接受JSON的客户端输出JSON, 其余输出纯文本; 5xx 的纯文本带请求ID
+func defaultErrorRenderer(ctx context.Context, res http.ResponseWriter, req *http.Request, status int, err error) {
+	message := http.StatusText(status)
+	if err != nil {
+		message = err.Error()
+	}
+	requestID := GetRequestID(ctx)
+
+	if acceptsJSON(req) {
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		res.Header().Set("X-Content-Type-Options", "nosniff")
+		res.WriteHeader(status)
+		_ = json.NewEncoder(res).Encode(&errorBody{Status: status, Error: message, RequestID: requestID})
+		return
+	}
+
+	if status >= http.StatusInternalServerError && requestID != "" {
+		message += "\nrequest id: " + requestID
+	}
+	http.Error(res, message, status)
+}
+
+// problemDetails RFC 9457 application/problem+json 响应体
+type problemDetails struct {
+	Type      string              `json:"type"`
+	Title     string              `json:"title"`
+	Status    int                 `json:"status"`
+	Detail    string              `json:"detail,omitempty"`
+	Instance  string              `json:"instance,omitempty"`
+	RequestID string              `json:"request_id,omitempty"`
+	Errors    []ValidationMessage `json:"errors,omitempty"`
+}
+
+func writeProblem(res http.ResponseWriter, problem *problemDetails) {
+	res.Header().Set("Content-Type", "application/problem+json")
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+	res.WriteHeader(problem.Status)
+	_ = json.NewEncoder(res).Encode(problem)
+}
+
+// ProblemErrorRenderer 按 RFC 9457 输出 application/problem+json, title 按请求语言本地化,
+// 可通过 SetErrorRenderer(ProblemErrorRenderer) 启用
+func ProblemErrorRenderer(ctx context.Context, res http.ResponseWriter, req *http.Request, status int, err error) {
+	problem := &problemDetails{
+		Type:      "about:blank",
+		Title:     ProblemTitle(ctx, status),
+		Status:    status,
+		RequestID: GetRequestID(ctx),
+	}
+	if err != nil {
+		problem.Detail = err.Error()
+	}
+	if req != nil {
+		problem.Instance = req.URL.Path
+	}
+	writeProblem(res, problem)
+}
+
+func acceptsJSON(req *http.Request) bool {
+	if req == nil {
+		return false
+	}
+
+	accept := req.Header.Get("Accept")
+	if strings.Contains(accept, "application/json") || strings.Contains(accept, "+json") {
+		return true
+	}
+	return accept == "" && strings.Contains(req.Header.Get("Content-Type"), "json")
+}
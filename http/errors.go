@@ -18,6 +18,15 @@ var (
 
 	// ErrEmptyFilePath is returned when an empty file path is provided
 	ErrEmptyFilePath = errors.New("empty file path")
+
+	// ErrRequestBodyTooLarge is returned when a request body exceeds the configured limit
+	ErrRequestBodyTooLarge = errors.New("http: request body too large")
+
+	// ErrDecompressionBomb is returned when a request body expands beyond the allowed ratio
+	ErrDecompressionBomb = errors.New("http: request body decompression ratio exceeded")
+
+	// ErrUnsupportedContentEncoding is returned when a request body uses an unknown Content-Encoding
+	ErrUnsupportedContentEncoding = errors.New("http: unsupported content encoding")
 )
 
 // StaticError represents an error with static file serving
@@ -0,0 +1,293 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultETagMaxSize 超过该大小的响应不计算ETag, 直接输出
+const DefaultETagMaxSize = 1 << 20
+
+// ErrPreconditionFailed is returned when If-Match or If-Unmodified-Since does not hold
+var ErrPreconditionFailed = errors.New("http: precondition failed")
+
+// ResourceVersionFunc 返回当前资源的版本, 资源不存在时exists为false
+//
+// etag为完整的实体标签, 如 `"v3"` 或 `W/"v3"`, 可以为空; lastModified为零值表示未知.
+type ResourceVersionFunc func(ctx context.Context, req *http.Request) (etag string, lastModified time.Time, exists bool)
+
+// FormatETag 把版本号格式化为实体标签
+func FormatETag(version string, weak bool) string {
+	tag := `"` + strings.ReplaceAll(version, `"`, "") + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// ETag 为动态响应计算ETag并处理条件请求
+//
+// GET/HEAD的200响应先缓冲, 由响应体或handler设置的ETag头得到实体标签, 命中If-None-Match时返回304.
+// 配置ResourceVersionFunc后, 执行handler前检查If-Match/If-Unmodified-Since, 不满足时返回412,
+// 用于PUT/PATCH/DELETE的乐观并发控制; GET/HEAD命中时不执行handler直接返回304.
+type ETag struct {
+	weak        bool
+	maxSize     int
+	versionFunc ResourceVersionFunc
+}
+
+// ETagOption configures an ETag
+type ETagOption func(*ETag)
+
+// WithWeakETag generates weak ETags, use it when equivalent bodies may differ byte by byte
+func WithWeakETag(weak bool) ETagOption {
+	return func(e *ETag) {
+		e.weak = weak
+	}
+}
+
+// WithETagMaxSize sets the largest body buffered for hashing, default DefaultETagMaxSize
+func WithETagMaxSize(size int) ETagOption {
+	return func(e *ETag) {
+		e.maxSize = size
+	}
+}
+
+// WithResourceVersion evaluates preconditions against the current resource version before the handler runs
+func WithResourceVersion(versionFunc ResourceVersionFunc) ETagOption {
+	return func(e *ETag) {
+		e.versionFunc = versionFunc
+	}
+}
+
+// NewETag creates a new ETag with optional configuration
+func NewETag(opts ...ETagOption) *ETag {
+	e := &ETag{maxSize: DefaultETagMaxSize}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+func (s *ETag) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	if s.versionFunc != nil {
+		etag, lastModified, exists := s.versionFunc(ctx.Context(), req)
+		if !CheckPreconditions(ctx.Context(), res, req, etag, lastModified, exists) {
+			return
+		}
+		if exists && (req.Method == GET || req.Method == HEAD) && notModified(req, etag, lastModified) {
+			writeNotModified(res, etag, lastModified)
+			return
+		}
+	}
+
+	if req.Method != GET && req.Method != HEAD {
+		ctx.Next()
+		return
+	}
+
+	ew := &etagWriter{ResponseWriter: res, etag: s, req: req}
+	restore, ok := replaceResponseWriter(ctx, ew)
+	if !ok {
+		ctx.Next()
+		return
+	}
+	defer restore()
+
+	ctx.Next()
+	ew.finish()
+}
+
+// CheckPreconditions 检查If-Match、If-Unmodified-Since以及非GET/HEAD请求的If-None-Match, 不满足时输出412并返回false
+//
+// 供自行加载资源的handler在修改前调用, exists表示资源当前是否存在.
+func CheckPreconditions(ctx context.Context, res http.ResponseWriter, req *http.Request, etag string, lastModified time.Time, exists bool) bool {
+	if preconditionHolds(req, etag, lastModified, exists) {
+		return true
+	}
+
+	if etag != "" {
+		res.Header().Set("ETag", etag)
+	}
+	RenderError(ctx, res, req, http.StatusPreconditionFailed, ErrPreconditionFailed)
+	return false
+}
+
+func preconditionHolds(req *http.Request, etag string, lastModified time.Time, exists bool) bool {
+	if ifMatch := req.Header.Get("If-Match"); ifMatch != "" {
+		if !exists {
+			return false
+		}
+		if strings.TrimSpace(ifMatch) == "*" {
+			return true
+		}
+		return etag != "" && matchETag(ifMatch, etag, false)
+	}
+
+	if since := req.Header.Get("If-Unmodified-Since"); since != "" && exists && !lastModified.IsZero() {
+		sinceTime, sinceErr := http.ParseTime(since)
+		if sinceErr == nil && lastModified.Truncate(time.Second).After(sinceTime) {
+			return false
+		}
+	}
+
+	// 非GET/HEAD请求的If-None-Match命中时同样不满足, 如 "If-None-Match: *" 表示仅在资源不存在时创建
+	if req.Method != GET && req.Method != HEAD && exists {
+		if ifNoneMatch := req.Header.Get("If-None-Match"); ifNoneMatch != "" {
+			return strings.TrimSpace(ifNoneMatch) != "*" && (etag == "" || !matchETag(ifNoneMatch, etag, true))
+		}
+	}
+	return true
+}
+
+// notModified 判断GET/HEAD请求是否可以返回304, If-None-Match优先于If-Modified-Since
+func notModified(req *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := req.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if strings.TrimSpace(ifNoneMatch) == "*" {
+			return true
+		}
+		return etag != "" && matchETag(ifNoneMatch, etag, true)
+	}
+
+	if since := req.Header.Get("If-Modified-Since"); since != "" && !lastModified.IsZero() {
+		sinceTime, sinceErr := http.ParseTime(since)
+		return sinceErr == nil && !lastModified.Truncate(time.Second).After(sinceTime)
+	}
+	return false
+}
+
+// matchETag 判断逗号分隔的实体标签列表是否包含etag, weak为true时使用弱比较
+func matchETag(list, etag string, weak bool) bool {
+	etagWeak, etagOpaque := splitETag(etag)
+	if !weak && etagWeak {
+		return false
+	}
+
+	for _, val := range strings.Split(list, ",") {
+		valWeak, valOpaque := splitETag(strings.TrimSpace(val))
+		if valOpaque == "" || valOpaque != etagOpaque {
+			continue
+		}
+		if weak || !valWeak {
+			return true
+		}
+	}
+	return false
+}
+
+func splitETag(etag string) (bool, string) {
+	weak := strings.HasPrefix(etag, "W/")
+	etag = strings.TrimPrefix(etag, "W/")
+	if len(etag) < 2 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+		return weak, ""
+	}
+	return weak, etag
+}
+
+func writeNotModified(res http.ResponseWriter, etag string, lastModified time.Time) {
+	header := res.Header()
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	if etag != "" {
+		header.Set("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	res.WriteHeader(http.StatusNotModified)
+}
+
+// etagWriter 缓冲200响应用于计算ETag, 超过上限或Flush时改为直接输出
+type etagWriter struct {
+	http.ResponseWriter
+	etag        *ETag
+	req         *http.Request
+	buffer      bytes.Buffer
+	status      int
+	passThrough bool
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = code
+	if code != http.StatusOK {
+		w.startPassThrough()
+	}
+}
+
+func (w *etagWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passThrough {
+		return w.ResponseWriter.Write(data)
+	}
+
+	if w.buffer.Len()+len(data) > w.etag.maxSize {
+		w.startPassThrough()
+		return w.ResponseWriter.Write(data)
+	}
+	return w.buffer.Write(data)
+}
+
+func (w *etagWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.startPassThrough()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *etagWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// startPassThrough 放弃计算ETag, 写出响应头和已缓冲的数据
+func (w *etagWriter) startPassThrough() {
+	if w.passThrough {
+		return
+	}
+	w.passThrough = true
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buffer.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buffer.Bytes())
+		w.buffer.Reset()
+	}
+}
+
+func (w *etagWriter) finish() {
+	if w.passThrough || w.status == 0 {
+		return
+	}
+
+	header := w.Header()
+	etag := header.Get("ETag")
+	if etag == "" {
+		sum := sha256.Sum256(w.buffer.Bytes())
+		etag = FormatETag(base64.RawURLEncoding.EncodeToString(sum[:16]), w.etag.weak)
+		header.Set("ETag", etag)
+	}
+
+	lastModified, _ := http.ParseTime(header.Get("Last-Modified"))
+	if notModified(w.req, etag, lastModified) {
+		writeNotModified(w.ResponseWriter, etag, lastModified)
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.req.Method != HEAD {
+		_, _ = w.ResponseWriter.Write(w.buffer.Bytes())
+	}
+}
@@ -0,0 +1,123 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func jsonHandler(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+	_, _ = res.Write([]byte(`{"id":1,"name":"demo"}`))
+}
+
+func TestETagGenerated(t *testing.T) {
+	etag := NewETag()
+
+	w := serveWithMiddleware(etag, jsonHandler, httptest.NewRequest(http.MethodGet, "http://example.com/item", nil))
+	tag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || !strings.HasPrefix(tag, `"`) || w.Body.String() != `{"id":1,"name":"demo"}` {
+		t.Fatalf("unexpected response %d %q %q", w.Code, tag, w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/item", nil)
+	req.Header.Set("If-None-Match", `"other", W/`+tag)
+	w = serveWithMiddleware(etag, jsonHandler, req)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("ETag") != tag || w.Header().Get("Content-Type") != "" {
+		t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+	}
+
+	weak := NewETag(WithWeakETag(true))
+	w = serveWithMiddleware(weak, jsonHandler, httptest.NewRequest(http.MethodGet, "http://example.com/item", nil))
+	if w.Header().Get("ETag") != "W/"+tag {
+		t.Fatalf("unexpected weak etag %q", w.Header().Get("ETag"))
+	}
+}
+
+func TestETagHandlerVersionAndPassThrough(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/item", nil)
+	req.Header.Set("If-None-Match", `"v2"`)
+	w := serveWithMiddleware(NewETag(), func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("ETag", FormatETag("v2", false))
+		jsonHandler(ctx, res, req)
+	}, req)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("handler provided etag should be used, got %d", w.Code)
+	}
+
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	req = httptest.NewRequest(http.MethodGet, "http://example.com/item", nil)
+	req.Header.Set("If-Modified-Since", modified.Format(http.TimeFormat))
+	w = serveWithMiddleware(NewETag(), func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		jsonHandler(ctx, res, req)
+	}, req)
+	if w.Code != http.StatusNotModified || w.Header().Get("Last-Modified") != modified.Format(http.TimeFormat) {
+		t.Fatalf("304 should keep Last-Modified, got %d %v", w.Code, w.Header())
+	}
+
+	// 超过缓冲上限的响应直接输出, 不计算ETag
+	etag := NewETag(WithETagMaxSize(8))
+	w = serveWithMiddleware(etag, jsonHandler, httptest.NewRequest(http.MethodGet, "http://example.com/item", nil))
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != "" || w.Body.String() != `{"id":1,"name":"demo"}` {
+		t.Fatalf("unexpected response %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+
+	w = serveWithMiddleware(etag, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		http.Error(res, "missing", http.StatusNotFound)
+	}, httptest.NewRequest(http.MethodGet, "http://example.com/item", nil))
+	if w.Code != http.StatusNotFound || w.Header().Get("ETag") != "" {
+		t.Fatalf("non 200 response should not carry etag, got %d", w.Code)
+	}
+}
+
+func TestETagPreconditions(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	etag := NewETag(WithResourceVersion(func(ctx context.Context, req *http.Request) (string, time.Time, bool) {
+		return FormatETag("v3", false), modified, true
+	}))
+
+	cases := []struct {
+		method string
+		header string
+		value  string
+		status int
+	}{
+		{http.MethodPut, "If-Match", `"v3"`, http.StatusOK},
+		{http.MethodPut, "If-Match", `"v2"`, http.StatusPreconditionFailed},
+		{http.MethodDelete, "If-Match", `W/"v3"`, http.StatusPreconditionFailed},
+		{http.MethodDelete, "If-Match", "*", http.StatusOK},
+		{http.MethodPut, "If-Unmodified-Since", modified.Format(http.TimeFormat), http.StatusOK},
+		{http.MethodPut, "If-Unmodified-Since", modified.Add(-time.Hour).Format(http.TimeFormat), http.StatusPreconditionFailed},
+		{http.MethodPut, "If-None-Match", "*", http.StatusPreconditionFailed},
+		{http.MethodGet, "If-None-Match", `"v3"`, http.StatusNotModified},
+		{http.MethodGet, "If-Modified-Since", modified.Format(http.TimeFormat), http.StatusNotModified},
+		{http.MethodGet, "If-Modified-Since", modified.Add(-time.Hour).Format(http.TimeFormat), http.StatusOK},
+	}
+	for _, val := range cases {
+		req := httptest.NewRequest(val.method, "http://example.com/item", nil)
+		req.Header.Set(val.header, val.value)
+		w := serveWithMiddleware(etag, okHandler, req)
+		if w.Code != val.status {
+			t.Errorf("%s %s: %s got %d, want %d", val.method, val.header, val.value, w.Code, val.status)
+		}
+	}
+}
+
+func TestCheckPreconditionsMissingResource(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "http://example.com/item", nil)
+	req.Header.Set("If-Match", "*")
+	w := httptest.NewRecorder()
+	if CheckPreconditions(context.Background(), w, req, "", time.Time{}, false) || w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("If-Match on missing resource should fail, got %d", w.Code)
+	}
+
+	req.Header.Del("If-Match")
+	req.Header.Set("If-None-Match", "*")
+	if !CheckPreconditions(context.Background(), httptest.NewRecorder(), req, "", time.Time{}, false) {
+		t.Fatal("If-None-Match * on missing resource should hold")
+	}
+}
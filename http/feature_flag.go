@@ -0,0 +1,215 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrFeatureDisabled is returned when a route is gated by a disabled feature flag
+var ErrFeatureDisabled = errors.New("http: feature disabled")
+
+// FeatureRule 特性开关规则, 每个请求按 Header、Enabled、Users、Percentage 的顺序判断
+type FeatureRule struct {
+	// Enabled 对全部请求开启
+	Enabled bool `json:"enabled"`
+	// Header 非空时, 请求头取值为 on/true/1 强制开启, off/false/0 强制关闭, 只应用于内部测试;
+	// 只有来自 SetHeaderOverrideNetworks 网段的客户端生效, 默认不对任何客户端生效
+	Header string `json:"header,omitempty"`
+	// Users 对这些已认证身份(Principal.ID)开启
+	Users []string `json:"users,omitempty"`
+	// Percentage 按身份(未认证时客户端IP)哈希灰度开启的百分比, 0-100, 同一调用方结果稳定
+	Percentage int `json:"percentage,omitempty"`
+}
+
+// FeatureFlags 特性开关集合, 运行时通过 Set 或管理路由修改, 无需重新部署
+type FeatureFlags struct {
+	mu           sync.RWMutex
+	rules        map[string]FeatureRule
+	overrideNets *CIDRSet
+	key          RateLimitKeyFunc
+}
+
+// NewFeatureFlags 新建特性开关集合
+func NewFeatureFlags() *FeatureFlags {
+	return &FeatureFlags{rules: map[string]FeatureRule{}, key: KeyByPrincipal()}
+}
+
+var defaultFeatureFlags = NewFeatureFlags()
+
+// DefaultFeatureFlags 默认特性开关集合
+func DefaultFeatureFlags() *FeatureFlags {
+	return defaultFeatureFlags
+}
+
+// Set 设置开关规则
+func (s *FeatureFlags) Set(name string, rule FeatureRule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule.Users = slices.Clone(rule.Users)
+	s.rules[name] = rule
+}
+
+// Delete 删除开关, 未定义的开关视为关闭
+func (s *FeatureFlags) Delete(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.rules, name)
+}
+
+// SetHeaderOverrideNetworks 设置允许通过 FeatureRule.Header 覆盖开关的客户端网段, 为空时关闭覆盖;
+// 网段非法时返回错误且保持原设置
+func (s *FeatureFlags) SetHeaderOverrideNetworks(cidrs ...string) error {
+	overrideNets, overrideErr := NewCIDRSet(cidrs...)
+	if overrideErr != nil {
+		return overrideErr
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.overrideNets = overrideNets
+	return nil
+}
+
+// Rules 返回全部开关规则的副本
+func (s *FeatureFlags) Rules() map[string]FeatureRule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return maps.Clone(s.rules)
+}
+
+// Enabled 按请求判断开关是否开启
+func (s *FeatureFlags) Enabled(ctx context.Context, req *http.Request, name string) bool {
+	s.mu.RLock()
+	rule, ok := s.rules[name]
+	overrideNets := s.overrideNets
+	s.mu.RUnlock()
+	if !ok {
+		return false
+	}
+
+	if rule.Header != "" && req != nil && canOverrideFeature(ctx, req, overrideNets) {
+		switch strings.ToLower(strings.TrimSpace(req.Header.Get(rule.Header))) {
+		case "on", "true", "1":
+			return true
+		case "off", "false", "0":
+			return false
+		}
+	}
+	if rule.Enabled {
+		return true
+	}
+	if principal, principalOK := GetPrincipal(ctx); principalOK && slices.Contains(rule.Users, principal.ID) {
+		return true
+	}
+	if rule.Percentage <= 0 || req == nil {
+		return false
+	}
+	return rolloutBucket(name, s.key(ctx, req)) < rule.Percentage
+}
+
+// canOverrideFeature 只有受信网段的客户端可以通过请求头覆盖开关
+func canOverrideFeature(ctx context.Context, req *http.Request, overrideNets *CIDRSet) bool {
+	if overrideNets == nil || overrideNets.Len() == 0 {
+		return false
+	}
+	addr, ok := parseHopAddr(clientIP(ctx, req))
+	return ok && overrideNets.Contains(addr)
+}
+
+// rolloutBucket 把调用方稳定地映射到 0-99 的桶, 开关名参与哈希使不同开关的灰度人群相互独立
+func rolloutBucket(name, key string) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(name + "\x00" + key))
+	return int(hash.Sum32() % 100)
+}
+
+// FeatureGate 特性开关中间件, 开关关闭时路由表现为不存在(默认404)
+type FeatureGate struct {
+	flags  *FeatureFlags
+	name   string
+	status int
+}
+
+// FeatureGateOption configures a FeatureGate
+type FeatureGateOption func(*FeatureGate)
+
+// WithFeatureFlags sets the flag set, default DefaultFeatureFlags
+func WithFeatureFlags(flags *FeatureFlags) FeatureGateOption {
+	return func(g *FeatureGate) {
+		g.flags = flags
+	}
+}
+
+// WithFeatureGateStatus sets the status returned when the flag is off, default 404
+func WithFeatureGateStatus(status int) FeatureGateOption {
+	return func(g *FeatureGate) {
+		g.status = status
+	}
+}
+
+// NewFeatureGate creates a middleware gating routes by the named flag
+func NewFeatureGate(name string, opts ...FeatureGateOption) *FeatureGate {
+	g := &FeatureGate{
+		flags:  DefaultFeatureFlags(),
+		name:   name,
+		status: http.StatusNotFound,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+func (s *FeatureGate) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	if s.flags.Enabled(ctx.Context(), req, s.name) {
+		ctx.Next()
+		return
+	}
+
+	err := ErrFeatureDisabled
+	if s.status == http.StatusNotFound {
+		err = ErrURLNotFound
+	}
+	RenderError(ctx.Context(), res, req, s.status, err)
+}
+
+// CreateFeatureFlagRoute 创建特性开关管理路由, GET 返回全部规则, 其他方法按请求体
+// {"name": FeatureRule, ...} 设置规则, 取值为 null 时删除; 需要自行挂载鉴权中间件
+func CreateFeatureFlagRoute(uriPattern, method string, flags *FeatureFlags) Route {
+	if flags == nil {
+		flags = DefaultFeatureFlags()
+	}
+
+	return CreateRoute(uriPattern, method, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			rules := map[string]*FeatureRule{}
+			if err := json.NewDecoder(io.LimitReader(req.Body, 1<<20)).Decode(&rules); err != nil {
+				RenderError(ctx, res, req, http.StatusBadRequest, err)
+				return
+			}
+			for name, rule := range rules {
+				if rule == nil {
+					flags.Delete(name)
+					continue
+				}
+				flags.Set(name, *rule)
+			}
+		}
+
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		res.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(res).Encode(flags.Rules())
+	})
+}
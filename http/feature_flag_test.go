@@ -0,0 +1,86 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFeatureFlagsEnabled(t *testing.T) {
+	flags := NewFeatureFlags()
+	flags.Set("checkout", FeatureRule{Header: "X-Feature-Checkout", Users: []string{"alice"}})
+
+	req := httptest.NewRequest(http.MethodGet, "/checkout", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	ctx := context.Background()
+	if flags.Enabled(ctx, req, "checkout") || flags.Enabled(ctx, req, "unknown") {
+		t.Fatal("flag should be off by default")
+	}
+	if !flags.Enabled(WithPrincipal(ctx, &Principal{ID: "alice"}), req, "checkout") {
+		t.Fatal("listed user should be enabled")
+	}
+
+	req.Header.Set("X-Feature-Checkout", "on")
+	if flags.Enabled(ctx, req, "checkout") {
+		t.Fatal("header should be ignored without trusted networks")
+	}
+	if err := flags.SetHeaderOverrideNetworks("bad-cidr"); err == nil {
+		t.Fatal("illegal network should be rejected")
+	}
+	if err := flags.SetHeaderOverrideNetworks("10.0.0.0/8"); err != nil {
+		t.Fatalf("set override networks failed, err: %v", err)
+	}
+	if flags.Enabled(ctx, req, "checkout") {
+		t.Fatal("header from untrusted client should be ignored")
+	}
+	req.RemoteAddr = "10.1.2.3:1234"
+	if !flags.Enabled(ctx, req, "checkout") {
+		t.Fatal("header from trusted client should force enable")
+	}
+	req.Header.Set("X-Feature-Checkout", "off")
+	if flags.Enabled(WithPrincipal(ctx, &Principal{ID: "alice"}), req, "checkout") {
+		t.Fatal("header from trusted client should force disable")
+	}
+	req.RemoteAddr = "192.0.2.1:1234"
+
+	flags.Set("rollout", FeatureRule{Percentage: 30})
+	enabled := 0
+	for idx := range 1000 {
+		userCtx := WithPrincipal(ctx, &Principal{ID: fmt.Sprintf("user-%d", idx)})
+		if flags.Enabled(userCtx, req, "rollout") {
+			enabled++
+			if !flags.Enabled(userCtx, req, "rollout") {
+				t.Fatal("rollout should be stable per caller")
+			}
+		}
+	}
+	if enabled < 250 || enabled > 350 {
+		t.Fatalf("rollout should enable about 30%%, got %d", enabled)
+	}
+}
+
+func TestFeatureGate(t *testing.T) {
+	flags := NewFeatureFlags()
+	gate := NewFeatureGate("beta", WithFeatureFlags(flags))
+
+	if w := serveWithMiddleware(gate, okHandler, httptest.NewRequest(http.MethodGet, "/beta", nil)); w.Code != http.StatusNotFound {
+		t.Fatalf("disabled feature should hide route, got %d", w.Code)
+	}
+
+	route := CreateFeatureFlagRoute("/admin/features", PUT, flags)
+	req := httptest.NewRequest(http.MethodPut, "/admin/features", strings.NewReader(`{"beta":{"enabled":true}}`))
+	route.Handler()(req.Context(), httptest.NewRecorder(), req)
+
+	if w := serveWithMiddleware(gate, okHandler, httptest.NewRequest(http.MethodGet, "/beta", nil)); w.Code != http.StatusOK {
+		t.Fatalf("enabled feature should pass, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/admin/features", strings.NewReader(`{"beta":null}`))
+	route.Handler()(req.Context(), httptest.NewRecorder(), req)
+	if len(flags.Rules()) != 0 {
+		t.Fatal("null rule should delete the flag")
+	}
+}
@@ -0,0 +1,252 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+	"unicode/utf8"
+)
+
+// HAR 1.2 的最小子集, 只包含记录和重放需要的字段
+// http://www.softwareishard.com/blog/har-12-spec/
+
+type harFile struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+
+	// 自定义字段按HAR规范以下划线开头
+	RequestID string `json:"_requestId,omitempty"`
+	Route     string `json:"_route,omitempty"`
+	ClientIP  string `json:"_clientIp,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+	Truncated   bool           `json:"_truncated,omitempty"`
+	Unredacted  bool           `json:"_unredacted,omitempty"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+	Truncated   bool           `json:"_truncated,omitempty"`
+	Unredacted  bool           `json:"_unredacted,omitempty"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"_encoding,omitempty"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// WriteHAR 把记录输出为HAR 1.2文件
+func WriteHAR(w io.Writer, records []*RecordedExchange) error {
+	har := &harFile{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: serverName, Version: "1.0"},
+		Entries: make([]harEntry, 0, len(records)),
+	}}
+	for _, record := range records {
+		har.Log.Entries = append(har.Log.Entries, toHAREntry(record))
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(har)
+}
+
+// ReadHAR 读取HAR文件, 可以是 WriteHAR 导出的文件, 也可以是浏览器导出的文件
+func ReadHAR(r io.Reader) ([]*RecordedExchange, error) {
+	har := &harFile{}
+	if err := json.NewDecoder(r).Decode(har); err != nil {
+		return nil, err
+	}
+
+	records := make([]*RecordedExchange, 0, len(har.Log.Entries))
+	for idx := range har.Log.Entries {
+		record, recordErr := fromHAREntry(&har.Log.Entries[idx])
+		if recordErr != nil {
+			return nil, recordErr
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func toHAREntry(record *RecordedExchange) harEntry {
+	elapsed := float64(record.Duration) / float64(time.Millisecond)
+	entry := harEntry{
+		StartedDateTime: record.StartedAt,
+		Time:            elapsed,
+		RequestID:       record.RequestID,
+		Route:           record.Route,
+		ClientIP:        record.ClientIP,
+		Timings:         harTimings{Wait: elapsed},
+		Request: harRequest{
+			Method:      record.Method,
+			URL:         record.URL,
+			HTTPVersion: record.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     toHARHeaders(record.RequestHeader),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(record.RequestBody),
+			Truncated:   record.RequestBodyTruncated,
+			Unredacted:  record.RequestBodyUnredacted,
+		},
+		Response: harResponse{
+			Status:      record.Status,
+			StatusText:  http.StatusText(record.Status),
+			HTTPVersion: record.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     toHARHeaders(record.ResponseHeader),
+			Content:     harContent{Size: record.ResponseSize, MimeType: record.ResponseHeader.Get("Content-Type")},
+			RedirectURL: record.ResponseHeader.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    record.ResponseSize,
+			Truncated:   record.ResponseBodyTruncated,
+			Unredacted:  record.ResponseBodyUnredacted,
+		},
+	}
+
+	if urlVal, urlErr := url.Parse(record.URL); urlErr == nil {
+		query := urlVal.Query()
+		for _, key := range slices.Sorted(maps.Keys(query)) {
+			for _, val := range query[key] {
+				entry.Request.QueryString = append(entry.Request.QueryString, harNameValue{Name: key, Value: val})
+			}
+		}
+	}
+	if len(record.RequestBody) > 0 {
+		text, encoding := encodeHARBody(record.RequestBody)
+		entry.Request.PostData = &harPostData{MimeType: record.RequestHeader.Get("Content-Type"), Text: text, Encoding: encoding}
+	}
+	entry.Response.Content.Text, entry.Response.Content.Encoding = encodeHARBody(record.ResponseBody)
+	return entry
+}
+
+func fromHAREntry(entry *harEntry) (*RecordedExchange, error) {
+	record := &RecordedExchange{
+		StartedAt:              entry.StartedDateTime,
+		Duration:               time.Duration(entry.Time * float64(time.Millisecond)),
+		RequestID:              entry.RequestID,
+		ClientIP:               entry.ClientIP,
+		Route:                  entry.Route,
+		Method:                 entry.Request.Method,
+		URL:                    entry.Request.URL,
+		Proto:                  entry.Request.HTTPVersion,
+		RequestHeader:          fromHARHeaders(entry.Request.Headers),
+		RequestBodyTruncated:   entry.Request.Truncated,
+		RequestBodyUnredacted:  entry.Request.Unredacted,
+		Status:                 entry.Response.Status,
+		ResponseHeader:         fromHARHeaders(entry.Response.Headers),
+		ResponseBodyTruncated:  entry.Response.Truncated,
+		ResponseBodyUnredacted: entry.Response.Unredacted,
+		ResponseSize:           entry.Response.Content.Size,
+	}
+
+	var err error
+	if entry.Request.PostData != nil {
+		if record.RequestBody, err = decodeHARBody(entry.Request.PostData.Text, entry.Request.PostData.Encoding); err != nil {
+			return nil, err
+		}
+	}
+	if record.ResponseBody, err = decodeHARBody(entry.Response.Content.Text, entry.Response.Content.Encoding); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func toHARHeaders(header http.Header) []harNameValue {
+	ret := []harNameValue{}
+	for _, key := range slices.Sorted(maps.Keys(header)) {
+		for _, val := range header[key] {
+			ret = append(ret, harNameValue{Name: key, Value: val})
+		}
+	}
+	return ret
+}
+
+func fromHARHeaders(items []harNameValue) http.Header {
+	header := http.Header{}
+	for _, val := range items {
+		header.Add(val.Name, val.Value)
+	}
+	return header
+}
+
+// encodeHARBody 文本按原样输出, 二进制内容使用base64
+func encodeHARBody(content []byte) (string, string) {
+	if utf8.Valid(content) {
+		return string(content), ""
+	}
+	return base64.StdEncoding.EncodeToString(content), "base64"
+}
+
+func decodeHARBody(text, encoding string) ([]byte, error) {
+	if text == "" {
+		return nil, nil
+	}
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(text)
+	}
+	return []byte(text), nil
+}
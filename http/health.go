@@ -0,0 +1,311 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultHealthCheckTimeout = 2 * time.Second
+
+// 健康检查结果状态
+const (
+	HealthPass     = "pass"
+	HealthWarn     = "warn"
+	HealthFail     = "fail"
+	HealthDraining = "draining"
+)
+
+// ErrHealthCheckTimeout is reported when a checker does not finish within its timeout
+var ErrHealthCheckTimeout = errors.New("http: health check timed out")
+
+// HealthCheckFunc 健康检查函数, 返回nil表示正常, 需要响应ctx取消
+type HealthCheckFunc func(ctx context.Context) error
+
+// Drainer 可以进入排空状态的子系统, 如 sse.HolderRegistry、tcp.Server
+type Drainer interface {
+	Draining() bool
+}
+
+type healthCheck struct {
+	name     string
+	check    HealthCheckFunc
+	timeout  time.Duration
+	critical bool
+	liveness bool
+	failing  atomic.Bool
+}
+
+// HealthCheckOption configures a registered checker
+type HealthCheckOption func(*healthCheck)
+
+// WithHealthTimeout sets the checker timeout, default DefaultHealthCheckTimeout
+func WithHealthTimeout(timeout time.Duration) HealthCheckOption {
+	return func(c *healthCheck) {
+		c.timeout = timeout
+	}
+}
+
+// WithHealthCritical marks whether a failure makes the service unavailable, default true
+//
+// 非关键检查失败时整体状态为 warn, 仍返回200.
+func WithHealthCritical(critical bool) HealthCheckOption {
+	return func(c *healthCheck) {
+		c.critical = critical
+	}
+}
+
+// WithHealthLiveness also runs the checker in /livez, only for failures a restart can fix
+func WithHealthLiveness(liveness bool) HealthCheckOption {
+	return func(c *healthCheck) {
+		c.liveness = liveness
+	}
+}
+
+// HealthCheckResult 单个检查的结果
+type HealthCheckResult struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// HealthReport 健康检查报告, Status 为 pass、warn、fail 或 draining
+type HealthReport struct {
+	Status   string                        `json:"status"`
+	Draining []string                      `json:"draining,omitempty"`
+	Checks   map[string]*HealthCheckResult `json:"checks,omitempty"`
+}
+
+// StatusCode 报告对应的HTTP状态码, fail 和 draining 返回503
+func (s *HealthReport) StatusCode() int {
+	if s.Status == HealthFail || s.Status == HealthDraining {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// HealthRegistry 健康检查注册表
+//
+// 组件注册带超时和关键性的检查函数; 服务关闭或任一注册的Drainer排空时就绪检查返回 draining.
+type HealthRegistry struct {
+	mu       sync.RWMutex
+	checks   []*healthCheck
+	drainers map[string]Drainer
+	draining atomic.Bool
+}
+
+// NewHealthRegistry 新建健康检查注册表
+func NewHealthRegistry() *HealthRegistry {
+	return &HealthRegistry{drainers: map[string]Drainer{}}
+}
+
+var defaultHealthRegistry = NewHealthRegistry()
+
+// DefaultHealthRegistry 默认健康检查注册表, 通过 WithHealthRegistry 传给 HTTPServer 后关闭时会把它置为排空
+func DefaultHealthRegistry() *HealthRegistry {
+	return defaultHealthRegistry
+}
+
+// Register 注册检查函数, 同名检查会被替换
+func (s *HealthRegistry) Register(name string, check HealthCheckFunc, opts ...HealthCheckOption) {
+	item := &healthCheck{
+		name:     name,
+		check:    check,
+		timeout:  DefaultHealthCheckTimeout,
+		critical: true,
+	}
+	for _, opt := range opts {
+		opt(item)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checks = slices.DeleteFunc(s.checks, func(val *healthCheck) bool { return val.name == name })
+	s.checks = append(s.checks, item)
+}
+
+// Unregister 删除检查函数
+func (s *HealthRegistry) Unregister(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checks = slices.DeleteFunc(s.checks, func(val *healthCheck) bool { return val.name == name })
+}
+
+// RegisterDrainer 注册子系统, 子系统排空期间就绪检查返回 draining
+func (s *HealthRegistry) RegisterDrainer(name string, drainer Drainer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.drainers[name] = drainer
+}
+
+// SetDraining 设置服务自身的排空状态, 优雅关闭开始时置为true
+func (s *HealthRegistry) SetDraining(draining bool) {
+	s.draining.Store(draining)
+}
+
+// Draining 服务自身或任一注册的子系统是否处于排空状态
+func (s *HealthRegistry) Draining() bool {
+	return len(s.drainingSources()) > 0
+}
+
+func (s *HealthRegistry) drainingSources() []string {
+	var sources []string
+	if s.draining.Load() {
+		sources = append(sources, "server")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for name, drainer := range s.drainers {
+		if drainer.Draining() {
+			sources = append(sources, name)
+		}
+	}
+	slices.Sort(sources)
+	return sources
+}
+
+// Liveness 只执行标记为存活检查的函数, 不受排空状态影响
+func (s *HealthRegistry) Liveness(ctx context.Context) *HealthReport {
+	return s.report(ctx, true, false)
+}
+
+// Readiness 执行全部检查, 排空期间返回 draining
+func (s *HealthRegistry) Readiness(ctx context.Context) *HealthReport {
+	return s.report(ctx, false, true)
+}
+
+// Health 执行全部检查并列出排空的子系统, 排空不影响整体状态
+func (s *HealthRegistry) Health(ctx context.Context) *HealthReport {
+	return s.report(ctx, false, false)
+}
+
+func (s *HealthRegistry) report(ctx context.Context, livenessOnly, drainAware bool) *HealthReport {
+	s.mu.RLock()
+	checks := make([]*healthCheck, 0, len(s.checks))
+	for _, val := range s.checks {
+		if !livenessOnly || val.liveness {
+			checks = append(checks, val)
+		}
+	}
+	s.mu.RUnlock()
+
+	report := &HealthReport{Status: HealthPass, Checks: make(map[string]*HealthCheckResult, len(checks))}
+	if !livenessOnly {
+		report.Draining = s.drainingSources()
+	}
+
+	results := make([]*HealthCheckResult, len(checks))
+	var wg sync.WaitGroup
+	for idx, val := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[idx] = val.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	for idx, val := range checks {
+		result := results[idx]
+		report.Checks[val.name] = result
+		switch {
+		case result.Status == HealthPass:
+		case val.critical:
+			report.Status = HealthFail
+		case report.Status == HealthPass:
+			report.Status = HealthWarn
+		}
+	}
+
+	if drainAware && len(report.Draining) > 0 {
+		report.Status = HealthDraining
+	}
+	return report
+}
+
+// run 在超时内执行检查, 超时后不再等待检查函数返回
+func (s *healthCheck) run(ctx context.Context) *HealthCheckResult {
+	checkCtx, cancel := context.WithTimeoutCause(ctx, s.timeout, ErrHealthCheckTimeout)
+	defer cancel()
+
+	startTime := time.Now()
+	errChan := make(chan error, 1)
+	go func() {
+		defer func() {
+			if info := recover(); info != nil {
+				errChan <- fmt.Errorf("panic: %v", info)
+			}
+		}()
+		errChan <- s.check(checkCtx)
+	}()
+
+	var err error
+	select {
+	case err = <-errChan:
+	case <-checkCtx.Done():
+		err = context.Cause(checkCtx)
+	}
+
+	result := &HealthCheckResult{
+		Status:   HealthPass,
+		Critical: s.critical,
+		Duration: time.Since(startTime).Round(time.Microsecond).String(),
+	}
+	if err != nil {
+		result.Status = HealthFail
+		result.Error = err.Error()
+	}
+
+	// 只在状态变化时输出日志, 避免探针刷屏
+	if s.failing.Swap(err != nil) != (err != nil) {
+		if err != nil {
+			slog.WarnContext(ctx, "health check failed", "check", s.name, "critical", s.critical, "err", err)
+		} else {
+			slog.InfoContext(ctx, "health check recovered", "check", s.name)
+		}
+	}
+	return result
+}
+
+// CreateLivezRoute 创建存活检查路由, registry 为nil时使用 DefaultHealthRegistry
+func CreateLivezRoute(uriPattern string, registry *HealthRegistry) Route {
+	return createHealthRoute(uriPattern, registry, (*HealthRegistry).Liveness)
+}
+
+// CreateReadyzRoute 创建就绪检查路由, registry 为nil时使用 DefaultHealthRegistry
+func CreateReadyzRoute(uriPattern string, registry *HealthRegistry) Route {
+	return createHealthRoute(uriPattern, registry, (*HealthRegistry).Readiness)
+}
+
+// CreateHealthzRoute 创建健康详情路由, registry 为nil时使用 DefaultHealthRegistry
+func CreateHealthzRoute(uriPattern string, registry *HealthRegistry) Route {
+	return createHealthRoute(uriPattern, registry, (*HealthRegistry).Health)
+}
+
+func createHealthRoute(uriPattern string, registry *HealthRegistry, reportFunc func(*HealthRegistry, context.Context) *HealthReport) Route {
+	if registry == nil {
+		registry = DefaultHealthRegistry()
+	}
+
+	return CreateRoute(uriPattern, GET, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		report := reportFunc(registry, ctx)
+
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		res.Header().Set("Cache-Control", "no-store")
+		res.WriteHeader(report.StatusCode())
+		_ = json.NewEncoder(res).Encode(report)
+	})
+}
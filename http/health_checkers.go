@@ -0,0 +1,67 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// ErrDiskSpaceLow is reported when free space drops below the configured minimum
+var ErrDiskSpaceLow = errors.New("http: disk space low")
+
+// TCPListenerChecker 检查监听地址是否可以建立TCP连接, 如 tcp.Server 的 bindAddr
+//
+// 地址不带主机名(如 ":9000")时连接本机.
+func TCPListenerChecker(addr string) HealthCheckFunc {
+	return func(ctx context.Context) error {
+		return dialCheck(ctx, addr)
+	}
+}
+
+// ProxyUpstreamChecker 检查代理目标是否可达, 与 CreateProxyRoute 使用相同的 targetURL
+func ProxyUpstreamChecker(targetURL string) HealthCheckFunc {
+	targetURI, targetErr := url.Parse(targetURL)
+	if targetErr == nil && targetURI.Hostname() == "" {
+		targetErr = fmt.Errorf("http: proxy target %q has no host", targetURL)
+	}
+
+	return func(ctx context.Context) error {
+		if targetErr != nil {
+			return targetErr
+		}
+
+		port := targetURI.Port()
+		if port == "" {
+			port = "80"
+			if targetURI.Scheme == "https" {
+				port = "443"
+			}
+		}
+		return dialCheck(ctx, net.JoinHostPort(targetURI.Hostname(), port))
+	}
+}
+
+// DiskSpaceChecker 检查path所在文件系统的可用空间不低于minFreeBytes, 如上传路由的 rootUploadPath
+func DiskSpaceChecker(path string, minFreeBytes uint64) HealthCheckFunc {
+	return func(context.Context) error {
+		freeVal, freeErr := diskFreeBytes(path)
+		if freeErr != nil {
+			return freeErr
+		}
+		if freeVal < minFreeBytes {
+			return fmt.Errorf("%w: %s has %d bytes free, want %d", ErrDiskSpaceLow, path, freeVal, minFreeBytes)
+		}
+		return nil
+	}
+}
+
+func dialCheck(ctx context.Context, addr string) error {
+	dialer := &net.Dialer{}
+	connVal, connErr := dialer.DialContext(ctx, "tcp", addr)
+	if connErr != nil {
+		return connErr
+	}
+	return connVal.Close()
+}
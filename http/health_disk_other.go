@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd)
+
+package http
+
+import "errors"
+
+func diskFreeBytes(string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
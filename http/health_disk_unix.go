@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd
+
+package http
+
+import "syscall"
+
+// diskFreeBytes 非特权用户可用的字节数
+func diskFreeBytes(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type drainerFunc func() bool
+
+func (s drainerFunc) Draining() bool { return s() }
+
+func healthGet(route Route) (int, *HealthReport) {
+	w := httptest.NewRecorder()
+	route.Handler()(context.Background(), w, httptest.NewRequest(http.MethodGet, route.Pattern(), nil))
+
+	report := &HealthReport{}
+	_ = json.NewDecoder(w.Body).Decode(report)
+	return w.Code, report
+}
+
+func TestHealthRegistryStatus(t *testing.T) {
+	registry := NewHealthRegistry()
+	registry.Register("db", func(context.Context) error { return nil }, WithHealthLiveness(true))
+	registry.Register("cache", func(context.Context) error { return errors.New("down") }, WithHealthCritical(false))
+
+	code, report := healthGet(CreateReadyzRoute("/readyz", registry))
+	if code != http.StatusOK || report.Status != HealthWarn || report.Checks["cache"].Error != "down" {
+		t.Fatalf("non critical failure should degrade only, got %d %+v", code, report)
+	}
+
+	registry.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithHealthTimeout(10*time.Millisecond))
+	code, report = healthGet(CreateHealthzRoute("/healthz", registry))
+	if code != http.StatusServiceUnavailable || report.Status != HealthFail || report.Checks["slow"].Error != ErrHealthCheckTimeout.Error() {
+		t.Fatalf("critical timeout should fail, got %d %+v", code, report)
+	}
+
+	code, report = healthGet(CreateLivezRoute("/livez", registry))
+	if code != http.StatusOK || len(report.Checks) != 1 || report.Checks["db"] == nil {
+		t.Fatalf("livez should only run liveness checks, got %d %+v", code, report)
+	}
+
+	registry.Unregister("slow")
+	if code, _ = healthGet(CreateHealthzRoute("/healthz", registry)); code != http.StatusOK {
+		t.Fatalf("unregistered check should not run, got %d", code)
+	}
+}
+
+func TestHealthRegistryDraining(t *testing.T) {
+	registry := NewHealthRegistry()
+	sseDraining := false
+	registry.RegisterDrainer("sse", drainerFunc(func() bool { return sseDraining }))
+
+	if code, _ := healthGet(CreateReadyzRoute("/readyz", registry)); code != http.StatusOK {
+		t.Fatalf("unexpected readiness %d", code)
+	}
+
+	sseDraining = true
+	code, report := healthGet(CreateReadyzRoute("/readyz", registry))
+	if code != http.StatusServiceUnavailable || report.Status != HealthDraining || len(report.Draining) != 1 || report.Draining[0] != "sse" {
+		t.Fatalf("draining subsystem should fail readiness, got %d %+v", code, report)
+	}
+	if code, _ = healthGet(CreateLivezRoute("/livez", registry)); code != http.StatusOK {
+		t.Fatalf("draining should not fail liveness, got %d", code)
+	}
+
+	sseDraining = false
+	svr := NewHTTPServer(WithPort("0"), WithHealthRegistry(registry))
+	done := make(chan struct{})
+	go func() {
+		svr.Run()
+		close(done)
+	}()
+	if err := svr.(GracefulHTTPServer).Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	<-done
+
+	code, report = healthGet(CreateHealthzRoute("/healthz", registry))
+	if code != http.StatusOK || len(report.Draining) != 1 || report.Draining[0] != "server" || !registry.Draining() {
+		t.Fatalf("shutdown should mark server draining, got %d %+v", code, report)
+	}
+}
+
+func TestHTTPServerShutdownKeepsDefaultRegistryReady(t *testing.T) {
+	svr := NewHTTPServer(WithPort("0"))
+	done := make(chan struct{})
+	go func() {
+		svr.Run()
+		close(done)
+	}()
+	if err := svr.(GracefulHTTPServer).Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	<-done
+
+	if DefaultHealthRegistry().Draining() {
+		t.Fatal("shutdown without WithHealthRegistry should not drain the shared default registry")
+	}
+}
+
+func TestHealthCheckers(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	addr := upstream.Listener.Addr().String()
+
+	ctx := context.Background()
+	if err := TCPListenerChecker(addr)(ctx); err != nil {
+		t.Fatalf("listener should be reachable: %v", err)
+	}
+	if err := ProxyUpstreamChecker(upstream.URL + "/api")(ctx); err != nil {
+		t.Fatalf("upstream should be reachable: %v", err)
+	}
+	if err := ProxyUpstreamChecker("/relative")(ctx); err == nil {
+		t.Fatal("target without host should fail")
+	}
+
+	upstream.Close()
+	if err := TCPListenerChecker(addr)(ctx); err == nil {
+		t.Fatal("closed listener should fail")
+	}
+
+	root := t.TempDir()
+	if _, err := diskFreeBytes(root); errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("disk space not supported on this platform")
+	}
+	if err := DiskSpaceChecker(root, 1)(ctx); err != nil {
+		t.Fatalf("disk space check failed: %v", err)
+	}
+	if err := DiskSpaceChecker(root, math.MaxUint64)(ctx); !errors.Is(err, ErrDiskSpaceLow) {
+		t.Fatalf("expected low disk space, got %v", err)
+	}
+}
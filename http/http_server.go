@@ -2,9 +2,11 @@ package http
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"time"
 )
 
 type HTTPServer interface {
@@ -13,6 +15,12 @@ type HTTPServer interface {
 	Run()
 }
 
+// GracefulHTTPServer HTTPServer 的可选接口, NewHTTPServer 返回的 HTTPServer 都实现
+type GracefulHTTPServer interface {
+	// Shutdown 优雅关闭: 先把就绪检查置为排空, 等待排空延迟后停止接收新请求并等待处理中的请求结束
+	Shutdown(ctx context.Context) error
+}
+
 type HTTPServerOption func(*httpServer)
 
 func WithPort(port string) HTTPServerOption {
@@ -37,12 +45,105 @@ func WithStaticEnabled(enabled bool) HTTPServerOption {
 	}
 }
 
+// WithTrustedProxies sets proxy networks whose forwarding headers are trusted when resolving the client address
+func WithTrustedProxies(cidrs ...string) HTTPServerOption {
+	return func(s *httpServer) {
+		s.trustedProxies = append(s.trustedProxies, cidrs...)
+	}
+}
+
+// WithRequestTimeout sets a global request timeout, streaming routes can opt out through exempt options
+func WithRequestTimeout(timeout time.Duration, opts ...TimeoutOption) HTTPServerOption {
+	return func(s *httpServer) {
+		s.requestTimeout = NewTimeout(timeout, opts...)
+	}
+}
+
+// WithConcurrencyLimit caps requests in flight across the whole server
+func WithConcurrencyLimit(limit int, opts ...ConcurrencyLimitOption) HTTPServerOption {
+	return func(s *httpServer) {
+		s.concurrencyLimit = NewConcurrencyLimit(limit, opts...)
+	}
+}
+
+// WithReadTimeout sets http.Server.ReadTimeout, the maximum duration for reading the entire request
+func WithReadTimeout(timeout time.Duration) HTTPServerOption {
+	return func(s *httpServer) {
+		s.readTimeout = timeout
+	}
+}
+
+// WithReadHeaderTimeout sets http.Server.ReadHeaderTimeout
+func WithReadHeaderTimeout(timeout time.Duration) HTTPServerOption {
+	return func(s *httpServer) {
+		s.readHeaderTimeout = timeout
+	}
+}
+
+// WithWriteTimeout sets http.Server.WriteTimeout, it also bounds streaming responses
+func WithWriteTimeout(timeout time.Duration) HTTPServerOption {
+	return func(s *httpServer) {
+		s.writeTimeout = timeout
+	}
+}
+
+// WithIdleTimeout sets http.Server.IdleTimeout for keep-alive connections
+func WithIdleTimeout(timeout time.Duration) HTTPServerOption {
+	return func(s *httpServer) {
+		s.idleTimeout = timeout
+	}
+}
+
+// WithRecovery configures the panic recovery middleware
+func WithRecovery(opts ...RecoveryOption) HTTPServerOption {
+	return func(s *httpServer) {
+		s.recoveryOptions = append(s.recoveryOptions, opts...)
+	}
+}
+
+// WithMaintenance puts the whole server under the maintenance toggle
+func WithMaintenance(maintenance *Maintenance) HTTPServerOption {
+	return func(s *httpServer) {
+		s.maintenance = maintenance
+	}
+}
+
+// WithHealthRegistry sets the registry flipped to draining on Shutdown, default a registry owned by the server;
+// pass the registry used by the readiness route, e.g. DefaultHealthRegistry, to report draining
+func WithHealthRegistry(registry *HealthRegistry) HTTPServerOption {
+	return func(s *httpServer) {
+		s.healthRegistry = registry
+	}
+}
+
+// WithShutdownDrainDelay keeps serving for the delay after readiness turns unavailable,
+// giving load balancers time to stop routing new requests
+func WithShutdownDrainDelay(delay time.Duration) HTTPServerOption {
+	return func(s *httpServer) {
+		s.drainDelay = delay
+	}
+}
+
 type httpServer struct {
 	listenAddr       string
 	routeRegistry    RouteRegistry
 	middlewareChains MiddleWareChains
 	staticOptions    *StaticOptions
 	enableStatic     bool
+	trustedProxies   []string
+
+	recoveryOptions   []RecoveryOption
+	maintenance       *Maintenance
+	concurrencyLimit  *ConcurrencyLimit
+	requestTimeout    *Timeout
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+
+	healthRegistry *HealthRegistry
+	drainDelay     time.Duration
+	server         *http.Server
 }
 
 func NewHTTPServer(opts ...HTTPServerOption) HTTPServer {
@@ -51,14 +152,35 @@ func NewHTTPServer(opts ...HTTPServerOption) HTTPServer {
 		middlewareChains: NewMiddleWareChains(),
 		staticOptions:    &StaticOptions{RootPath: "./static", PrefixUri: "/static", ExcludeUri: "/api/"},
 		enableStatic:     false,
+		healthRegistry:   NewHealthRegistry(),
 	}
 
 	for _, opt := range opts {
 		opt(svr)
 	}
 
+	svr.server = &http.Server{
+		Addr:              svr.listenAddr,
+		Handler:           svr,
+		ReadTimeout:       svr.readTimeout,
+		ReadHeaderTimeout: svr.readHeaderTimeout,
+		WriteTimeout:      svr.writeTimeout,
+		IdleTimeout:       svr.idleTimeout,
+	}
+
+	svr.Use(NewRequestID())
+	svr.Use(NewTrustedProxies(WithTrustedProxyCIDRs(svr.trustedProxies...)))
 	svr.Use(&logger{})
-	svr.Use(&recovery{})
+	svr.Use(NewRecovery(svr.recoveryOptions...))
+	if svr.maintenance != nil {
+		svr.Use(svr.maintenance)
+	}
+	if svr.concurrencyLimit != nil {
+		svr.Use(svr.concurrencyLimit)
+	}
+	if svr.requestTimeout != nil {
+		svr.Use(svr.requestTimeout)
+	}
 
 	if svr.enableStatic {
 		svr.Use(&static{rootPath: Root})
@@ -84,6 +206,27 @@ func (s *httpServer) Bind(routeRegistry RouteRegistry) {
 
 func (s *httpServer) Run() {
 	slog.Info("server listening", "addr", s.listenAddr)
-	err := http.ListenAndServe(s.listenAddr, s)
+	err := s.server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		slog.Info("server stopped", "addr", s.listenAddr)
+		return
+	}
 	slog.Error("server fatal error", "err", err)
 }
+
+func (s *httpServer) Shutdown(ctx context.Context) error {
+	if s.healthRegistry != nil {
+		s.healthRegistry.SetDraining(true)
+	}
+	slog.InfoContext(ctx, "server draining", "addr", s.listenAddr, "delay", s.drainDelay)
+
+	if s.drainDelay > 0 {
+		timer := time.NewTimer(s.drainDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+	return s.server.Shutdown(ctx)
+}
@@ -0,0 +1,238 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/muidea/magicCommon/foundation/helper"
+	"golang.org/x/text/language"
+)
+
+// LocaleKey context中保存协商语言的key
+type LocaleKey struct{}
+
+type localeInfo struct {
+	tag     language.Tag
+	catalog *Catalog
+}
+
+// WithLocale 返回携带语言和消息目录的context, catalog 为 nil 时使用 DefaultCatalog
+func WithLocale(ctx context.Context, catalog *Catalog, tag language.Tag) context.Context {
+	if catalog == nil {
+		catalog = DefaultCatalog()
+	}
+	return context.WithValue(ctx, LocaleKey{}, &localeInfo{tag: tag, catalog: catalog})
+}
+
+// GetLocale 获取context中的协商语言, 未经过 I18n 中间件时返回 language.Und
+func GetLocale(ctx context.Context) language.Tag {
+	if info, ok := helper.GetValueFromContext[*localeInfo](ctx, LocaleKey{}); ok {
+		return info.tag
+	}
+	return language.Und
+}
+
+func getLocaleInfo(ctx context.Context) *localeInfo {
+	if info, ok := helper.GetValueFromContext[*localeInfo](ctx, LocaleKey{}); ok {
+		return info
+	}
+	catalog := DefaultCatalog()
+	return &localeInfo{tag: catalog.Fallback(), catalog: catalog}
+}
+
+// Translate 按context中的语言翻译消息, 找不到时返回key
+func Translate(ctx context.Context, key string, params map[string]any) string {
+	info := getLocaleInfo(ctx)
+	return info.catalog.Message(info.tag, key, params)
+}
+
+// TranslatePlural 按context中的语言和数量翻译复数消息
+func TranslatePlural(ctx context.Context, key string, count int, params map[string]any) string {
+	info := getLocaleInfo(ctx)
+	return info.catalog.Plural(info.tag, key, count, params)
+}
+
+// I18n 语言协商中间件, 依次按查询参数、Cookie、Accept-Language 与消息目录中的语言协商,
+// 结果写入context并通过 Content-Language 响应头返回
+type I18n struct {
+	catalog    *Catalog
+	queryName  string
+	cookieName string
+}
+
+// I18nOption configures an I18n
+type I18nOption func(*I18n)
+
+// WithLocaleQuery sets the query parameter overriding Accept-Language, default "lang", empty disables it
+func WithLocaleQuery(name string) I18nOption {
+	return func(i *I18n) {
+		i.queryName = name
+	}
+}
+
+// WithLocaleCookie sets the cookie overriding Accept-Language, default "lang", empty disables it
+func WithLocaleCookie(name string) I18nOption {
+	return func(i *I18n) {
+		i.cookieName = name
+	}
+}
+
+// NewI18n creates a new I18n, nil catalog means DefaultCatalog
+func NewI18n(catalog *Catalog, opts ...I18nOption) *I18n {
+	if catalog == nil {
+		catalog = DefaultCatalog()
+	}
+
+	i := &I18n{
+		catalog:    catalog,
+		queryName:  "lang",
+		cookieName: "lang",
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// Negotiate 协商请求语言
+func (s *I18n) Negotiate(req *http.Request) language.Tag {
+	preferred := make([]string, 0, 3)
+	if s.queryName != "" {
+		preferred = append(preferred, req.URL.Query().Get(s.queryName))
+	}
+	if s.cookieName != "" {
+		if cookie, cookieErr := req.Cookie(s.cookieName); cookieErr == nil {
+			preferred = append(preferred, cookie.Value)
+		}
+	}
+	preferred = append(preferred, req.Header.Get("Accept-Language"))
+	return s.catalog.Negotiate(preferred...)
+}
+
+func (s *I18n) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	tag := s.Negotiate(req)
+	ctx.Update(WithLocale(ctx.Context(), s.catalog, tag))
+
+	res.Header().Set("Content-Language", tag.String())
+	addVary(res.Header(), "Accept-Language")
+	ctx.Next()
+}
+
+// ProblemTitle 返回本地化的状态标题, 消息key为 "status.<code>", 未定义时使用 http.StatusText
+func ProblemTitle(ctx context.Context, status int) string {
+	info := getLocaleInfo(ctx)
+	key := "status." + strconv.Itoa(status)
+	if info.catalog.Has(info.tag, key) {
+		return info.catalog.Message(info.tag, key, nil)
+	}
+	return http.StatusText(status)
+}
+
+// ValidationMessage 单个字段的本地化校验错误
+type ValidationMessage struct {
+	Field   string `json:"field"`
+	Tag     string `json:"tag"`
+	Message string `json:"message"`
+}
+
+// builtinValidationMessages 未在消息目录中定义 "validation.<tag>" 时使用的内置消息
+var builtinValidationMessages = map[string]map[string]string{
+	"en": {
+		"required": "{field} is required",
+		"min":      "{field} must be at least {param}",
+		"max":      "{field} must be at most {param}",
+		"len":      "{field} must have length {param}",
+		"gte":      "{field} must be greater than or equal to {param}",
+		"lte":      "{field} must be less than or equal to {param}",
+		"gt":       "{field} must be greater than {param}",
+		"lt":       "{field} must be less than {param}",
+		"oneof":    "{field} must be one of [{param}]",
+		"email":    "{field} must be a valid email address",
+		"url":      "{field} must be a valid URL",
+		"numeric":  "{field} must be numeric",
+		"default":  "{field} is invalid",
+	},
+	"zh": {
+		"required": "{field}为必填字段",
+		"min":      "{field}最小为{param}",
+		"max":      "{field}最大为{param}",
+		"len":      "{field}长度必须为{param}",
+		"gte":      "{field}必须大于或等于{param}",
+		"lte":      "{field}必须小于或等于{param}",
+		"gt":       "{field}必须大于{param}",
+		"lt":       "{field}必须小于{param}",
+		"oneof":    "{field}必须是[{param}]中的一个",
+		"email":    "{field}必须是有效的邮箱地址",
+		"url":      "{field}必须是有效的URL",
+		"numeric":  "{field}必须是数字",
+		"default":  "{field}校验失败",
+	},
+}
+
+// LocalizeValidation 把绑定层(validator)返回的校验错误翻译为context中的语言, 不是校验错误时返回nil
+//
+// 消息key为 "validation.<tag>", 参数为 field、param、value; 字段名可通过 "field.<name>" 翻译
+func LocalizeValidation(ctx context.Context, err error) []ValidationMessage {
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return nil
+	}
+
+	info := getLocaleInfo(ctx)
+	ret := make([]ValidationMessage, 0, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		field := fieldErr.Field()
+		if info.catalog.Has(info.tag, "field."+field) {
+			field = info.catalog.Message(info.tag, "field."+field, nil)
+		}
+		params := map[string]any{"field": field, "param": fieldErr.Param(), "value": fieldErr.Value()}
+		ret = append(ret, ValidationMessage{
+			Field:   fieldErr.Field(),
+			Tag:     fieldErr.Tag(),
+			Message: info.validationMessage(fieldErr.Tag(), params),
+		})
+	}
+	return ret
+}
+
+// validationMessage 查找顺序: 目录中请求语言 -> 内置请求语言 -> 目录回退语言 -> 内置回退语言 -> 内置英语
+func (s *localeInfo) validationMessage(tag string, params map[string]any) string {
+	key := "validation." + tag
+	for _, cur := range []language.Tag{s.tag, s.catalog.Fallback(), language.English} {
+		if msg, ok := s.catalog.lookupTag(cur, key); ok && msg.plural == nil {
+			return formatMessage(msg.text, params)
+		}
+
+		base, _ := cur.Base()
+		if builtin, ok := builtinValidationMessages[base.String()]; ok {
+			text, textOK := builtin[tag]
+			if !textOK {
+				text = builtin["default"]
+			}
+			return formatMessage(text, params)
+		}
+	}
+	return formatMessage(builtinValidationMessages["en"]["default"], params)
+}
+
+// RenderValidationError 输出400 application/problem+json, errors 中为本地化的字段错误;
+// 不是校验错误时交给 RenderError
+func RenderValidationError(ctx context.Context, res http.ResponseWriter, req *http.Request, err error) {
+	messages := LocalizeValidation(ctx, err)
+	if messages == nil {
+		RenderError(ctx, res, req, http.StatusBadRequest, err)
+		return
+	}
+
+	writeProblem(res, &problemDetails{
+		Type:      "about:blank",
+		Title:     ProblemTitle(ctx, http.StatusBadRequest),
+		Status:    http.StatusBadRequest,
+		Instance:  req.URL.Path,
+		RequestID: GetRequestID(ctx),
+		Errors:    messages,
+	})
+}
@@ -0,0 +1,302 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+type catalogMessage struct {
+	text   string
+	plural map[string]string
+}
+
+// Catalog 多语言消息目录, 按语言标签保存消息, 支持复数形式和 {name} 占位符
+//
+// JSON 文件中字符串值为普通消息, 对象值为复数消息, 键为 zero/one/two/few/many/other
+// 或精确数量 "=0"、"=1":
+//
+//	{"greeting": "Hello {name}", "items": {"=0": "No items", "one": "{count} item", "other": "{count} items"}}
+type Catalog struct {
+	fallback language.Tag
+
+	mu       sync.RWMutex
+	messages map[language.Tag]map[string]*catalogMessage
+	tags     []language.Tag
+	matcher  language.Matcher
+}
+
+// NewCatalog 新建消息目录, fallback 为协商失败时使用的语言
+func NewCatalog(fallback language.Tag) *Catalog {
+	return &Catalog{
+		fallback: fallback,
+		messages: map[language.Tag]map[string]*catalogMessage{},
+		tags:     []language.Tag{fallback},
+		matcher:  language.NewMatcher([]language.Tag{fallback}),
+	}
+}
+
+var defaultCatalog = NewCatalog(language.English)
+
+// DefaultCatalog 默认消息目录, 回退语言为英语
+func DefaultCatalog() *Catalog {
+	return defaultCatalog
+}
+
+// Fallback 回退语言
+func (s *Catalog) Fallback() language.Tag {
+	return s.fallback
+}
+
+// Tags 已加载消息的语言, 第一个为回退语言
+func (s *Catalog) Tags() []language.Tag {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]language.Tag{}, s.tags...)
+}
+
+// Set 设置普通消息
+func (s *Catalog) Set(tag language.Tag, key, text string) {
+	s.store(tag, key, &catalogMessage{text: text})
+}
+
+// SetPlural 设置复数消息, forms 的键为复数类别或 "=N"
+func (s *Catalog) SetPlural(tag language.Tag, key string, forms map[string]string) {
+	s.store(tag, key, &catalogMessage{plural: forms})
+}
+
+func (s *Catalog) store(tag language.Tag, key string, msg *catalogMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msgs, ok := s.messages[tag]
+	if !ok {
+		msgs = map[string]*catalogMessage{}
+		s.messages[tag] = msgs
+		if tag != s.fallback {
+			s.tags = append(s.tags, tag)
+			s.matcher = language.NewMatcher(s.tags)
+		}
+	}
+	msgs[key] = msg
+}
+
+// LoadJSON 加载一种语言的JSON消息
+func (s *Catalog) LoadJSON(tag language.Tag, content []byte) error {
+	items := map[string]json.RawMessage{}
+	if err := json.Unmarshal(content, &items); err != nil {
+		return err
+	}
+
+	for key, raw := range items {
+		var text string
+		if err := json.Unmarshal(raw, &text); err == nil {
+			s.Set(tag, key, text)
+			continue
+		}
+
+		forms := map[string]string{}
+		if err := json.Unmarshal(raw, &forms); err != nil {
+			return fmt.Errorf("message %s: %w", key, err)
+		}
+		s.SetPlural(tag, key, forms)
+	}
+	return nil
+}
+
+// LoadFS 加载目录下以语言标签命名的JSON文件, 如 locales/en.json、locales/zh-CN.json, 可用于 embed.FS 和 os.DirFS
+func (s *Catalog) LoadFS(fsys fs.FS, dir string) error {
+	entries, entriesErr := fs.ReadDir(fsys, dir)
+	if entriesErr != nil {
+		return entriesErr
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || path.Ext(name) != ".json" {
+			continue
+		}
+
+		tag, tagErr := language.Parse(strings.TrimSuffix(name, ".json"))
+		if tagErr != nil {
+			return fmt.Errorf("%s: %w", name, tagErr)
+		}
+		content, contentErr := fs.ReadFile(fsys, path.Join(dir, name))
+		if contentErr != nil {
+			return contentErr
+		}
+		if err := s.LoadJSON(tag, content); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Negotiate 按顺序尝试每个候选值(单个标签或 Accept-Language 列表), 返回第一个能匹配的已加载语言
+func (s *Catalog) Negotiate(preferred ...string) language.Tag {
+	s.mu.RLock()
+	matcher, tags := s.matcher, s.tags
+	s.mu.RUnlock()
+
+	for _, val := range preferred {
+		if val == "" {
+			continue
+		}
+		desired, _, parseErr := language.ParseAcceptLanguage(val)
+		if parseErr != nil || len(desired) == 0 {
+			continue
+		}
+		if _, idx, confidence := matcher.Match(desired...); confidence != language.No {
+			return tags[idx]
+		}
+	}
+	return s.fallback
+}
+
+// lookup 依次查找tag、其父语言和回退语言
+func (s *Catalog) lookup(tag language.Tag, key string) (*catalogMessage, bool) {
+	if msg, ok := s.lookupTag(tag, key); ok {
+		return msg, true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	msg, ok := s.messages[s.fallback][key]
+	return msg, ok
+}
+
+// lookupTag 只查找tag及其父语言, 不使用回退语言
+func (s *Catalog) lookupTag(tag language.Tag, key string) (*catalogMessage, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for cur := tag; ; cur = cur.Parent() {
+		if msg, ok := s.messages[cur][key]; ok {
+			return msg, true
+		}
+		if cur == language.Und {
+			return nil, false
+		}
+	}
+}
+
+// Has 判断tag语言(含父语言和回退语言)是否定义了key
+func (s *Catalog) Has(tag language.Tag, key string) bool {
+	_, ok := s.lookup(tag, key)
+	return ok
+}
+
+// Message 返回tag语言的消息, 找不到时返回key
+func (s *Catalog) Message(tag language.Tag, key string, params map[string]any) string {
+	msg, ok := s.lookup(tag, key)
+	if !ok {
+		return key
+	}
+
+	text := msg.text
+	if msg.plural != nil {
+		text = msg.plural["other"]
+	}
+	return formatMessage(text, params)
+}
+
+// Plural 按count选择复数形式, params 中自动加入 count
+func (s *Catalog) Plural(tag language.Tag, key string, count int, params map[string]any) string {
+	msg, ok := s.lookup(tag, key)
+	if !ok {
+		return key
+	}
+
+	merged := map[string]any{"count": count}
+	for name, val := range params {
+		merged[name] = val
+	}
+	if msg.plural == nil {
+		return formatMessage(msg.text, merged)
+	}
+
+	text, exact := msg.plural["="+strconv.Itoa(count)]
+	if !exact {
+		text, ok = msg.plural[pluralCategory(tag, count)]
+		if !ok {
+			text = msg.plural["other"]
+		}
+	}
+	return formatMessage(text, merged)
+}
+
+// formatMessage 替换 {name} 占位符, 未提供的占位符保持原样
+func formatMessage(text string, params map[string]any) string {
+	if len(params) == 0 || !strings.Contains(text, "{") {
+		return text
+	}
+
+	pairs := make([]string, 0, len(params)*2)
+	for name, val := range params {
+		pairs = append(pairs, "{"+name+"}", fmt.Sprint(val))
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// pluralCategory 常用语言的CLDR整数复数规则, 未列出的语言按 one/other 处理
+func pluralCategory(tag language.Tag, n int) string {
+	base, _ := tag.Base()
+	n = max(n, -n)
+	mod10, mod100 := n%10, n%100
+
+	switch base.String() {
+	case "zh", "ja", "ko", "vi", "th", "id", "ms":
+		return "other"
+	case "fr", "pt":
+		if n == 0 || n == 1 {
+			return "one"
+		}
+		return "other"
+	case "ru", "uk", "be":
+		switch {
+		case mod10 == 1 && mod100 != 11:
+			return "one"
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return "few"
+		default:
+			return "many"
+		}
+	case "pl":
+		switch {
+		case n == 1:
+			return "one"
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return "few"
+		default:
+			return "many"
+		}
+	case "ar":
+		switch {
+		case n == 0:
+			return "zero"
+		case n == 1:
+			return "one"
+		case n == 2:
+			return "two"
+		case mod100 >= 3 && mod100 <= 10:
+			return "few"
+		case mod100 >= 11:
+			return "many"
+		default:
+			return "other"
+		}
+	default:
+		if n == 1 {
+			return "one"
+		}
+		return "other"
+	}
+}
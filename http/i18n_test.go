@@ -0,0 +1,206 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/text/language"
+)
+
+func newTestCatalog(t *testing.T) *Catalog {
+	catalog := NewCatalog(language.English)
+	fsys := fstest.MapFS{
+		"locales/en.json": {Data: []byte(`{
+			"greeting": "Hello {name}",
+			"items": {"=0": "No items", "one": "{count} item", "other": "{count} items"},
+			"status.400": "Bad Request"
+		}`)},
+		"locales/zh-CN.json": {Data: []byte(`{
+			"greeting": "你好 {name}",
+			"items": {"other": "{count} 个条目"},
+			"status.400": "请求参数错误",
+			"status.500": "服务器内部错误",
+			"field.Name": "名称"
+		}`)},
+		"locales/ru.json": {Data: []byte(`{"files": {"one": "{count} файл", "few": "{count} файла", "many": "{count} файлов"}}`)},
+	}
+	if err := catalog.LoadFS(fsys, "locales"); err != nil {
+		t.Fatalf("load catalog failed, err: %v", err)
+	}
+	return catalog
+}
+
+func TestCatalogMessageAndPlural(t *testing.T) {
+	catalog := newTestCatalog(t)
+	zh := language.MustParse("zh-CN")
+
+	if got := catalog.Message(zh, "greeting", map[string]any{"name": "Ann"}); got != "你好 Ann" {
+		t.Fatalf("zh greeting = %q", got)
+	}
+	if got := catalog.Message(language.MustParse("en-GB"), "greeting", map[string]any{"name": "Ann"}); got != "Hello Ann" {
+		t.Fatalf("en-GB should use parent en, got %q", got)
+	}
+	if got := catalog.Message(zh, "missing", nil); got != "missing" {
+		t.Fatalf("missing key should return key, got %q", got)
+	}
+
+	cases := []struct {
+		tag   language.Tag
+		key   string
+		count int
+		want  string
+	}{
+		{language.English, "items", 0, "No items"},
+		{language.English, "items", 1, "1 item"},
+		{language.English, "items", 5, "5 items"},
+		{zh, "items", 1, "1 个条目"},
+		{language.Russian, "files", 1, "1 файл"},
+		{language.Russian, "files", 3, "3 файла"},
+		{language.Russian, "files", 11, "11 файлов"},
+		{language.Russian, "files", 22, "22 файла"},
+	}
+	for _, c := range cases {
+		if got := catalog.Plural(c.tag, c.key, c.count, nil); got != c.want {
+			t.Errorf("Plural(%s, %s, %d) = %q, want %q", c.tag, c.key, c.count, got, c.want)
+		}
+	}
+}
+
+func TestI18nNegotiate(t *testing.T) {
+	i18n := NewI18n(newTestCatalog(t))
+	handler := func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		_, _ = res.Write([]byte(Translate(ctx, "greeting", map[string]any{"name": "Bob"})))
+	}
+
+	cases := []struct {
+		url, cookie, accept string
+		want, body          string
+	}{
+		{"/hello", "", "zh-CN,zh;q=0.9,en;q=0.8", "zh-CN", "你好 Bob"},
+		{"/hello", "", "zh-TW", "zh-CN", "你好 Bob"},
+		{"/hello", "", "de-DE", "en", "Hello Bob"},
+		{"/hello?lang=en", "zh-CN", "zh-CN", "en", "Hello Bob"},
+		{"/hello", "zh-CN", "en-US", "zh-CN", "你好 Bob"},
+		{"/hello?lang=!!", "", "zh", "zh-CN", "你好 Bob"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.url, nil)
+		req.Header.Set("Accept-Language", c.accept)
+		if c.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "lang", Value: c.cookie})
+		}
+
+		w := serveWithMiddleware(i18n, handler, req)
+		if got := w.Header().Get("Content-Language"); got != c.want || w.Body.String() != c.body {
+			t.Errorf("%s cookie=%q accept=%q: Content-Language = %q body = %q, want %q %q", c.url, c.cookie, c.accept, got, w.Body.String(), c.want, c.body)
+		}
+		if !strings.Contains(w.Header().Get("Vary"), "Accept-Language") {
+			t.Errorf("Vary should contain Accept-Language, got %q", w.Header().Get("Vary"))
+		}
+	}
+
+	if GetLocale(context.Background()) != language.Und {
+		t.Fatal("locale should be undefined without middleware")
+	}
+}
+
+func TestCatalogNegotiateAfterStore(t *testing.T) {
+	catalog := NewCatalog(language.English)
+	if got := catalog.Negotiate("fr"); got != language.English {
+		t.Fatalf("unloaded language should fall back, got %s", got)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				catalog.Negotiate("fr,en;q=0.5")
+			}
+		}()
+	}
+	catalog.Set(language.French, "greeting", "Bonjour")
+	wg.Wait()
+
+	if got := catalog.Negotiate("fr-CA"); got != language.French {
+		t.Fatalf("new language should be negotiated, got %s", got)
+	}
+}
+
+type i18nSignup struct {
+	Name string `validate:"required"`
+	Age  int    `validate:"gte=18"`
+	Role string `validate:"oneof=admin user"`
+}
+
+func TestRenderValidationErrorLocalized(t *testing.T) {
+	catalog := newTestCatalog(t)
+	catalog.Set(language.English, "validation.gte", "{field} must be {param} or older")
+	validateErr := validator.New().Struct(&i18nSignup{Age: 16, Role: "guest"})
+
+	req := httptest.NewRequest(http.MethodPost, "/signup", nil)
+	w := httptest.NewRecorder()
+	RenderValidationError(WithLocale(context.Background(), catalog, language.MustParse("zh-CN")), w, req, validateErr)
+
+	problem := &problemDetails{}
+	if err := json.Unmarshal(w.Body.Bytes(), problem); err != nil {
+		t.Fatalf("decode problem failed, err: %v", err)
+	}
+	if w.Code != http.StatusBadRequest || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("unexpected response, code = %d, content type = %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if problem.Title != "请求参数错误" || len(problem.Errors) != 3 {
+		t.Fatalf("unexpected problem: %+v", problem)
+	}
+	if problem.Errors[0].Field != "Name" || problem.Errors[0].Message != "名称为必填字段" {
+		t.Fatalf("unexpected required message: %+v", problem.Errors[0])
+	}
+	if problem.Errors[2].Message != "Role必须是[admin user]中的一个" {
+		t.Fatalf("unexpected oneof message: %+v", problem.Errors[2])
+	}
+
+	messages := LocalizeValidation(WithLocale(context.Background(), catalog, language.English), validateErr)
+	if messages[1].Message != "Age must be 18 or older" {
+		t.Fatalf("catalog message should override builtin, got %q", messages[1].Message)
+	}
+	if LocalizeValidation(context.Background(), ErrURLNotFound) != nil {
+		t.Fatal("non validation error should not be localized")
+	}
+}
+
+func TestProblemRenderersLocalized(t *testing.T) {
+	ctx := WithLocale(WithRequestID(context.Background(), "req-1"), newTestCatalog(t), language.MustParse("zh-CN"))
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+
+	w := httptest.NewRecorder()
+	ProblemErrorRenderer(ctx, w, req, http.StatusInternalServerError, ErrMaintenance)
+	problem := &problemDetails{}
+	_ = json.Unmarshal(w.Body.Bytes(), problem)
+	if problem.Title != "服务器内部错误" || problem.Detail != ErrMaintenance.Error() || problem.Instance != "/orders" || problem.RequestID != "req-1" {
+		t.Fatalf("unexpected problem: %+v", problem)
+	}
+
+	w = httptest.NewRecorder()
+	ProblemErrorRenderer(ctx, w, req, http.StatusNotFound, nil)
+	problem = &problemDetails{}
+	_ = json.Unmarshal(w.Body.Bytes(), problem)
+	if w.Code != http.StatusNotFound || problem.Title != http.StatusText(http.StatusNotFound) {
+		t.Fatalf("undefined title should fall back to status text, got %+v", problem)
+	}
+
+	w = httptest.NewRecorder()
+	ProblemPanicRenderer(ctx, w, req, &PanicInfo{Path: "/orders"})
+	problem = &problemDetails{}
+	_ = json.Unmarshal(w.Body.Bytes(), problem)
+	if problem.Title != "服务器内部错误" {
+		t.Fatalf("panic problem title should be localized, got %+v", problem)
+	}
+}
@@ -0,0 +1,253 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultIdempotencyHeader      = "Idempotency-Key"
+	DefaultIdempotencyMaxBodySize = 1 << 20
+	maxIdempotencyKeySize         = 255
+)
+
+var (
+	// ErrIdempotencyKeyMissing is returned when a required Idempotency-Key header is absent or too long
+	ErrIdempotencyKeyMissing = errors.New("http: idempotency key missing or invalid")
+
+	// ErrIdempotencyKeyInUse is returned while the first request with the same key is still in flight
+	ErrIdempotencyKeyInUse = errors.New("http: idempotency key in use")
+
+	// ErrIdempotencyKeyReused is returned when a key is reused with a different request
+	ErrIdempotencyKeyReused = errors.New("http: idempotency key reused with different request")
+)
+
+// Idempotency 幂等键中间件, 对非安全方法按Idempotency-Key保存首次响应并在重试时重放
+//
+// 键按调用方隔离(默认已认证身份, 否则客户端IP); 首次请求返回5xx或panic时删除记录, 允许重试.
+// 重放的响应带 Idempotent-Replayed: true. 请求体超过 maxBodySize 时返回413,
+// 响应体超过 maxBodySize 时不保存记录.
+type Idempotency struct {
+	store       IdempotencyStore
+	header      string
+	ttl         time.Duration
+	required    bool
+	scope       RateLimitKeyFunc
+	maxBodySize int64
+}
+
+// IdempotencyOption configures an Idempotency
+type IdempotencyOption func(*Idempotency)
+
+// WithIdempotencyStore sets the record store, default a shared MemoryIdempotencyStore
+func WithIdempotencyStore(store IdempotencyStore) IdempotencyOption {
+	return func(i *Idempotency) {
+		i.store = store
+	}
+}
+
+// WithIdempotencyHeader sets the request header carrying the key, default Idempotency-Key
+func WithIdempotencyHeader(header string) IdempotencyOption {
+	return func(i *Idempotency) {
+		i.header = header
+	}
+}
+
+// WithIdempotencyTTL sets how long responses are kept for replay, default 24 hours
+func WithIdempotencyTTL(ttl time.Duration) IdempotencyOption {
+	return func(i *Idempotency) {
+		i.ttl = ttl
+	}
+}
+
+// WithIdempotencyRequired rejects unsafe requests without a key with 400
+func WithIdempotencyRequired(required bool) IdempotencyOption {
+	return func(i *Idempotency) {
+		i.required = required
+	}
+}
+
+// WithIdempotencyScope sets how keys are partitioned between clients, default KeyByPrincipal
+func WithIdempotencyScope(scope RateLimitKeyFunc) IdempotencyOption {
+	return func(i *Idempotency) {
+		i.scope = scope
+	}
+}
+
+// WithIdempotencyMaxBodySize sets the largest request and response body handled, default DefaultIdempotencyMaxBodySize
+func WithIdempotencyMaxBodySize(size int64) IdempotencyOption {
+	return func(i *Idempotency) {
+		i.maxBodySize = size
+	}
+}
+
+var (
+	defaultIdempotencyStore     IdempotencyStore
+	defaultIdempotencyStoreOnce sync.Once
+)
+
+func getDefaultIdempotencyStore() IdempotencyStore {
+	defaultIdempotencyStoreOnce.Do(func() {
+		defaultIdempotencyStore = NewMemoryIdempotencyStore(time.Minute)
+	})
+	return defaultIdempotencyStore
+}
+
+// NewIdempotency creates a new Idempotency with optional configuration
+func NewIdempotency(opts ...IdempotencyOption) *Idempotency {
+	i := &Idempotency{
+		header:      DefaultIdempotencyHeader,
+		ttl:         24 * time.Hour,
+		scope:       KeyByPrincipal(),
+		maxBodySize: DefaultIdempotencyMaxBodySize,
+	}
+
+	for _, opt := range opts {
+		opt(i)
+	}
+
+	if i.store == nil {
+		i.store = getDefaultIdempotencyStore()
+	}
+
+	return i
+}
+
+func (s *Idempotency) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	if isSafeMethod(req.Method) {
+		ctx.Next()
+		return
+	}
+
+	key := req.Header.Get(s.header)
+	if key == "" && !s.required {
+		ctx.Next()
+		return
+	}
+	if key == "" || len(key) > maxIdempotencyKeySize {
+		RenderError(ctx.Context(), res, req, http.StatusBadRequest, ErrIdempotencyKeyMissing)
+		return
+	}
+
+	fingerprint, fingerprintErr := requestFingerprint(req, s.maxBodySize)
+	if errors.Is(fingerprintErr, ErrRequestBodyTooLarge) {
+		res.Header().Set("Connection", "close")
+		RenderError(ctx.Context(), res, req, http.StatusRequestEntityTooLarge, fingerprintErr)
+		return
+	}
+	if fingerprintErr != nil {
+		RenderError(ctx.Context(), res, req, http.StatusBadRequest, fingerprintErr)
+		return
+	}
+
+	storeKey := s.scope(ctx.Context(), req) + "|" + key
+	record, recordErr := s.store.Begin(storeKey, fingerprint, s.ttl)
+	if recordErr != nil {
+		slog.ErrorContext(ctx.Context(), "idempotency store failed", "err", recordErr)
+		RenderError(ctx.Context(), res, req, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if record != nil {
+		s.replay(ctx.Context(), res, req, record, fingerprint)
+		return
+	}
+
+	s.process(ctx, res, req, storeKey, fingerprint)
+}
+
+// replay 处理重复的请求
+func (s *Idempotency) replay(ctx context.Context, res http.ResponseWriter, req *http.Request, record *IdempotencyRecord, fingerprint string) {
+	if record.Fingerprint != fingerprint {
+		RenderError(ctx, res, req, http.StatusUnprocessableEntity, ErrIdempotencyKeyReused)
+		return
+	}
+	if !record.Completed {
+		res.Header().Set("Retry-After", "1")
+		RenderError(ctx, res, req, http.StatusConflict, ErrIdempotencyKeyInUse)
+		return
+	}
+
+	header := res.Header()
+	for key, values := range record.Header {
+		header[key] = append([]string(nil), values...)
+	}
+	header.Set("Idempotent-Replayed", "true")
+	res.WriteHeader(record.Status)
+	_, _ = res.Write(record.Body)
+}
+
+// process 执行首次请求并保存响应
+func (s *Idempotency) process(ctx RequestContext, res http.ResponseWriter, req *http.Request, storeKey, fingerprint string) {
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+		if err := s.store.Release(storeKey); err != nil {
+			slog.ErrorContext(ctx.Context(), "release idempotency key failed", "err", err)
+		}
+	}()
+
+	cw := &cacheWriter{ResponseWriter: res, preset: res.Header().Clone(), maxSize: s.maxBodySize}
+	restore, ok := replaceResponseWriter(ctx, cw)
+	if !ok {
+		ctx.Next()
+		return
+	}
+	ctx.Next()
+	restore()
+
+	if cw.status == 0 || cw.status >= http.StatusInternalServerError {
+		return
+	}
+	if cw.overflow {
+		slog.WarnContext(ctx.Context(), "idempotent response too large, record dropped", "path", req.URL.Path, "max_size", s.maxBodySize)
+		return
+	}
+
+	record := &IdempotencyRecord{
+		Fingerprint: fingerprint,
+		Completed:   true,
+		Status:      cw.status,
+		Header:      cw.snapshot,
+		Body:        cw.body.Bytes(),
+	}
+	record.Header.Del("Set-Cookie")
+	if err := s.store.Complete(storeKey, record, s.ttl); err != nil {
+		slog.ErrorContext(ctx.Context(), "save idempotency record failed", "err", err)
+		return
+	}
+	completed = true
+}
+
+// requestFingerprint 按方法、URI和请求体计算指纹, 读取后恢复请求体; 请求体超过maxSize时返回 ErrRequestBodyTooLarge
+func requestFingerprint(req *http.Request, maxSize int64) (string, error) {
+	hash := sha256.New()
+	hash.Write([]byte(req.Method + " " + req.URL.RequestURI() + "\n"))
+
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.ContentLength > maxSize {
+			return "", ErrRequestBodyTooLarge
+		}
+		content, contentErr := io.ReadAll(io.LimitReader(req.Body, maxSize+1))
+		_ = req.Body.Close()
+		if contentErr != nil {
+			return "", contentErr
+		}
+		if int64(len(content)) > maxSize {
+			return "", ErrRequestBodyTooLarge
+		}
+		req.Body = io.NopCloser(bytes.NewReader(content))
+		hash.Write(content)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
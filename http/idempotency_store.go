@@ -0,0 +1,129 @@
+package http
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyRecord 一个幂等键对应的处理状态
+type IdempotencyRecord struct {
+	// Fingerprint 首次请求的指纹, 同一个键的请求内容必须一致
+	Fingerprint string
+	// Completed 首次请求已处理完成, 未完成表示仍在处理中
+	Completed bool
+	Status    int
+	Header    http.Header
+	Body      []byte
+}
+
+// IdempotencyStore 幂等记录存储, 实现需要保证Begin的原子性
+type IdempotencyStore interface {
+	// Begin 占用key, key不存在时保存进行中的记录并返回nil, 已存在时返回已有记录
+	Begin(key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, error)
+	// Complete 保存首次请求的响应
+	Complete(key string, record *IdempotencyRecord, ttl time.Duration) error
+	// Release 删除记录, 首次请求失败时允许客户端重试
+	Release(key string) error
+}
+
+type idempotencyItem struct {
+	record   *IdempotencyRecord
+	expireAt time.Time
+}
+
+// MemoryIdempotencyStore 内存幂等记录存储, 后台定期清理过期记录
+type MemoryIdempotencyStore struct {
+	items    map[string]*idempotencyItem
+	mu       sync.Mutex
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewMemoryIdempotencyStore 新建内存幂等记录存储
+func NewMemoryIdempotencyStore(gcInterval time.Duration) *MemoryIdempotencyStore {
+	if gcInterval <= 0 {
+		gcInterval = time.Minute
+	}
+
+	s := &MemoryIdempotencyStore{
+		items:    map[string]*idempotencyItem{},
+		stopChan: make(chan struct{}),
+	}
+	go s.gc(gcInterval)
+	return s
+}
+
+func (s *MemoryIdempotencyStore) gc(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case now := <-ticker.C:
+			s.evict(now)
+		}
+	}
+}
+
+func (s *MemoryIdempotencyStore) evict(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, item := range s.items {
+		if now.After(item.expireAt) {
+			delete(s.items, key)
+		}
+	}
+}
+
+// Close 停止后台清理
+func (s *MemoryIdempotencyStore) Close() {
+	s.stopOnce.Do(func() {
+		close(s.stopChan)
+	})
+}
+
+// Len 当前保存的记录数
+func (s *MemoryIdempotencyStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.items)
+}
+
+func (s *MemoryIdempotencyStore) Begin(key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if item, ok := s.items[key]; ok && !now.After(item.expireAt) {
+		// 返回副本, 避免调用方修改保存的记录
+		record := *item.record
+		return &record, nil
+	}
+
+	s.items[key] = &idempotencyItem{
+		record:   &IdempotencyRecord{Fingerprint: fingerprint},
+		expireAt: now.Add(ttl),
+	}
+	return nil, nil
+}
+
+func (s *MemoryIdempotencyStore) Complete(key string, record *IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[key] = &idempotencyItem{record: record, expireAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.items, key)
+	return nil
+}
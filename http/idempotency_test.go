@@ -0,0 +1,156 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func idempotentPost(idem *Idempotency, handler RouteHandleFunc, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/payments", strings.NewReader(body))
+	req.RemoteAddr = "192.0.2.1:1234"
+	if key != "" {
+		req.Header.Set(DefaultIdempotencyHeader, key)
+	}
+	return serveWithMiddleware(idem, handler, req)
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	store := NewMemoryIdempotencyStore(time.Minute)
+	defer store.Close()
+	idem := NewIdempotency(WithIdempotencyStore(store))
+
+	counter := &atomic.Int32{}
+	handler := func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Location", fmt.Sprintf("/payments/%d", counter.Add(1)))
+		res.WriteHeader(http.StatusCreated)
+		_, _ = res.Write([]byte("created"))
+	}
+
+	w := idempotentPost(idem, handler, "k1", `{"amount":10}`)
+	if w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("unexpected first response %d %v", w.Code, w.Header())
+	}
+
+	w = idempotentPost(idem, handler, "k1", `{"amount":10}`)
+	if w.Code != http.StatusCreated || w.Body.String() != "created" || w.Header().Get("Location") != "/payments/1" ||
+		w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("unexpected replay %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+	if counter.Load() != 1 {
+		t.Fatalf("handler should run once, got %d", counter.Load())
+	}
+
+	if w = idempotentPost(idem, handler, "k1", `{"amount":20}`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("reused key should be rejected, got %d", w.Code)
+	}
+	if w = idempotentPost(idem, handler, "", `{"amount":10}`); w.Code != http.StatusCreated || counter.Load() != 2 {
+		t.Fatalf("request without key should pass through, got %d", w.Code)
+	}
+}
+
+func TestIdempotencyInFlightAndFailure(t *testing.T) {
+	store := NewMemoryIdempotencyStore(time.Minute)
+	defer store.Close()
+	idem := NewIdempotency(WithIdempotencyStore(store), WithIdempotencyRequired(true))
+
+	if w := idempotentPost(idem, okHandler, "", "{}"); w.Code != http.StatusBadRequest {
+		t.Fatalf("missing key should be rejected, got %d", w.Code)
+	}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan int, 1)
+	go func() {
+		w := idempotentPost(idem, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+			close(started)
+			<-release
+			http.Error(res, "downstream failed", http.StatusBadGateway)
+		}, "k2", "{}")
+		done <- w.Code
+	}()
+	<-started
+
+	w := idempotentPost(idem, okHandler, "k2", "{}")
+	if w.Code != http.StatusConflict || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("concurrent duplicate should be rejected, got %d", w.Code)
+	}
+
+	close(release)
+	<-done
+	if store.Len() != 0 {
+		t.Fatal("failed request should release the key")
+	}
+	if w = idempotentPost(idem, okHandler, "k2", "{}"); w.Code != http.StatusOK {
+		t.Fatalf("retry after failure should run again, got %d", w.Code)
+	}
+}
+
+func TestIdempotencyScope(t *testing.T) {
+	store := NewMemoryIdempotencyStore(time.Minute)
+	defer store.Close()
+	idem := NewIdempotency(WithIdempotencyStore(store))
+
+	counter := &atomic.Int32{}
+	handler := func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		_, _ = fmt.Fprintf(res, "%d", counter.Add(1))
+	}
+
+	idempotentPost(idem, handler, "same", "{}")
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/payments", strings.NewReader("{}"))
+	req.RemoteAddr = "198.51.100.2:1234"
+	req.Header.Set(DefaultIdempotencyHeader, "same")
+	if w := serveWithMiddleware(idem, handler, req); w.Body.String() != "2" {
+		t.Fatalf("keys of different clients should not collide, got %q", w.Body.String())
+	}
+}
+
+func TestIdempotencyMaxBodySize(t *testing.T) {
+	store := NewMemoryIdempotencyStore(time.Minute)
+	defer store.Close()
+	idem := NewIdempotency(WithIdempotencyStore(store), WithIdempotencyMaxBodySize(8))
+
+	counter := &atomic.Int32{}
+	handler := func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		_, _ = fmt.Fprintf(res, "response-%d", counter.Add(1))
+	}
+
+	if w := idempotentPost(idem, handler, "big", `{"amount":10}`); w.Code != http.StatusRequestEntityTooLarge || counter.Load() != 0 {
+		t.Fatalf("oversized request body should be rejected, got %d", w.Code)
+	}
+
+	if w := idempotentPost(idem, handler, "small", "{}"); w.Body.String() != "response-1" {
+		t.Fatalf("oversized response should still be written, got %q", w.Body.String())
+	}
+	if w := idempotentPost(idem, handler, "small", "{}"); w.Body.String() != "response-2" || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("oversized response should not be stored for replay, got %q", w.Body.String())
+	}
+}
+
+type failingIdempotencyStore struct{}
+
+func (failingIdempotencyStore) Begin(string, string, time.Duration) (*IdempotencyRecord, error) {
+	return nil, errors.New("redis: dial tcp 10.0.0.5:6379: connection refused")
+}
+
+func (failingIdempotencyStore) Complete(string, *IdempotencyRecord, time.Duration) error {
+	return nil
+}
+
+func (failingIdempotencyStore) Release(string) error {
+	return nil
+}
+
+func TestIdempotencyStoreErrorNotExposed(t *testing.T) {
+	idem := NewIdempotency(WithIdempotencyStore(failingIdempotencyStore{}))
+
+	w := idempotentPost(idem, okHandler, "k1", "{}")
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "redis") {
+		t.Fatalf("store error should be rendered as a generic 500, got %d %q", w.Code, w.Body.String())
+	}
+}
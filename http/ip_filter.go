@@ -0,0 +1,226 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrIPForbidden is returned when the client IP is rejected by an IPFilter
+var ErrIPForbidden = errors.New("http: client ip forbidden")
+
+// ipRules 一组不可变的允许/拒绝规则, 整体替换以支持热加载
+type ipRules struct {
+	allow *CIDRSet
+	deny  *CIDRSet
+}
+
+// IPFilter IP黑白名单中间件, 使用经TrustedProxies解析的客户端IP
+//
+// 拒绝列表优先; 允许列表非空时, 只放行其中的地址. 被拒绝的请求返回403并记录审计日志.
+type IPFilter struct {
+	name          string
+	allow         []string
+	deny          []string
+	filePath      string
+	checkInterval time.Duration
+
+	rules     atomic.Pointer[ipRules]
+	mu        sync.Mutex
+	modTime   time.Time
+	size      int64
+	lastCheck atomic.Int64
+}
+
+// IPFilterOption configures an IPFilter
+type IPFilterOption func(*IPFilter)
+
+// WithIPFilterName sets the name written to audit logs, e.g. "admin"
+func WithIPFilterName(name string) IPFilterOption {
+	return func(f *IPFilter) {
+		f.name = name
+	}
+}
+
+// WithIPAllow adds networks that are allowed, once set other addresses are rejected
+func WithIPAllow(cidrs ...string) IPFilterOption {
+	return func(f *IPFilter) {
+		f.allow = append(f.allow, cidrs...)
+	}
+}
+
+// WithIPDeny adds networks that are always rejected
+func WithIPDeny(cidrs ...string) IPFilterOption {
+	return func(f *IPFilter) {
+		f.deny = append(f.deny, cidrs...)
+	}
+}
+
+// WithIPFilterFile loads additional rules from a file, one "allow <cidr>" or "deny <cidr>" per line,
+// the file is reloaded when its modification time or size changes
+func WithIPFilterFile(filePath string) IPFilterOption {
+	return func(f *IPFilter) {
+		f.filePath = filePath
+	}
+}
+
+// WithIPFilterCheckInterval sets how often the rule file is checked for changes, default 1 second
+func WithIPFilterCheckInterval(interval time.Duration) IPFilterOption {
+	return func(f *IPFilter) {
+		f.checkInterval = interval
+	}
+}
+
+// NewIPFilter creates a new IPFilter, illegal rules in options or the file are reported as error
+func NewIPFilter(opts ...IPFilterOption) (*IPFilter, error) {
+	f := &IPFilter{checkInterval: time.Second}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload 重新构建规则, 文件中的规则追加在选项配置的规则之后; 失败时保留原有规则
+func (s *IPFilter) Reload() error {
+	allow, deny := append([]string{}, s.allow...), append([]string{}, s.deny...)
+
+	var fileInfo os.FileInfo
+	if s.filePath != "" {
+		var fileErr error
+		fileInfo, fileErr = os.Stat(s.filePath)
+		if fileErr != nil {
+			return fileErr
+		}
+		content, contentErr := os.ReadFile(s.filePath)
+		if contentErr != nil {
+			return contentErr
+		}
+
+		fileAllow, fileDeny, parseErr := parseIPRules(content)
+		if parseErr != nil {
+			return fmt.Errorf("%s: %w", s.filePath, parseErr)
+		}
+		allow = append(allow, fileAllow...)
+		deny = append(deny, fileDeny...)
+	}
+
+	allowSet, allowErr := NewCIDRSet(allow...)
+	if allowErr != nil {
+		return allowErr
+	}
+	denySet, denyErr := NewCIDRSet(deny...)
+	if denyErr != nil {
+		return denyErr
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules.Store(&ipRules{allow: allowSet, deny: denySet})
+	if fileInfo != nil {
+		s.modTime = fileInfo.ModTime()
+		s.size = fileInfo.Size()
+	}
+	return nil
+}
+
+func parseIPRules(content []byte) ([]string, []string, error) {
+	allow, deny := []string{}, []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = strings.TrimSpace(line[:idx])
+		}
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, nil, fmt.Errorf("illegal ip rule line %d", lineNo)
+		}
+		switch strings.ToLower(fields[0]) {
+		case "allow":
+			allow = append(allow, fields[1])
+		case "deny":
+			deny = append(deny, fields[1])
+		default:
+			return nil, nil, fmt.Errorf("illegal ip rule action %s at line %d", fields[0], lineNo)
+		}
+	}
+	return allow, deny, nil
+}
+
+func (s *IPFilter) reloadIfChanged() {
+	if s.filePath == "" {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	last := s.lastCheck.Load()
+	if now-last < int64(s.checkInterval) || !s.lastCheck.CompareAndSwap(last, now) {
+		return
+	}
+
+	fileInfo, fileErr := os.Stat(s.filePath)
+	if fileErr != nil {
+		slog.Error("stat ip filter file failed", "file", s.filePath, "err", fileErr)
+		return
+	}
+
+	s.mu.Lock()
+	changed := !fileInfo.ModTime().Equal(s.modTime) || fileInfo.Size() != s.size
+	s.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	if err := s.Reload(); err != nil {
+		slog.Error("reload ip filter failed", "file", s.filePath, "err", err)
+		return
+	}
+	slog.Info("ip filter reloaded", "file", s.filePath)
+}
+
+// Allowed 判断客户端IP是否放行, 无法解析的地址只在没有允许列表时放行
+func (s *IPFilter) Allowed(ip string) bool {
+	rules := s.rules.Load()
+	addr, ok := parseHopAddr(ip)
+	if !ok {
+		return rules.allow.Len() == 0
+	}
+	if rules.deny.Contains(addr) {
+		return false
+	}
+	return rules.allow.Len() == 0 || rules.allow.Contains(addr)
+}
+
+func (s *IPFilter) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	s.reloadIfChanged()
+
+	ip := clientIP(ctx.Context(), req)
+	if !s.Allowed(ip) {
+		slog.WarnContext(ctx.Context(), "ip filter denied",
+			"filter", s.name,
+			"ip", ip,
+			"method", req.Method,
+			"path", req.URL.Path,
+			"request_id", GetRequestID(ctx.Context()))
+		RenderError(ctx.Context(), res, req, http.StatusForbidden, ErrIPForbidden)
+		return
+	}
+
+	ctx.Next()
+}
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCIDRSet(t *testing.T) {
+	set, err := NewCIDRSet("10.0.0.0/8", "192.168.1.1", "2001:db8::/32", "::ffff:172.16.0.0/108")
+	if err != nil {
+		t.Fatalf("new cidr set failed, err:%s", err.Error())
+	}
+
+	cases := map[string]bool{
+		"10.1.2.3":         true,
+		"11.0.0.1":         false,
+		"192.168.1.1":      true,
+		"192.168.1.2":      false,
+		"::ffff:10.0.0.1":  true,
+		"2001:db8:1::1":    true,
+		"2001:db9::1":      false,
+		"172.16.3.4":       true,
+		"172.32.0.1":       false,
+		"[2001:db8::1]:80": true,
+		"garbage":          false,
+	}
+	for ip, want := range cases {
+		if got := set.ContainsString(ip); got != want {
+			t.Errorf("contains %s = %v, want %v", ip, got, want)
+		}
+	}
+
+	if set.Contains(netip.Addr{}) {
+		t.Error("invalid addr should not match")
+	}
+	if _, err := NewCIDRSet("10.0.0.0/40"); err == nil {
+		t.Error("illegal cidr should fail")
+	}
+}
+
+func TestIPFilterAllowDeny(t *testing.T) {
+	filter, err := NewIPFilter(WithIPAllow("10.0.0.0/8"), WithIPDeny("10.0.0.13"))
+	if err != nil {
+		t.Fatalf("new ip filter failed, err:%s", err.Error())
+	}
+
+	cases := map[string]int{
+		"10.0.0.1:1000":  http.StatusOK,
+		"10.0.0.13:1000": http.StatusForbidden,
+		"8.8.8.8:1000":   http.StatusForbidden,
+	}
+	for remoteAddr, want := range cases {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/admin", nil)
+		req.RemoteAddr = remoteAddr
+		if w := serveWithMiddleware(filter, okHandler, req); w.Code != want {
+			t.Errorf("%s got status %d, want %d", remoteAddr, w.Code, want)
+		}
+	}
+
+	denyOnly, _ := NewIPFilter(WithIPDeny("2001:db8::/32"))
+	if !denyOnly.Allowed("8.8.8.8") || denyOnly.Allowed("2001:db8::5") {
+		t.Error("deny only filter should allow everything except denied networks")
+	}
+}
+
+func TestIPFilterFileReload(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "ip.rules")
+	if err := os.WriteFile(filePath, []byte("# admin\nallow 10.0.0.0/8\ndeny 10.0.0.13 # lab\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	filter, err := NewIPFilter(WithIPFilterFile(filePath), WithIPFilterCheckInterval(0))
+	if err != nil {
+		t.Fatalf("new ip filter failed, err:%s", err.Error())
+	}
+	if !filter.Allowed("10.0.0.1") || filter.Allowed("10.0.0.13") || filter.Allowed("8.8.8.8") {
+		t.Fatal("unexpected rules from file")
+	}
+
+	if err := os.WriteFile(filePath, []byte("allow 8.8.8.0/24\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(filePath, future, future)
+	filter.reloadIfChanged()
+	if !filter.Allowed("8.8.8.8") || filter.Allowed("10.0.0.1") {
+		t.Fatal("rules should be reloaded after file change")
+	}
+
+	// 错误的文件不影响已加载的规则
+	if err := os.WriteFile(filePath, []byte("block 1.1.1.1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	future = future.Add(time.Minute)
+	_ = os.Chtimes(filePath, future, future)
+	filter.reloadIfChanged()
+	if !filter.Allowed("8.8.8.8") {
+		t.Fatal("illegal file should keep previous rules")
+	}
+
+	if _, err := NewIPFilter(WithIPFilterFile(filePath)); err == nil {
+		t.Fatal("illegal rule file should fail")
+	}
+}
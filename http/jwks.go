@@ -0,0 +1,256 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultJWKSRefresh   = 10 * time.Minute
+	defaultJWKSMinReload = time.Minute
+	maxJWKSSize          = 1 << 20
+)
+
+// ErrUnknownSigningKey is returned when no key matches a token's kid
+var ErrUnknownSigningKey = errors.New("http: unknown signing key")
+
+// JWKS 从文件或URL加载的JSON Web Key Set, 定期刷新
+//
+// 遇到未知kid时会提前刷新一次(受最小间隔限制), 以支持密钥轮换.
+// 加载在锁外进行并合并并发请求, 到期刷新在后台完成, 期间继续使用缓存的密钥.
+type JWKS struct {
+	loader    func(ctx context.Context) ([]byte, error)
+	refresh   time.Duration
+	minReload time.Duration
+	client    *http.Client
+
+	keys     []JWTKey
+	loadedAt time.Time
+	mu       sync.RWMutex
+	group    singleflight.Group
+}
+
+// JWKSOption configures a JWKS
+type JWKSOption func(*JWKS)
+
+// WithJWKSRefresh sets how long loaded keys are cached, default 10 minutes
+func WithJWKSRefresh(refresh time.Duration) JWKSOption {
+	return func(j *JWKS) {
+		j.refresh = refresh
+	}
+}
+
+// WithJWKSHTTPClient sets the client used by NewJWKSFromURL
+func WithJWKSHTTPClient(client *http.Client) JWKSOption {
+	return func(j *JWKS) {
+		j.client = client
+	}
+}
+
+func newJWKS(opts ...JWKSOption) *JWKS {
+	j := &JWKS{
+		refresh:   defaultJWKSRefresh,
+		minReload: defaultJWKSMinReload,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// NewJWKSFromFile 从本地文件加载JWKS
+func NewJWKSFromFile(filePath string, opts ...JWKSOption) *JWKS {
+	j := newJWKS(opts...)
+	j.loader = func(context.Context) ([]byte, error) {
+		return os.ReadFile(filePath)
+	}
+	return j
+}
+
+// NewJWKSFromURL 从URL加载JWKS
+func NewJWKSFromURL(jwksURL string, opts ...JWKSOption) *JWKS {
+	j := newJWKS(opts...)
+	j.loader = func(ctx context.Context) ([]byte, error) {
+		req, reqErr := http.NewRequestWithContext(ctx, http.MethodGet, jwksURL, nil)
+		if reqErr != nil {
+			return nil, reqErr
+		}
+		req.Header.Set("Accept", "application/json")
+
+		res, resErr := j.client.Do(req)
+		if resErr != nil {
+			return nil, resErr
+		}
+		defer func() {
+			_ = res.Body.Close()
+		}()
+
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("load jwks failed, status:%d", res.StatusCode)
+		}
+		return io.ReadAll(io.LimitReader(res.Body, maxJWKSSize))
+	}
+	return j
+}
+
+func (s *JWKS) Keys(kid string) ([]JWTKey, error) {
+	keys, loadedAt := s.cached()
+	if keys == nil {
+		// 首次加载只能等待
+		s.reload()
+		keys, loadedAt = s.cached()
+	} else if time.Since(loadedAt) >= s.refresh {
+		go s.reload()
+	}
+
+	matched := filterJWTKeys(keys, kid)
+	if len(matched) == 0 && kid != "" && time.Since(loadedAt) >= s.minReload {
+		s.reload()
+		keys, _ = s.cached()
+		matched = filterJWTKeys(keys, kid)
+	}
+	if len(matched) == 0 {
+		return nil, ErrUnknownSigningKey
+	}
+	return matched, nil
+}
+
+func (s *JWKS) cached() ([]JWTKey, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.keys, s.loadedAt
+}
+
+// reload 合并并发的加载, 加载失败时保留旧的密钥
+func (s *JWKS) reload() {
+	_, _, _ = s.group.Do("jwks", func() (any, error) {
+		s.mu.Lock()
+		if s.keys != nil && time.Since(s.loadedAt) < min(s.minReload, s.refresh) {
+			// 刚刚加载过, 合并错过singleflight窗口的重复刷新
+			s.mu.Unlock()
+			return nil, nil
+		}
+		s.loadedAt = time.Now()
+		s.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		byteVal, byteErr := s.loader(ctx)
+		if byteErr != nil {
+			slog.Error("load jwks failed", "err", byteErr)
+			return nil, nil
+		}
+
+		keys, keysErr := ParseJWKS(byteVal)
+		if keysErr != nil {
+			slog.Error("parse jwks failed", "err", keysErr)
+			return nil, nil
+		}
+
+		s.mu.Lock()
+		s.keys = keys
+		s.mu.Unlock()
+		return nil, nil
+	})
+}
+
+type jsonWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+	K         string `json:"k"`
+}
+
+// ParseJWKS 解析JWKS文档, 支持RSA、P-256 EC和oct密钥, 跳过非签名用途和不支持的密钥
+func ParseJWKS(content []byte) ([]JWTKey, error) {
+	doc := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+
+	keys := []JWTKey{}
+	for _, val := range doc.Keys {
+		if val.Use != "" && val.Use != "sig" {
+			continue
+		}
+
+		key, keyErr := val.toJWTKey()
+		if keyErr != nil {
+			slog.Warn("skip illegal jwk", "kid", val.KeyID, "err", keyErr)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (k *jsonWebKey) toJWTKey() (JWTKey, error) {
+	key := JWTKey{ID: k.KeyID, Algorithm: k.Algorithm}
+	switch k.KeyType {
+	case "RSA":
+		n, nErr := decodeBigInt(k.N)
+		e, eErr := decodeBigInt(k.E)
+		if nErr != nil || eErr != nil || !e.IsInt64() {
+			return key, ErrInvalidToken
+		}
+		key.Key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		if key.Algorithm == "" {
+			key.Algorithm = RS256
+		}
+	case "EC":
+		if k.Curve != "P-256" {
+			return key, fmt.Errorf("%w: curve %s", ErrUnsupportedAlgorithm, k.Curve)
+		}
+		x, xErr := decodeBigInt(k.X)
+		y, yErr := decodeBigInt(k.Y)
+		if xErr != nil || yErr != nil {
+			return key, ErrInvalidToken
+		}
+		key.Key = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		if key.Algorithm == "" {
+			key.Algorithm = ES256
+		}
+	case "oct":
+		secret, secretErr := jwtEncoding.DecodeString(k.K)
+		if secretErr != nil {
+			return key, secretErr
+		}
+		key.Key = secret
+	default:
+		return key, fmt.Errorf("%w: kty %s", ErrUnsupportedAlgorithm, k.KeyType)
+	}
+	return key, nil
+}
+
+func decodeBigInt(val string) (*big.Int, error) {
+	byteVal, byteErr := jwtEncoding.DecodeString(val)
+	if byteErr != nil {
+		return nil, byteErr
+	}
+	return new(big.Int).SetBytes(byteVal), nil
+}
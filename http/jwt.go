@@ -0,0 +1,526 @@
+package http
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"log/slog"
+	"math"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/muidea/magicCommon/foundation/helper"
+)
+
+var (
+	// ErrMissingToken is returned when a request carries no bearer token
+	ErrMissingToken = errors.New("http: missing bearer token")
+
+	// ErrInvalidToken is returned when a token is malformed or its signature does not verify
+	ErrInvalidToken = errors.New("http: invalid token")
+
+	// ErrTokenExpired is returned when a token's exp claim has passed
+	ErrTokenExpired = errors.New("http: token expired")
+
+	// ErrTokenNotValidYet is returned when a token's nbf claim is in the future
+	ErrTokenNotValidYet = errors.New("http: token not valid yet")
+
+	// ErrInvalidIssuer is returned when a token's iss claim is not accepted
+	ErrInvalidIssuer = errors.New("http: invalid token issuer")
+
+	// ErrInvalidAudience is returned when a token's aud claim is not accepted
+	ErrInvalidAudience = errors.New("http: invalid token audience")
+
+	// ErrUnsupportedAlgorithm is returned when a token or key uses an algorithm that is not allowed
+	ErrUnsupportedAlgorithm = errors.New("http: unsupported signing algorithm")
+)
+
+// 支持的JWT签名算法
+const (
+	HS256 = "HS256"
+	HS384 = "HS384"
+	HS512 = "HS512"
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+// JWTKey 签名/验签密钥
+//
+// Key 的类型: HS* 为 []byte; RS256 为 *rsa.PublicKey 或 *rsa.PrivateKey;
+// ES256 为 *ecdsa.PublicKey 或 *ecdsa.PrivateKey. 签发时需要私钥.
+type JWTKey struct {
+	ID        string
+	Algorithm string
+	Key       any
+}
+
+// JWTKeyProvider 提供验签密钥, kid 为空时返回全部密钥
+type JWTKeyProvider interface {
+	Keys(kid string) ([]JWTKey, error)
+}
+
+type staticJWTKeys []JWTKey
+
+// NewStaticJWTKeys 使用固定的密钥集合
+func NewStaticJWTKeys(keys ...JWTKey) JWTKeyProvider {
+	return staticJWTKeys(keys)
+}
+
+func (s staticJWTKeys) Keys(kid string) ([]JWTKey, error) {
+	return filterJWTKeys(s, kid), nil
+}
+
+func filterJWTKeys(keys []JWTKey, kid string) []JWTKey {
+	if kid == "" {
+		return keys
+	}
+
+	ret := []JWTKey{}
+	for _, val := range keys {
+		if val.ID == kid || val.ID == "" {
+			ret = append(ret, val)
+		}
+	}
+	return ret
+}
+
+// JWTClaims JWT载荷
+type JWTClaims map[string]any
+
+// JWTClaimsKey context中保存JWT载荷的key
+type JWTClaimsKey struct{}
+
+// WithJWTClaims 返回携带JWT载荷的context
+func WithJWTClaims(ctx context.Context, claims JWTClaims) context.Context {
+	return context.WithValue(ctx, JWTClaimsKey{}, claims)
+}
+
+// GetJWTClaims 获取context中已验证的JWT载荷
+func GetJWTClaims(ctx context.Context) (JWTClaims, bool) {
+	claims, ok := helper.GetValueFromContext[JWTClaims](ctx, JWTClaimsKey{})
+	return claims, ok && claims != nil
+}
+
+func (c JWTClaims) stringClaim(name string) string {
+	val, _ := c[name].(string)
+	return val
+}
+
+// Subject sub
+func (c JWTClaims) Subject() string {
+	return c.stringClaim("sub")
+}
+
+// Issuer iss
+func (c JWTClaims) Issuer() string {
+	return c.stringClaim("iss")
+}
+
+// Audience aud, 兼容字符串和数组两种形式
+func (c JWTClaims) Audience() []string {
+	switch val := c["aud"].(type) {
+	case string:
+		return []string{val}
+	case []string:
+		return val
+	case []any:
+		ret := make([]string, 0, len(val))
+		for _, item := range val {
+			if str, ok := item.(string); ok {
+				ret = append(ret, str)
+			}
+		}
+		return ret
+	}
+	return nil
+}
+
+// ExpiresAt exp
+func (c JWTClaims) ExpiresAt() (time.Time, bool) {
+	return c.numericDate("exp")
+}
+
+// NotBefore nbf
+func (c JWTClaims) NotBefore() (time.Time, bool) {
+	return c.numericDate("nbf")
+}
+
+func (c JWTClaims) numericDate(name string) (time.Time, bool) {
+	var seconds float64
+	switch val := c[name].(type) {
+	case float64:
+		seconds = val
+	case int64:
+		seconds = float64(val)
+	case int:
+		seconds = float64(val)
+	case json.Number:
+		floatVal, floatErr := val.Float64()
+		if floatErr != nil {
+			return time.Time{}, false
+		}
+		seconds = floatVal
+	default:
+		return time.Time{}, false
+	}
+	if math.IsNaN(seconds) || math.Abs(seconds) > math.MaxInt64/float64(time.Second) {
+		return time.Time{}, false
+	}
+
+	sec := int64(seconds)
+	return time.Unix(sec, int64((seconds-float64(sec))*float64(time.Second))), true
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+var jwtEncoding = base64.RawURLEncoding
+
+// IssueJWT 使用key签发JWT, 未设置iat时自动填充, ttl大于0时设置exp
+func IssueJWT(key JWTKey, claims JWTClaims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	payload := make(JWTClaims, len(claims)+2)
+	for k, v := range claims {
+		payload[k] = v
+	}
+	if _, ok := payload["iat"]; !ok {
+		payload["iat"] = now.Unix()
+	}
+	if ttl > 0 {
+		payload["exp"] = now.Add(ttl).Unix()
+	}
+
+	headerVal, headerErr := json.Marshal(&jwtHeader{Algorithm: key.Algorithm, Type: "JWT", KeyID: key.ID})
+	if headerErr != nil {
+		return "", headerErr
+	}
+	payloadVal, payloadErr := json.Marshal(payload)
+	if payloadErr != nil {
+		return "", payloadErr
+	}
+
+	signingInput := jwtEncoding.EncodeToString(headerVal) + "." + jwtEncoding.EncodeToString(payloadVal)
+	signature, signErr := signJWT(key, []byte(signingInput))
+	if signErr != nil {
+		return "", signErr
+	}
+	return signingInput + "." + jwtEncoding.EncodeToString(signature), nil
+}
+
+func hmacHash(algorithm string) func() hash.Hash {
+	switch algorithm {
+	case HS256:
+		return sha256.New
+	case HS384:
+		return sha512.New384
+	case HS512:
+		return sha512.New
+	}
+	return nil
+}
+
+func hmacSecret(key any) ([]byte, bool) {
+	switch val := key.(type) {
+	case []byte:
+		return val, len(val) > 0
+	case string:
+		return []byte(val), val != ""
+	}
+	return nil, false
+}
+
+func signJWT(key JWTKey, signingInput []byte) ([]byte, error) {
+	switch key.Algorithm {
+	case HS256, HS384, HS512:
+		secret, ok := hmacSecret(key.Key)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s requires a []byte secret", ErrUnsupportedAlgorithm, key.Algorithm)
+		}
+		mac := hmac.New(hmacHash(key.Algorithm), secret)
+		mac.Write(signingInput)
+		return mac.Sum(nil), nil
+	case RS256:
+		privateKey, ok := key.Key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: RS256 requires *rsa.PrivateKey", ErrUnsupportedAlgorithm)
+		}
+		digest := sha256.Sum256(signingInput)
+		return rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	case ES256:
+		privateKey, ok := key.Key.(*ecdsa.PrivateKey)
+		if !ok || privateKey.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%w: ES256 requires a P-256 *ecdsa.PrivateKey", ErrUnsupportedAlgorithm)
+		}
+		digest := sha256.Sum256(signingInput)
+		r, s, err := ecdsa.Sign(rand.Reader, privateKey, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, key.Algorithm)
+}
+
+func verifyJWT(key JWTKey, algorithm string, signingInput, signature []byte) bool {
+	if key.Algorithm != "" && key.Algorithm != algorithm {
+		return false
+	}
+
+	switch algorithm {
+	case HS256, HS384, HS512:
+		secret, ok := hmacSecret(key.Key)
+		if !ok {
+			return false
+		}
+		mac := hmac.New(hmacHash(algorithm), secret)
+		mac.Write(signingInput)
+		return hmac.Equal(signature, mac.Sum(nil))
+	case RS256:
+		var publicKey *rsa.PublicKey
+		switch val := key.Key.(type) {
+		case *rsa.PublicKey:
+			publicKey = val
+		case *rsa.PrivateKey:
+			publicKey = &val.PublicKey
+		default:
+			return false
+		}
+		digest := sha256.Sum256(signingInput)
+		return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature) == nil
+	case ES256:
+		var publicKey *ecdsa.PublicKey
+		switch val := key.Key.(type) {
+		case *ecdsa.PublicKey:
+			publicKey = val
+		case *ecdsa.PrivateKey:
+			publicKey = &val.PublicKey
+		default:
+			return false
+		}
+		if publicKey.Curve != elliptic.P256() || len(signature) != 64 {
+			return false
+		}
+		digest := sha256.Sum256(signingInput)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(publicKey, digest[:], r, s)
+	}
+	return false
+}
+
+// JWTAuth JWT bearer认证中间件
+type JWTAuth struct {
+	keys       JWTKeyProvider
+	algorithms []string
+	issuers    []string
+	audiences  []string
+	leeway     time.Duration
+	requireExp bool
+	cookieName string
+	now        func() time.Time
+}
+
+// JWTAuthOption configures a JWTAuth
+type JWTAuthOption func(*JWTAuth)
+
+// WithJWTAlgorithms restricts the accepted signing algorithms, default all supported
+func WithJWTAlgorithms(algorithms ...string) JWTAuthOption {
+	return func(j *JWTAuth) {
+		j.algorithms = algorithms
+	}
+}
+
+// WithJWTIssuer requires the iss claim to equal one of issuers
+func WithJWTIssuer(issuers ...string) JWTAuthOption {
+	return func(j *JWTAuth) {
+		j.issuers = issuers
+	}
+}
+
+// WithJWTAudience requires the aud claim to contain one of audiences
+func WithJWTAudience(audiences ...string) JWTAuthOption {
+	return func(j *JWTAuth) {
+		j.audiences = audiences
+	}
+}
+
+// WithJWTLeeway sets the allowed clock skew when checking exp and nbf
+func WithJWTLeeway(leeway time.Duration) JWTAuthOption {
+	return func(j *JWTAuth) {
+		j.leeway = leeway
+	}
+}
+
+// WithJWTRequireExpiry rejects tokens without an exp claim
+func WithJWTRequireExpiry(require bool) JWTAuthOption {
+	return func(j *JWTAuth) {
+		j.requireExp = require
+	}
+}
+
+// WithJWTCookie also reads the token from the named cookie when Authorization is absent
+func WithJWTCookie(name string) JWTAuthOption {
+	return func(j *JWTAuth) {
+		j.cookieName = name
+	}
+}
+
+// NewJWTAuth creates a new JWTAuth verifying tokens with keys
+func NewJWTAuth(keys JWTKeyProvider, opts ...JWTAuthOption) *JWTAuth {
+	j := &JWTAuth{
+		keys:       keys,
+		algorithms: []string{HS256, HS384, HS512, RS256, ES256},
+		leeway:     30 * time.Second,
+		now:        time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	return j
+}
+
+func (s *JWTAuth) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	token := s.extractToken(req)
+	if token == "" {
+		res.Header().Set("WWW-Authenticate", "Bearer")
+		RenderError(ctx.Context(), res, req, http.StatusUnauthorized, ErrMissingToken)
+		return
+	}
+
+	claims, err := s.Verify(token)
+	if err != nil {
+		slog.WarnContext(ctx.Context(), "jwt verify failed", "path", req.URL.Path, "err", err)
+		res.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		RenderError(ctx.Context(), res, req, http.StatusUnauthorized, err)
+		return
+	}
+
+	reqCtx := WithJWTClaims(ctx.Context(), claims)
+	ctx.Update(WithPrincipal(reqCtx, &Principal{ID: claims.Subject(), Scheme: "Bearer", Attributes: claims}))
+	ctx.Next()
+}
+
+func (s *JWTAuth) extractToken(req *http.Request) string {
+	authorization := req.Header.Get("Authorization")
+	if scheme, token, ok := strings.Cut(authorization, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	if s.cookieName != "" {
+		if cookie, err := req.Cookie(s.cookieName); err == nil {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// Verify 校验签名和exp/nbf/iss/aud, 返回载荷
+func (s *JWTAuth) Verify(token string) (JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	header := jwtHeader{}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !slices.Contains(s.algorithms, header.Algorithm) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, header.Algorithm)
+	}
+
+	signature, signatureErr := jwtEncoding.DecodeString(parts[2])
+	if signatureErr != nil {
+		return nil, ErrInvalidToken
+	}
+
+	keys, keysErr := s.keys.Keys(header.KeyID)
+	if keysErr != nil {
+		return nil, keysErr
+	}
+
+	signingInput := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range keys {
+		if verifyJWT(key, header.Algorithm, signingInput, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrInvalidToken
+	}
+
+	claims := JWTClaims{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := s.validateClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (s *JWTAuth) validateClaims(claims JWTClaims) error {
+	now := s.now()
+	// exp/nbf 存在但不是数字时视为无效token, 不能当作未设置
+	if _, present := claims["exp"]; present {
+		expiresAt, ok := claims.ExpiresAt()
+		if !ok {
+			return fmt.Errorf("%w: malformed exp", ErrInvalidToken)
+		}
+		if !now.Before(expiresAt.Add(s.leeway)) {
+			return ErrTokenExpired
+		}
+	} else if s.requireExp {
+		return fmt.Errorf("%w: missing exp", ErrInvalidToken)
+	}
+	if _, present := claims["nbf"]; present {
+		notBefore, ok := claims.NotBefore()
+		if !ok {
+			return fmt.Errorf("%w: malformed nbf", ErrInvalidToken)
+		}
+		if now.Add(s.leeway).Before(notBefore) {
+			return ErrTokenNotValidYet
+		}
+	}
+	if len(s.issuers) > 0 && !slices.Contains(s.issuers, claims.Issuer()) {
+		return ErrInvalidIssuer
+	}
+	if len(s.audiences) > 0 && !slices.ContainsFunc(claims.Audience(), func(aud string) bool {
+		return slices.Contains(s.audiences, aud)
+	}) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, val any) error {
+	byteVal, byteErr := jwtEncoding.DecodeString(part)
+	if byteErr != nil {
+		return byteErr
+	}
+	return json.Unmarshal(byteVal, val)
+}
@@ -0,0 +1,232 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJWTAuthStoresClaimsAndPrincipal(t *testing.T) {
+	key := JWTKey{ID: "k1", Algorithm: HS256, Key: []byte("secret")}
+	token, err := IssueJWT(key, JWTClaims{"sub": "user-1", "iss": "login", "aud": []string{"api"}}, time.Minute)
+	if err != nil {
+		t.Fatalf("IssueJWT failed: %v", err)
+	}
+
+	auth := NewJWTAuth(NewStaticJWTKeys(key), WithJWTIssuer("login"), WithJWTAudience("api"), WithJWTCookie("token"))
+	var principal *Principal
+	var claims JWTClaims
+	handler := func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		principal, _ = GetPrincipal(ctx)
+		claims, _ = GetJWTClaims(ctx)
+		res.WriteHeader(http.StatusOK)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if w := serveWithMiddleware(auth, handler, req); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if principal == nil || principal.ID != "user-1" || principal.Scheme != "Bearer" || claims.Issuer() != "login" {
+		t.Fatalf("unexpected principal %+v / claims %+v", principal, claims)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: token})
+	if w := serveWithMiddleware(auth, handler, req); w.Code != http.StatusOK {
+		t.Fatalf("cookie token status = %d", w.Code)
+	}
+
+	w := serveWithMiddleware(auth, handler, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Fatalf("missing token should be rejected, status = %d", w.Code)
+	}
+}
+
+func TestJWTAuthValidatesClaims(t *testing.T) {
+	key := JWTKey{Algorithm: HS384, Key: []byte("secret")}
+	now := time.Now()
+	auth := NewJWTAuth(NewStaticJWTKeys(key), WithJWTIssuer("login"), WithJWTAudience("api"), WithJWTLeeway(10*time.Second))
+
+	cases := []struct {
+		claims JWTClaims
+		err    error
+	}{
+		{JWTClaims{"iss": "login", "aud": "api", "exp": now.Add(-5 * time.Second).Unix()}, nil},
+		{JWTClaims{"iss": "login", "aud": "api", "exp": now.Add(-time.Minute).Unix()}, ErrTokenExpired},
+		{JWTClaims{"iss": "login", "aud": "api", "nbf": now.Add(time.Minute).Unix()}, ErrTokenNotValidYet},
+		{JWTClaims{"iss": "other", "aud": "api"}, ErrInvalidIssuer},
+		{JWTClaims{"iss": "login", "aud": []string{"web"}}, ErrInvalidAudience},
+		{JWTClaims{"iss": "login", "aud": "api", "exp": fmt.Sprint(now.Add(time.Hour).Unix())}, ErrInvalidToken},
+		{JWTClaims{"iss": "login", "aud": "api", "nbf": nil}, ErrInvalidToken},
+	}
+	for idx, val := range cases {
+		token, _ := IssueJWT(key, val.claims, 0)
+		if _, err := auth.Verify(token); !errors.Is(err, val.err) {
+			t.Errorf("case %d: err = %v, want %v", idx, err, val.err)
+		}
+	}
+
+	token, _ := IssueJWT(key, JWTClaims{"iss": "login", "aud": "api"}, 0)
+	if _, err := NewJWTAuth(NewStaticJWTKeys(key), WithJWTRequireExpiry(true)).Verify(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("missing exp should be rejected when required, err = %v", err)
+	}
+	if _, err := auth.Verify(token[:len(token)-2] + "xx"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("tampered signature err = %v", err)
+	}
+	if _, err := NewJWTAuth(NewStaticJWTKeys(key), WithJWTAlgorithms(HS256)).Verify(token); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Fatalf("disallowed algorithm err = %v", err)
+	}
+
+	noneToken := jwtEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + jwtEncoding.EncodeToString([]byte(`{}`)) + "."
+	if _, err := auth.Verify(noneToken); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Fatalf("alg none err = %v", err)
+	}
+}
+
+func TestJWTAsymmetricAlgorithms(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	signKeys := []JWTKey{
+		{ID: "rsa", Algorithm: RS256, Key: rsaKey},
+		{ID: "ec", Algorithm: ES256, Key: ecKey},
+	}
+	auth := NewJWTAuth(NewStaticJWTKeys(
+		JWTKey{ID: "rsa", Algorithm: RS256, Key: &rsaKey.PublicKey},
+		JWTKey{ID: "ec", Algorithm: ES256, Key: &ecKey.PublicKey},
+	))
+	for _, key := range signKeys {
+		token, err := IssueJWT(key, JWTClaims{"sub": key.ID}, time.Minute)
+		if err != nil {
+			t.Fatalf("%s: IssueJWT failed: %v", key.Algorithm, err)
+		}
+		claims, err := auth.Verify(token)
+		if err != nil || claims.Subject() != key.ID {
+			t.Fatalf("%s: verify failed: %v", key.Algorithm, err)
+		}
+	}
+
+	// RSA公钥不能被当作HMAC密钥使用
+	forged, _ := IssueJWT(JWTKey{ID: "rsa", Algorithm: HS256, Key: rsaKey.PublicKey.N.Bytes()}, JWTClaims{}, 0)
+	if _, err := auth.Verify(forged); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("algorithm confusion err = %v", err)
+	}
+}
+
+func writeJWKS(t *testing.T, filePath string, kid string, key *rsa.PublicKey) {
+	t.Helper()
+	content := fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":%q,"use":"sig","n":%q,"e":%q},{"kty":"RSA","kid":"enc","use":"enc","n":"AQAB","e":"AQAB"}]}`,
+		kid, jwtEncoding.EncodeToString(key.N.Bytes()), jwtEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()))
+	if err := os.WriteFile(filePath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write jwks failed: %v", err)
+	}
+}
+
+func TestJWKSFileRotation(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "jwks.json")
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	writeJWKS(t, filePath, "old", &oldKey.PublicKey)
+
+	jwks := NewJWKSFromFile(filePath, WithJWKSRefresh(time.Hour))
+	jwks.minReload = 0
+	auth := NewJWTAuth(jwks)
+
+	token, _ := IssueJWT(JWTKey{ID: "old", Algorithm: RS256, Key: oldKey}, JWTClaims{}, time.Minute)
+	if _, err := auth.Verify(token); err != nil {
+		t.Fatalf("verify with old key failed: %v", err)
+	}
+
+	writeJWKS(t, filePath, "new", &newKey.PublicKey)
+	token, _ = IssueJWT(JWTKey{ID: "new", Algorithm: RS256, Key: newKey}, JWTClaims{}, time.Minute)
+	if _, err := auth.Verify(token); err != nil {
+		t.Fatalf("unknown kid should trigger reload: %v", err)
+	}
+	if keys, _ := jwks.Keys(""); len(keys) != 1 {
+		t.Fatalf("encryption keys should be skipped, got %d keys", len(keys))
+	}
+}
+
+func TestJWKSFromURLCaches(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	filePath := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, filePath, "k1", &key.PublicKey)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		requests++
+		http.ServeFile(res, req, filePath)
+	}))
+	defer server.Close()
+
+	auth := NewJWTAuth(NewJWKSFromURL(server.URL))
+	token, _ := IssueJWT(JWTKey{ID: "k1", Algorithm: RS256, Key: key}, JWTClaims{}, time.Minute)
+	for idx := 0; idx < 3; idx++ {
+		if _, err := auth.Verify(token); err != nil {
+			t.Fatalf("verify failed: %v", err)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("jwks requests = %d, want 1", requests)
+	}
+
+	unknown, _ := IssueJWT(JWTKey{ID: "k2", Algorithm: RS256, Key: key}, JWTClaims{}, time.Minute)
+	if _, err := auth.Verify(unknown); !errors.Is(err, ErrUnknownSigningKey) || !strings.Contains(err.Error(), "unknown") {
+		t.Fatalf("unknown kid err = %v", err)
+	}
+}
+
+func TestJWKSRefreshDoesNotBlockVerification(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	filePath := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, filePath, "k1", &key.PublicKey)
+	content, _ := os.ReadFile(filePath)
+
+	release := make(chan struct{})
+	defer close(release)
+	loads := 0
+	jwks := newJWKS(WithJWKSRefresh(time.Millisecond))
+	jwks.loader = func(ctx context.Context) ([]byte, error) {
+		loads++
+		if loads > 1 {
+			<-release
+		}
+		return content, nil
+	}
+	if keys, err := jwks.Keys("k1"); err != nil || len(keys) != 1 {
+		t.Fatalf("initial load failed: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		for idx := 0; idx < 10; idx++ {
+			if _, err := jwks.Keys("k1"); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("cached keys should be served during refresh: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Keys blocked behind a slow refresh")
+	}
+}
@@ -0,0 +1,277 @@
+package http
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const rotateTimeLayout = "20060102-150405"
+
+// RotateWriter 按文件大小或时间间隔滚动的日志文件
+type RotateWriter struct {
+	filePath   string
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+
+	file     *os.File
+	size     int64
+	openTime time.Time
+	mu       sync.Mutex
+}
+
+// RotateOption configures a RotateWriter
+type RotateOption func(*RotateWriter)
+
+// WithRotateSize rotates the file once it grows beyond size bytes
+func WithRotateSize(size int64) RotateOption {
+	return func(w *RotateWriter) {
+		w.maxSize = size
+	}
+}
+
+// WithRotateInterval rotates the file every interval
+func WithRotateInterval(interval time.Duration) RotateOption {
+	return func(w *RotateWriter) {
+		w.interval = interval
+	}
+}
+
+// WithRotateBackups keeps at most count rotated files, zero keeps all
+func WithRotateBackups(count int) RotateOption {
+	return func(w *RotateWriter) {
+		w.maxBackups = count
+	}
+}
+
+// NewRotateWriter opens filePath for appending and rotates it per the options
+func NewRotateWriter(filePath string, opts ...RotateOption) (*RotateWriter, error) {
+	if filePath == "" {
+		return nil, ErrEmptyFilePath
+	}
+
+	w := &RotateWriter{filePath: filePath}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *RotateWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.filePath), 0o755); err != nil {
+		return err
+	}
+
+	fileVal, fileErr := os.OpenFile(w.filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if fileErr != nil {
+		return fileErr
+	}
+	infoVal, infoErr := fileVal.Stat()
+	if infoErr != nil {
+		_ = fileVal.Close()
+		return infoErr
+	}
+
+	w.file = fileVal
+	w.size = infoVal.Size()
+	w.openTime = time.Now()
+	return nil
+}
+
+func (w *RotateWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	if w.shouldRotate(int64(len(data))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(data)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *RotateWriter) shouldRotate(incoming int64) bool {
+	if w.maxSize > 0 && w.size > 0 && w.size+incoming > w.maxSize {
+		return true
+	}
+	return w.interval > 0 && time.Since(w.openTime) >= w.interval
+}
+
+// Rotate 立即滚动日志文件
+func (w *RotateWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.rotate()
+}
+
+func (w *RotateWriter) rotate() error {
+	if w.file != nil {
+		_ = w.file.Close()
+		w.file = nil
+	}
+
+	backupPath := fmt.Sprintf("%s.%s", w.filePath, time.Now().Format(rotateTimeLayout))
+	for idx := 1; ; idx++ {
+		if _, err := os.Stat(backupPath); os.IsNotExist(err) {
+			break
+		}
+		backupPath = fmt.Sprintf("%s.%s.%d", w.filePath, time.Now().Format(rotateTimeLayout), idx)
+	}
+	if err := os.Rename(w.filePath, backupPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	w.removeBackups()
+	return w.open()
+}
+
+func (w *RotateWriter) removeBackups() {
+	if w.maxBackups <= 0 {
+		return
+	}
+
+	backups, _ := filepath.Glob(w.filePath + ".*")
+	if len(backups) <= w.maxBackups {
+		return
+	}
+
+	sort.Strings(backups)
+	for _, val := range backups[:len(backups)-w.maxBackups] {
+		if strings.HasPrefix(val, w.filePath+".") {
+			_ = os.Remove(val)
+		}
+	}
+}
+
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+const (
+	defaultAsyncQueueSize     = 4096
+	defaultAsyncFlushInterval = time.Second
+)
+
+// AsyncWriter 异步缓冲写入, 队列满时丢弃数据而不阻塞请求
+type AsyncWriter struct {
+	writer    io.Writer
+	buffer    *bufio.Writer
+	queue     chan []byte
+	interval  time.Duration
+	dropped   int64
+	closed    bool
+	closeLock sync.RWMutex
+	closeOnce sync.Once
+	closeErr  error
+	done      chan struct{}
+}
+
+// NewAsyncWriter 新建异步写入器, queueSize<=0时使用默认队列长度
+func NewAsyncWriter(writer io.Writer, queueSize int) *AsyncWriter {
+	if queueSize <= 0 {
+		queueSize = defaultAsyncQueueSize
+	}
+
+	w := &AsyncWriter{
+		writer:   writer,
+		buffer:   bufio.NewWriter(writer),
+		queue:    make(chan []byte, queueSize),
+		interval: defaultAsyncFlushInterval,
+		done:     make(chan struct{}),
+	}
+	go w.loop()
+	return w
+}
+
+func (w *AsyncWriter) Write(data []byte) (int, error) {
+	w.closeLock.RLock()
+	defer w.closeLock.RUnlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	item := append([]byte(nil), data...)
+	select {
+	case w.queue <- item:
+	default:
+		atomic.AddInt64(&w.dropped, 1)
+	}
+	return len(data), nil
+}
+
+// Dropped 返回因队列满被丢弃的写入次数
+func (w *AsyncWriter) Dropped() int64 {
+	return atomic.LoadInt64(&w.dropped)
+}
+
+func (w *AsyncWriter) loop() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case item, ok := <-w.queue:
+			if !ok {
+				w.flush()
+				return
+			}
+			if _, err := w.buffer.Write(item); err != nil {
+				slog.Error("async log write failed", "err", err)
+			}
+		case <-ticker.C:
+			w.flush()
+		}
+	}
+}
+
+func (w *AsyncWriter) flush() {
+	if err := w.buffer.Flush(); err != nil {
+		slog.Error("async log flush failed", "err", err)
+	}
+}
+
+// Close 写完队列中剩余数据后关闭底层写入器
+func (w *AsyncWriter) Close() error {
+	w.closeOnce.Do(func() {
+		w.closeLock.Lock()
+		w.closed = true
+		close(w.queue)
+		w.closeLock.Unlock()
+		<-w.done
+
+		if closer, ok := w.writer.(io.Closer); ok {
+			w.closeErr = closer.Close()
+		}
+	})
+	return w.closeErr
+}
@@ -3,37 +3,32 @@ package http
 import (
 	"log/slog"
 	"net/http"
-	"sync/atomic"
 	"time"
 )
 
 type logger struct {
-	serialNo int64
 }
 
 func (s *logger) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
 	start := time.Now()
 
-	addr := req.Header.Get("X-Real-IP")
-	if addr == "" {
-		addr = req.Header.Get("X-Forwarded-For")
-		if addr == "" {
-			addr = req.RemoteAddr
-		}
-	}
-
-	curSerial := atomic.AddInt64(&s.serialNo, 1)
+	reqCtx := ctx.Context()
+	addr := clientIP(reqCtx, req)
+	requestID := GetRequestID(reqCtx)
 	if EnableTrace() {
-		slog.Info("request started", "serial", curSerial, "method", req.Method, "path", req.URL.Path, "addr", addr)
+		slog.InfoContext(reqCtx, "request started", "request_id", requestID, "method", req.Method, "path", req.URL.Path, "addr", addr)
 	}
 
 	rw := res.(ResponseWriter)
+	recorder := trackRequestTimeout(ctx)
 	ctx.Next()
 
 	elapseVal := time.Since(start)
-	if EnableTrace() {
-		slog.Info("request completed", "serial", curSerial, "status", rw.Status(), "status_text", http.StatusText(rw.Status()), "elapsed", elapseVal)
+	if recorder.timedOut.Load() {
+		slog.WarnContext(reqCtx, "request timeout", "request_id", requestID, "method", req.Method, "path", req.URL.Path, "addr", addr, "status", rw.Status(), "elapsed", elapseVal, "timeout", true)
+	} else if EnableTrace() {
+		slog.InfoContext(reqCtx, "request completed", "request_id", requestID, "status", rw.Status(), "status_text", http.StatusText(rw.Status()), "elapsed", elapseVal)
 	} else if elapseVal >= GetElapseThreshold() {
-		slog.Warn("slow request", "serial", curSerial, "method", req.Method, "path", req.URL.Path, "addr", addr, "status", rw.Status(), "elapsed", elapseVal)
+		slog.WarnContext(reqCtx, "slow request", "request_id", requestID, "method", req.Method, "path", req.URL.Path, "addr", addr, "status", rw.Status(), "elapsed", elapseVal)
 	}
 }
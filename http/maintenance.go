@@ -0,0 +1,221 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrMaintenance is returned while the server or route group is in maintenance mode
+var ErrMaintenance = errors.New("http: service under maintenance")
+
+// maxMaintenanceMessageSize 维护说明的最大长度, 超出部分截断
+const maxMaintenanceMessageSize = 1024
+
+// MaintenanceState 维护模式状态
+type MaintenanceState struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+}
+
+// Maintenance 维护模式中间件, 挂在 NewHTTPServer(WithMaintenance) 上作用于整个服务, 挂在分组上只作用于该分组
+//
+// 通过 Enable/Disable、管理路由或标记文件切换; 维护期间返回503和Retry-After,
+// 允许列表中的IP和豁免路径(默认健康检查路由)仍然放行.
+type Maintenance struct {
+	name          string
+	allow         []string
+	exemptPaths   []string
+	filePath      string
+	checkInterval time.Duration
+	retryAfter    time.Duration
+
+	allowSet  *CIDRSet
+	state     atomic.Pointer[MaintenanceState]
+	fileState atomic.Pointer[MaintenanceState]
+	lastCheck atomic.Int64
+}
+
+// MaintenanceOption configures a Maintenance
+type MaintenanceOption func(*Maintenance)
+
+// WithMaintenanceName sets the name written to logs, e.g. "billing"
+func WithMaintenanceName(name string) MaintenanceOption {
+	return func(m *Maintenance) {
+		m.name = name
+	}
+}
+
+// WithMaintenanceAllow adds networks that still pass during maintenance
+func WithMaintenanceAllow(cidrs ...string) MaintenanceOption {
+	return func(m *Maintenance) {
+		m.allow = append(m.allow, cidrs...)
+	}
+}
+
+// WithMaintenanceExemptPaths adds path prefixes that still pass, such as the admin route,
+// "/livez", "/readyz" and "/healthz" are always exempt
+func WithMaintenanceExemptPaths(paths ...string) MaintenanceOption {
+	return func(m *Maintenance) {
+		m.exemptPaths = append(m.exemptPaths, paths...)
+	}
+}
+
+// WithMaintenanceFile enables maintenance while the file exists, its content is used as the message
+func WithMaintenanceFile(filePath string) MaintenanceOption {
+	return func(m *Maintenance) {
+		m.filePath = filePath
+	}
+}
+
+// WithMaintenanceCheckInterval sets how often the flag file is checked, default 1 second
+func WithMaintenanceCheckInterval(interval time.Duration) MaintenanceOption {
+	return func(m *Maintenance) {
+		m.checkInterval = interval
+	}
+}
+
+// WithMaintenanceRetryAfter sets the Retry-After hint, default 5 minutes
+func WithMaintenanceRetryAfter(retryAfter time.Duration) MaintenanceOption {
+	return func(m *Maintenance) {
+		m.retryAfter = retryAfter
+	}
+}
+
+// NewMaintenance creates a new Maintenance, illegal allow networks are reported as error
+func NewMaintenance(opts ...MaintenanceOption) (*Maintenance, error) {
+	m := &Maintenance{
+		exemptPaths:   []string{"/livez", "/readyz", "/healthz"},
+		checkInterval: time.Second,
+		retryAfter:    5 * time.Minute,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	allowSet, allowErr := NewCIDRSet(m.allow...)
+	if allowErr != nil {
+		return nil, allowErr
+	}
+	m.allowSet = allowSet
+	m.state.Store(&MaintenanceState{})
+	m.loadFile()
+	return m, nil
+}
+
+// Enable 进入维护模式, message 会出现在503响应中
+func (s *Maintenance) Enable(message string) {
+	s.state.Store(&MaintenanceState{Enabled: true, Message: message})
+	slog.Info("maintenance enabled", "maintenance", s.name, "message", message)
+}
+
+// Disable 退出通过 Enable 进入的维护模式, 标记文件存在时仍处于维护模式
+func (s *Maintenance) Disable() {
+	s.state.Store(&MaintenanceState{})
+	slog.Info("maintenance disabled", "maintenance", s.name)
+}
+
+// State 当前生效的维护状态, Enable 设置的状态优先于标记文件
+func (s *Maintenance) State() MaintenanceState {
+	s.reloadIfDue()
+
+	if state := s.state.Load(); state.Enabled {
+		return *state
+	}
+	if state := s.fileState.Load(); state != nil {
+		return *state
+	}
+	return MaintenanceState{}
+}
+
+func (s *Maintenance) reloadIfDue() {
+	if s.filePath == "" {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	last := s.lastCheck.Load()
+	if now-last < int64(s.checkInterval) || !s.lastCheck.CompareAndSwap(last, now) {
+		return
+	}
+	s.loadFile()
+}
+
+// loadFile 读取标记文件, 文件不存在表示未处于维护模式
+func (s *Maintenance) loadFile() {
+	if s.filePath == "" {
+		return
+	}
+
+	fileVal, fileErr := os.Open(s.filePath)
+	if fileErr != nil {
+		if !errors.Is(fileErr, os.ErrNotExist) {
+			slog.Error("open maintenance file failed", "file", s.filePath, "err", fileErr)
+		}
+		if s.fileState.Swap(nil) != nil {
+			slog.Info("maintenance file removed", "maintenance", s.name, "file", s.filePath)
+		}
+		return
+	}
+	defer fileVal.Close()
+
+	content, _ := io.ReadAll(io.LimitReader(fileVal, maxMaintenanceMessageSize))
+	state := &MaintenanceState{Enabled: true, Message: strings.TrimSpace(string(content))}
+	if s.fileState.Swap(state) == nil {
+		slog.Info("maintenance file detected", "maintenance", s.name, "file", s.filePath)
+	}
+}
+
+func (s *Maintenance) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	state := s.State()
+	if !state.Enabled || matchPathPrefix(req.URL.Path, s.exemptPaths) {
+		ctx.Next()
+		return
+	}
+
+	if addr, ok := parseHopAddr(clientIP(ctx.Context(), req)); ok && s.allowSet.Contains(addr) {
+		ctx.Next()
+		return
+	}
+
+	err := ErrMaintenance
+	if state.Message != "" {
+		err = fmt.Errorf("%w: %s", ErrMaintenance, state.Message)
+	}
+	if seconds := ceilSeconds(s.retryAfter); seconds > 0 {
+		res.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+	RenderError(ctx.Context(), res, req, http.StatusServiceUnavailable, err)
+}
+
+// CreateMaintenanceRoute 创建维护模式管理路由, GET 返回当前状态, 其他方法按请求体
+// {"enabled": true, "message": "..."} 切换状态; 需要自行挂载鉴权中间件, 挂在维护范围内时要加入豁免路径
+func CreateMaintenanceRoute(uriPattern, method string, maintenance *Maintenance) Route {
+	return CreateRoute(uriPattern, method, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			state := &MaintenanceState{}
+			if err := json.NewDecoder(io.LimitReader(req.Body, 4096)).Decode(state); err != nil {
+				RenderError(ctx, res, req, http.StatusBadRequest, err)
+				return
+			}
+			if state.Enabled {
+				maintenance.Enable(state.Message)
+			} else {
+				maintenance.Disable()
+			}
+		}
+
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		res.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(res).Encode(maintenance.State())
+	})
+}
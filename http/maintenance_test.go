@@ -0,0 +1,72 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceToggle(t *testing.T) {
+	m, mErr := NewMaintenance(WithMaintenanceAllow("10.0.0.0/8"), WithMaintenanceRetryAfter(90*time.Second))
+	if mErr != nil {
+		t.Fatal(mErr)
+	}
+
+	get := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		return serveWithMiddleware(m, okHandler, req)
+	}
+
+	if w := get("/orders", "192.0.2.1:1234"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", w.Code)
+	}
+
+	m.Enable("upgrading database")
+	w := get("/orders", "192.0.2.1:1234")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "90" || !strings.Contains(w.Body.String(), "upgrading database") {
+		t.Fatalf("maintenance should reject, got %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+	if w = get("/orders", "10.1.2.3:1234"); w.Code != http.StatusOK {
+		t.Fatalf("allowed ip should pass, got %d", w.Code)
+	}
+	if w = get("/readyz", "192.0.2.1:1234"); w.Code != http.StatusOK {
+		t.Fatalf("health route should pass, got %d", w.Code)
+	}
+
+	m.Disable()
+	if w = get("/orders", "192.0.2.1:1234"); w.Code != http.StatusOK {
+		t.Fatalf("disabled maintenance should pass, got %d", w.Code)
+	}
+}
+
+func TestMaintenanceFileAndRoute(t *testing.T) {
+	flagPath := filepath.Join(t.TempDir(), "maintenance")
+	m, mErr := NewMaintenance(WithMaintenanceFile(flagPath), WithMaintenanceCheckInterval(0))
+	if mErr != nil {
+		t.Fatal(mErr)
+	}
+
+	if err := os.WriteFile(flagPath, []byte("  deploying\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if state := m.State(); !state.Enabled || state.Message != "deploying" {
+		t.Fatalf("flag file should enable maintenance, got %+v", state)
+	}
+	_ = os.Remove(flagPath)
+	if m.State().Enabled {
+		t.Fatal("removing flag file should disable maintenance")
+	}
+
+	route := CreateMaintenanceRoute("/admin/maintenance", PUT, m)
+	req := httptest.NewRequest(http.MethodPut, "/admin/maintenance", strings.NewReader(`{"enabled":true,"message":"api"}`))
+	w := httptest.NewRecorder()
+	route.Handler()(req.Context(), w, req)
+	if state := m.State(); !state.Enabled || state.Message != "api" || !strings.Contains(w.Body.String(), `"enabled":true`) {
+		t.Fatalf("admin route should enable maintenance, got %+v %q", state, w.Body.String())
+	}
+}
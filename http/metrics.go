@@ -0,0 +1,116 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/muidea/magicEngine/metrics"
+)
+
+// unmatchedRoute 未命中任何路由时使用的route标签, 避免原始路径撑爆标签基数
+const unmatchedRoute = "unmatched"
+
+type httpMetrics struct {
+	requests  *metrics.CounterVec
+	durations *metrics.HistogramVec
+	sizes     *metrics.HistogramVec
+	inFlight  *metrics.Gauge
+}
+
+var (
+	httpMetricsLock   sync.Mutex
+	httpMetricsLookup = map[*metrics.Registry]*httpMetrics{}
+)
+
+// getHTTPMetrics 同一个注册表上的多个Metrics中间件共享指标
+func getHTTPMetrics(registry *metrics.Registry) *httpMetrics {
+	httpMetricsLock.Lock()
+	defer httpMetricsLock.Unlock()
+
+	if val, ok := httpMetricsLookup[registry]; ok {
+		return val
+	}
+
+	val := &httpMetrics{
+		requests: metrics.NewCounterVec(metrics.Opts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests.",
+		}, "method", "route", "status"),
+		durations: metrics.NewHistogramVec(metrics.Opts{
+			Name: "http_request_duration_seconds",
+			Help: "HTTP request latency in seconds.",
+		}, metrics.DefBuckets, "method", "route", "status"),
+		sizes: metrics.NewHistogramVec(metrics.Opts{
+			Name: "http_response_size_bytes",
+			Help: "HTTP response body size in bytes.",
+		}, metrics.SizeBuckets, "method", "route"),
+		inFlight: metrics.NewGauge(metrics.Opts{
+			Name: "http_requests_in_flight",
+			Help: "Number of HTTP requests currently being served.",
+		}),
+	}
+	registry.MustRegister(val.requests, val.durations, val.sizes, val.inFlight)
+	httpMetricsLookup[registry] = val
+	return val
+}
+
+// Metrics HTTP指标中间件
+type Metrics struct {
+	registry *metrics.Registry
+	metrics  *httpMetrics
+}
+
+// MetricsOption configures a Metrics
+type MetricsOption func(*Metrics)
+
+// WithMetricsRegistry sets the registry the HTTP metrics are registered to, default metrics.Default()
+func WithMetricsRegistry(registry *metrics.Registry) MetricsOption {
+	return func(m *Metrics) {
+		m.registry = registry
+	}
+}
+
+// NewMetrics creates a new Metrics with optional configuration
+func NewMetrics(opts ...MetricsOption) *Metrics {
+	m := &Metrics{registry: metrics.Default()}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.metrics = getHTTPMetrics(m.registry)
+	return m
+}
+
+func (s *Metrics) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	start := time.Now()
+	recorder := trackRoutePattern(ctx)
+	rw := res.(ResponseWriter)
+
+	s.metrics.inFlight.Inc()
+	defer s.metrics.inFlight.Dec()
+
+	ctx.Next()
+
+	route := recorder.pattern
+	if route == "" {
+		route = unmatchedRoute
+	}
+	status := strconv.Itoa(rw.Status())
+	s.metrics.requests.With(req.Method, route, status).Inc()
+	s.metrics.durations.With(req.Method, route, status).Observe(time.Since(start).Seconds())
+	s.metrics.sizes.With(req.Method, route).Observe(float64(rw.Size()))
+}
+
+// CreateMetricsRoute 创建输出指标的路由, registry为nil时使用metrics.Default()
+func CreateMetricsRoute(uriPattern string, registry *metrics.Registry) Route {
+	if registry == nil {
+		registry = metrics.Default()
+	}
+
+	return CreateRoute(uriPattern, GET, func(_ context.Context, res http.ResponseWriter, req *http.Request) {
+		registry.ServeHTTP(res, req)
+	})
+}
@@ -0,0 +1,49 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/muidea/magicEngine/metrics"
+)
+
+func TestMetricsRecordsRoutePattern(t *testing.T) {
+	metricsRegistry := metrics.NewRegistry()
+
+	registry := NewRouteRegistry()
+	registry.AddHandler("/users/:id", GET, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		_, _ = res.Write([]byte("hello"))
+	})
+	registry.AddRoute(CreateMetricsRoute("/metrics", metricsRegistry))
+
+	chains := NewMiddleWareChains()
+	chains.Append(NewMetrics(WithMetricsRegistry(metricsRegistry)))
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		NewRequestContext(chains.GetHandlers(), registry, context.Background(), w, req).Run()
+		return w
+	}
+
+	serve("/users/1")
+	serve("/users/2")
+	serve("/missing")
+
+	body := serve("/metrics").Body.String()
+	expected := []string{
+		`http_requests_total{method="GET",route="/users/:id",status="200"} 2`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_response_size_bytes_sum{method="GET",route="/users/:id"} 10`,
+		`http_request_duration_seconds_count{method="GET",route="/users/:id",status="200"} 2`,
+		"http_requests_in_flight 1",
+	}
+	for _, val := range expected {
+		if !strings.Contains(body, val) {
+			t.Errorf("metrics output missing %q in:\n%s", val, body)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"context"
+
+	"github.com/muidea/magicCommon/foundation/helper"
+)
+
+// PrincipalKey context中保存已认证调用方的key
+type PrincipalKey struct{}
+
+// Principal 已认证的调用方
+type Principal struct {
+	// ID 调用方唯一标识
+	ID string
+	// Scheme 认证方式, 如 Bearer/Basic/APIKey
+	Scheme string
+	// Attributes 认证过程中得到的附加信息
+	Attributes map[string]any
+}
+
+// WithPrincipal 返回携带principal的context
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	if recorder, ok := helper.GetValueFromContext[*principalRecorder](ctx, principalRecorderKey{}); ok {
+		recorder.principal = principal
+	}
+	return context.WithValue(ctx, PrincipalKey{}, principal)
+}
+
+type principalRecorderKey struct{}
+
+// principalRecorder 记录内层中间件(包括路由中间件)认证出的调用方, 供外层中间件在 Next 之后读取
+type principalRecorder struct {
+	principal *Principal
+}
+
+// trackPrincipal 在context中登记recorder, 已登记时复用
+func trackPrincipal(ctx RequestContext) *principalRecorder {
+	if recorder, ok := helper.GetValueFromContext[*principalRecorder](ctx.Context(), principalRecorderKey{}); ok {
+		return recorder
+	}
+
+	recorder := &principalRecorder{}
+	ctx.Update(context.WithValue(ctx.Context(), principalRecorderKey{}, recorder))
+	return recorder
+}
+
+// GetPrincipal 获取context中已认证的调用方
+func GetPrincipal(ctx context.Context) (*Principal, bool) {
+	principal, ok := helper.GetValueFromContext[*Principal](ctx, PrincipalKey{})
+	return principal, ok && principal != nil
+}
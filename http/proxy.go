@@ -7,6 +7,8 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"strings"
+
+	"github.com/muidea/magicEngine/trace"
 )
 
 type ProxyErrorHandler func(http.ResponseWriter, *http.Request, error)
@@ -94,6 +96,11 @@ func (s *proxyRoute) applyTarget(req *http.Request) *url.URL {
 
 // ProxyHTTP proxies the current request to a dynamically resolved target URL.
 func ProxyHTTP(res http.ResponseWriter, req *http.Request, targetURL string, errorHandler ProxyErrorHandler) error {
+	return ProxyHTTPContext(req.Context(), res, req, targetURL, errorHandler)
+}
+
+// ProxyHTTPContext is like ProxyHTTP, ctx should be the handler's context so that the trace span and request ID are forwarded.
+func ProxyHTTPContext(ctx context.Context, res http.ResponseWriter, req *http.Request, targetURL string, errorHandler ProxyErrorHandler) error {
 	targetURI, err := url.Parse(targetURL)
 	if err != nil {
 		return err
@@ -112,6 +119,8 @@ func ProxyHTTP(res http.ResponseWriter, req *http.Request, targetURL string, err
 			proxyReq.Host = target.Host
 			proxyReq.URL.Path = target.Path
 			proxyReq.URL.RawQuery = target.RawQuery
+			PropagateRequestID(proxyReq.Context(), proxyReq.Header)
+			trace.Inject(proxyReq.Context(), proxyReq.Header)
 		},
 	}
 	proxy.ErrorHandler = func(res http.ResponseWriter, req *http.Request, err error) {
@@ -122,14 +131,16 @@ func ProxyHTTP(res http.ResponseWriter, req *http.Request, targetURL string, err
 		res.WriteHeader(http.StatusInternalServerError)
 		_, _ = res.Write([]byte(err.Error()))
 	}
-	proxy.ServeHTTP(res, req)
+	spanCtx, span := startProxySpan(ctx, req, target.Host)
+	proxy.ServeHTTP(res, req.WithContext(spanCtx))
+	endProxySpan(span, res)
 	return nil
 }
 
 // proxyFun 是实际处理请求转发的函数
-func (s *proxyRoute) proxyFun(_ context.Context, res http.ResponseWriter, req *http.Request) {
+func (s *proxyRoute) proxyFun(ctx context.Context, res http.ResponseWriter, req *http.Request) {
 	if s.parseErr != nil {
-		slog.Error("illegal proxy target URL", "url", s.targetURL, "err", ErrInvalidProxyTarget)
+		slog.ErrorContext(ctx, "illegal proxy target URL", "url", s.targetURL, "err", ErrInvalidProxyTarget)
 		return
 	}
 
@@ -152,7 +163,9 @@ func (s *proxyRoute) proxyFun(_ context.Context, res http.ResponseWriter, req *h
 		return
 	}
 	s.proxy.ErrorHandler = errorHandler
-	s.proxy.ServeHTTP(res, req)
+	spanCtx, span := startProxySpan(ctx, req, targetUri.Host)
+	s.proxy.ServeHTTP(res, req.WithContext(spanCtx))
+	endProxySpan(span, res)
 }
 
 // CreateProxyRoute 创建代理路由
@@ -176,6 +189,8 @@ func CreateProxyRoute(uriPattern, method, targetURL string, rewriteURL bool) Rou
 				req.URL.Host = target.Host
 				req.URL.Path = target.Path
 				req.URL.RawQuery = target.RawQuery
+				PropagateRequestID(req.Context(), req.Header)
+				trace.Inject(req.Context(), req.Header)
 			},
 		}
 		return route
@@ -188,6 +203,8 @@ func CreateProxyRoute(uriPattern, method, targetURL string, rewriteURL bool) Rou
 			req.URL.Host = target.Host
 			req.URL.Path = target.Path
 			req.URL.RawQuery = target.RawQuery
+			PropagateRequestID(req.Context(), req.Header)
+			trace.Inject(req.Context(), req.Header)
 		},
 	}
 	return route
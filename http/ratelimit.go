@@ -0,0 +1,363 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTooManyRequests is returned when a client exceeds its rate limit
+var ErrTooManyRequests = errors.New("http: too many requests")
+
+// RateLimitAlgorithm 限流算法
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket 令牌桶, 允许Burst大小的突发流量
+	TokenBucket RateLimitAlgorithm = iota
+	// SlidingWindow 滑动窗口计数
+	SlidingWindow
+)
+
+// RateLimitRule 限流规则, Window内最多允许Limit次请求
+type RateLimitRule struct {
+	Algorithm RateLimitAlgorithm
+	Limit     int
+	Window    time.Duration
+	// Burst 令牌桶容量, 默认等于Limit
+	Burst int
+}
+
+// RateLimitResult 单次限流判断结果
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset 配额完全恢复需要的时间
+	Reset time.Duration
+	// RetryAfter 被拒绝时建议的重试等待时间
+	RetryAfter time.Duration
+}
+
+// RateLimitStore 限流状态存储
+type RateLimitStore interface {
+	// Allow 按rule对key消耗一次配额
+	Allow(key string, rule RateLimitRule, now time.Time) (RateLimitResult, error)
+}
+
+// RateLimitKeyFunc 从请求中提取限流key, 返回空表示不限流
+type RateLimitKeyFunc func(ctx context.Context, req *http.Request) string
+
+// KeyByClientIP 按客户端IP限流
+func KeyByClientIP() RateLimitKeyFunc {
+	return func(ctx context.Context, req *http.Request) string {
+		return clientIP(ctx, req)
+	}
+}
+
+// KeyByHeader 按请求头限流
+func KeyByHeader(name string) RateLimitKeyFunc {
+	return func(_ context.Context, req *http.Request) string {
+		return req.Header.Get(name)
+	}
+}
+
+// KeyByPrincipal 按已认证的调用方限流, 未认证时按客户端IP限流
+func KeyByPrincipal() RateLimitKeyFunc {
+	return func(ctx context.Context, req *http.Request) string {
+		if principal, ok := GetPrincipal(ctx); ok {
+			return "principal:" + principal.ID
+		}
+		return clientIP(ctx, req)
+	}
+}
+
+// KeyByRoutePattern 按命中的路由规则限流, 需要作为路由中间件使用
+func KeyByRoutePattern() RateLimitKeyFunc {
+	return func(ctx context.Context, req *http.Request) string {
+		pattern := GetRoutePattern(ctx)
+		if pattern == "" {
+			pattern = req.URL.Path
+		}
+		return req.Method + " " + pattern
+	}
+}
+
+var rateLimitSerial int64
+
+// RateLimit 限流中间件
+type RateLimit struct {
+	name    string
+	rule    RateLimitRule
+	keyFunc RateLimitKeyFunc
+	store   RateLimitStore
+}
+
+// RateLimitOption configures a RateLimit
+type RateLimitOption func(*RateLimit)
+
+// WithRateLimitKey sets the function used to derive the limit key
+func WithRateLimitKey(keyFunc RateLimitKeyFunc) RateLimitOption {
+	return func(r *RateLimit) {
+		r.keyFunc = keyFunc
+	}
+}
+
+// WithRateLimitStore sets the state store
+func WithRateLimitStore(store RateLimitStore) RateLimitOption {
+	return func(r *RateLimit) {
+		r.store = store
+	}
+}
+
+// WithRateLimitName sets the key namespace, limiters sharing a name share quota
+func WithRateLimitName(name string) RateLimitOption {
+	return func(r *RateLimit) {
+		r.name = name
+	}
+}
+
+var (
+	defaultRateLimitStore     RateLimitStore
+	defaultRateLimitStoreOnce sync.Once
+)
+
+func getDefaultRateLimitStore() RateLimitStore {
+	defaultRateLimitStoreOnce.Do(func() {
+		defaultRateLimitStore = NewMemoryRateLimitStore(0, time.Minute)
+	})
+	return defaultRateLimitStore
+}
+
+func (r RateLimitRule) normalize() RateLimitRule {
+	if r.Limit <= 0 {
+		r.Limit = 1
+	}
+	if r.Window <= 0 {
+		r.Window = time.Second
+	}
+	if r.Burst <= 0 {
+		r.Burst = r.Limit
+	}
+	return r
+}
+
+// NewRateLimit creates a new RateLimit with optional configuration
+func NewRateLimit(rule RateLimitRule, opts ...RateLimitOption) *RateLimit {
+	r := &RateLimit{
+		name:    fmt.Sprintf("ratelimit-%d", atomic.AddInt64(&rateLimitSerial, 1)),
+		rule:    rule.normalize(),
+		keyFunc: KeyByClientIP(),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.store == nil {
+		r.store = getDefaultRateLimitStore()
+	}
+
+	return r
+}
+
+func (s *RateLimit) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	key := s.keyFunc(ctx.Context(), req)
+	if key == "" {
+		ctx.Next()
+		return
+	}
+
+	result, err := s.store.Allow(s.name+"|"+key, s.rule, time.Now())
+	if err != nil {
+		slog.WarnContext(ctx.Context(), "rate limit store failed", "name", s.name, "err", err)
+		ctx.Next()
+		return
+	}
+
+	header := res.Header()
+	header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	if !result.Allowed {
+		header.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+		RenderError(ctx.Context(), res, req, http.StatusTooManyRequests, ErrTooManyRequests)
+		return
+	}
+
+	ctx.Next()
+}
+
+func ceilSeconds(val time.Duration) int {
+	if val <= 0 {
+		return 0
+	}
+	return int(math.Ceil(val.Seconds()))
+}
+
+const defaultRateLimitShards = 32
+
+type rateLimitEntry struct {
+	// 令牌桶: tokens为剩余令牌; 滑动窗口: prevCount/curCount为前后两个窗口的计数
+	tokens      float64
+	prevCount   int
+	curCount    int
+	windowStart time.Time
+	last        time.Time
+	expireAt    time.Time
+}
+
+type rateLimitShard struct {
+	entries map[string]*rateLimitEntry
+	mu      sync.Mutex
+}
+
+// MemoryRateLimitStore 分片的内存限流存储, 过期状态由后台goroutine定期清理
+type MemoryRateLimitStore struct {
+	shards    []*rateLimitShard
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+// NewMemoryRateLimitStore 新建内存限流存储, shardCount<=0时使用默认分片数, gcInterval<=0时不启动清理
+func NewMemoryRateLimitStore(shardCount int, gcInterval time.Duration) *MemoryRateLimitStore {
+	if shardCount <= 0 {
+		shardCount = defaultRateLimitShards
+	}
+
+	store := &MemoryRateLimitStore{
+		shards:  make([]*rateLimitShard, shardCount),
+		closeCh: make(chan struct{}),
+	}
+	for idx := range store.shards {
+		store.shards[idx] = &rateLimitShard{entries: map[string]*rateLimitEntry{}}
+	}
+
+	if gcInterval > 0 {
+		go store.gcLoop(gcInterval)
+	}
+	return store
+}
+
+// Close 停止后台清理
+func (s *MemoryRateLimitStore) Close() {
+	s.closeOnce.Do(func() {
+		close(s.closeCh)
+	})
+}
+
+func (s *MemoryRateLimitStore) gcLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.closeCh:
+			return
+		case now := <-ticker.C:
+			s.evict(now)
+		}
+	}
+}
+
+func (s *MemoryRateLimitStore) evict(now time.Time) {
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		for key, entry := range shard.entries {
+			if now.After(entry.expireAt) {
+				delete(shard.entries, key)
+			}
+		}
+		shard.mu.Unlock()
+	}
+}
+
+func (s *MemoryRateLimitStore) getShard(key string) *rateLimitShard {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+	return s.shards[hash.Sum32()%uint32(len(s.shards))]
+}
+
+func (s *MemoryRateLimitStore) Allow(key string, rule RateLimitRule, now time.Time) (RateLimitResult, error) {
+	rule = rule.normalize()
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, ok := shard.entries[key]
+	if !ok || now.After(entry.expireAt) {
+		entry = &rateLimitEntry{tokens: float64(rule.Burst), windowStart: now, last: now}
+		shard.entries[key] = entry
+	}
+
+	var result RateLimitResult
+	switch rule.Algorithm {
+	case SlidingWindow:
+		result = entry.slidingWindow(rule, now)
+	default:
+		result = entry.tokenBucket(rule, now)
+	}
+	// 超过Reset+Window后状态等价于新建, 可以安全清理
+	entry.expireAt = now.Add(result.Reset + rule.Window)
+	return result, nil
+}
+
+func (e *rateLimitEntry) tokenBucket(rule RateLimitRule, now time.Time) RateLimitResult {
+	rate := float64(rule.Limit) / rule.Window.Seconds()
+	if elapsed := now.Sub(e.last).Seconds(); elapsed > 0 {
+		e.tokens = math.Min(float64(rule.Burst), e.tokens+elapsed*rate)
+	}
+	e.last = now
+
+	result := RateLimitResult{Limit: rule.Burst}
+	if e.tokens >= 1 {
+		e.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - e.tokens) / rate * float64(time.Second))
+	}
+	result.Remaining = int(e.tokens)
+	result.Reset = time.Duration((float64(rule.Burst) - e.tokens) / rate * float64(time.Second))
+	return result
+}
+
+func (e *rateLimitEntry) slidingWindow(rule RateLimitRule, now time.Time) RateLimitResult {
+	elapsed := now.Sub(e.windowStart)
+	if elapsed >= rule.Window {
+		windows := int(elapsed / rule.Window)
+		if windows == 1 {
+			e.prevCount = e.curCount
+		} else {
+			e.prevCount = 0
+		}
+		e.curCount = 0
+		e.windowStart = e.windowStart.Add(time.Duration(windows) * rule.Window)
+		elapsed = now.Sub(e.windowStart)
+	}
+
+	weight := 1 - float64(elapsed)/float64(rule.Window)
+	estimated := float64(e.prevCount)*weight + float64(e.curCount)
+
+	result := RateLimitResult{Limit: rule.Limit, Reset: rule.Window - elapsed}
+	if estimated+1 <= float64(rule.Limit) {
+		e.curCount++
+		estimated++
+		result.Allowed = true
+	} else if e.prevCount > 0 {
+		// 等待前一窗口的权重衰减到可以再放行一次
+		need := estimated + 1 - float64(rule.Limit)
+		result.RetryAfter = time.Duration(need / float64(e.prevCount) * float64(rule.Window))
+	} else {
+		result.RetryAfter = rule.Window - elapsed
+	}
+	result.Remaining = max(0, rule.Limit-int(math.Ceil(estimated)))
+	return result
+}
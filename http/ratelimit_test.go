@@ -0,0 +1,110 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimitStoreTokenBucket(t *testing.T) {
+	store := NewMemoryRateLimitStore(4, 0)
+	rule := RateLimitRule{Algorithm: TokenBucket, Limit: 2, Window: time.Second}
+	now := time.Now()
+
+	for idx := 0; idx < 2; idx++ {
+		if result, _ := store.Allow("k", rule, now); !result.Allowed {
+			t.Fatalf("request %d should be allowed", idx)
+		}
+	}
+	result, _ := store.Allow("k", rule, now)
+	if result.Allowed || result.RetryAfter <= 0 {
+		t.Fatalf("third request should be rejected with retry after, got %+v", result)
+	}
+
+	result, _ = store.Allow("k", rule, now.Add(500*time.Millisecond))
+	if !result.Allowed {
+		t.Fatal("token should be refilled after half window")
+	}
+}
+
+func TestMemoryRateLimitStoreSlidingWindow(t *testing.T) {
+	store := NewMemoryRateLimitStore(4, 0)
+	rule := RateLimitRule{Algorithm: SlidingWindow, Limit: 3, Window: time.Minute}
+	start := time.Now()
+
+	for idx := 0; idx < 3; idx++ {
+		if result, _ := store.Allow("k", rule, start); !result.Allowed {
+			t.Fatalf("request %d should be allowed", idx)
+		}
+	}
+	if result, _ := store.Allow("k", rule, start); result.Allowed || result.Remaining != 0 {
+		t.Fatalf("fourth request should be rejected, got %+v", result)
+	}
+
+	// 下一个窗口开始时前一窗口权重仍然较大
+	if result, _ := store.Allow("k", rule, start.Add(time.Minute+time.Second)); result.Allowed {
+		t.Fatal("request should still be limited by previous window weight")
+	}
+	if result, _ := store.Allow("k", rule, start.Add(time.Minute+40*time.Second)); !result.Allowed {
+		t.Fatal("request should be allowed after previous window decays")
+	}
+}
+
+func TestMemoryRateLimitStoreEvict(t *testing.T) {
+	store := NewMemoryRateLimitStore(1, 0)
+	rule := RateLimitRule{Limit: 1, Window: time.Second}
+	now := time.Now()
+	_, _ = store.Allow("k", rule, now)
+
+	store.evict(now.Add(time.Minute))
+	if len(store.shards[0].entries) != 0 {
+		t.Fatal("expired entries should be evicted")
+	}
+}
+
+func TestRateLimitMiddlewareHeaders(t *testing.T) {
+	limiter := NewRateLimit(RateLimitRule{Limit: 1, Window: time.Minute},
+		WithRateLimitStore(NewMemoryRateLimitStore(1, 0)),
+		WithRateLimitKey(KeyByHeader("X-Client")))
+	handler := func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/public", nil)
+	req.Header.Set("X-Client", "a")
+	w := serveWithMiddleware(limiter, handler, req)
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("unexpected first response %d %v", w.Code, w.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/public", nil)
+	req.Header.Set("X-Client", "a")
+	w = serveWithMiddleware(limiter, handler, req)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("second response = %d, want 429 with Retry-After", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/public", nil)
+	req.Header.Set("X-Client", "b")
+	w = serveWithMiddleware(limiter, handler, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("other client status = %d, want 200", w.Code)
+	}
+}
+
+func TestKeyByRoutePattern(t *testing.T) {
+	var got string
+	registry := NewRouteRegistry()
+	registry.AddHandler("/user/:id", GET, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		got = KeyByRoutePattern()(ctx, req)
+		res.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/user/12", nil)
+	registry.Handle(context.Background(), NewResponseWriter(httptest.NewRecorder()), req)
+	if got != "GET /user/:id" {
+		t.Fatalf("key = %q, want %q", got, "GET /user/:id")
+	}
+}
@@ -0,0 +1,481 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	DefaultRecorderCapacity    = 100
+	DefaultRecorderMaxBodySize = 64 * 1024
+
+	// RedactedValue 替换被脱敏内容的占位符
+	RedactedValue = "[REDACTED]"
+)
+
+// RecordedExchange 一次记录的请求和响应, XxxBodyUnredacted 表示该body格式(multipart、压缩、二进制等)无法脱敏,
+// 默认不保存
+type RecordedExchange struct {
+	StartedAt time.Time
+	Duration  time.Duration
+	RequestID string
+	ClientIP  string
+	Route     string
+
+	Method                string
+	URL                   string
+	Proto                 string
+	RequestHeader         http.Header
+	RequestBody           []byte
+	RequestBodyTruncated  bool
+	RequestBodyUnredacted bool
+
+	Status                 int
+	ResponseHeader         http.Header
+	ResponseBody           []byte
+	ResponseBodyTruncated  bool
+	ResponseBodyUnredacted bool
+	ResponseSize           int64
+}
+
+// RecorderFilter 决定是否保存一次请求, 在响应完成后调用
+type RecorderFilter func(record *RecordedExchange) bool
+
+// RecordRoutes 只记录命中这些路由规则的请求
+func RecordRoutes(patterns ...string) RecorderFilter {
+	return func(record *RecordedExchange) bool {
+		return slices.Contains(patterns, record.Route)
+	}
+}
+
+// RecordStatus 只记录状态码在 [minStatus, maxStatus] 之间的请求, 如 RecordStatus(500, 599)
+func RecordStatus(minStatus, maxStatus int) RecorderFilter {
+	return func(record *RecordedExchange) bool {
+		return record.Status >= minStatus && record.Status <= maxStatus
+	}
+}
+
+// RecordHeader 只记录请求头取值为value的请求, value为空时只要求请求头存在, 如 RecordHeader("X-Debug", "")
+func RecordHeader(name, value string) RecorderFilter {
+	return func(record *RecordedExchange) bool {
+		values, ok := record.RequestHeader[http.CanonicalHeaderKey(name)]
+		if !ok {
+			return false
+		}
+		return value == "" || slices.Contains(values, value)
+	}
+}
+
+// Recorder 调试用的请求/响应记录中间件, 把匹配的请求保存在环形缓冲区中, 可以导出为HAR并重放
+//
+// 请求体和响应体最多保存 maxBodySize 字节; 敏感请求头、查询参数、表单和JSON字段在保存前脱敏,
+// 无法脱敏的请求体和响应体(multipart、压缩、二进制等)默认不保存. 只应在排查问题时开启.
+type Recorder struct {
+	capacity       int
+	maxBodySize    int64
+	filters        []RecorderFilter
+	redactHeader   map[string]bool
+	redactFields   []string
+	redactor       func(record *RecordedExchange)
+	keepUnredacted bool
+	fieldPattern   *regexp.Regexp
+
+	mu      sync.Mutex
+	records []*RecordedExchange
+	next    int
+}
+
+// RecorderOption configures a Recorder
+type RecorderOption func(*Recorder)
+
+// WithRecorderCapacity sets how many exchanges are kept, default DefaultRecorderCapacity
+func WithRecorderCapacity(capacity int) RecorderOption {
+	return func(r *Recorder) {
+		r.capacity = capacity
+	}
+}
+
+// WithRecorderMaxBodySize sets the maximum bytes kept for each body, default DefaultRecorderMaxBodySize
+func WithRecorderMaxBodySize(size int64) RecorderOption {
+	return func(r *Recorder) {
+		r.maxBodySize = size
+	}
+}
+
+// WithRecorderFilter adds a filter, an exchange is kept only when all filters match
+func WithRecorderFilter(filter RecorderFilter) RecorderOption {
+	return func(r *Recorder) {
+		r.filters = append(r.filters, filter)
+	}
+}
+
+// WithRecorderRedactHeaders adds headers whose values are redacted,
+// Authorization, Proxy-Authorization, Cookie, Set-Cookie and X-Api-Key are always redacted
+func WithRecorderRedactHeaders(names ...string) RecorderOption {
+	return func(r *Recorder) {
+		for _, name := range names {
+			r.redactHeader[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
+
+// WithRecorderRedactFields adds query parameters, form fields and JSON string fields that are redacted,
+// password, secret, token, access_token, refresh_token and api_key are always redacted
+func WithRecorderRedactFields(names ...string) RecorderOption {
+	return func(r *Recorder) {
+		r.redactFields = append(r.redactFields, names...)
+	}
+}
+
+// WithRecorderRedactor sets a hook called after built-in redaction for custom rules
+func WithRecorderRedactor(redactor func(record *RecordedExchange)) RecorderOption {
+	return func(r *Recorder) {
+		r.redactor = redactor
+	}
+}
+
+// WithRecorderKeepUnredactedBodies keeps bodies that cannot be redacted, such as multipart or compressed bodies,
+// they are marked RequestBodyUnredacted/ResponseBodyUnredacted, default false drops them
+func WithRecorderKeepUnredactedBodies(keep bool) RecorderOption {
+	return func(r *Recorder) {
+		r.keepUnredacted = keep
+	}
+}
+
+// NewRecorder creates a new Recorder with optional configuration
+func NewRecorder(opts ...RecorderOption) *Recorder {
+	r := &Recorder{
+		capacity:    DefaultRecorderCapacity,
+		maxBodySize: DefaultRecorderMaxBodySize,
+		redactHeader: map[string]bool{
+			"Authorization":       true,
+			"Proxy-Authorization": true,
+			"Cookie":              true,
+			"Set-Cookie":          true,
+			"X-Api-Key":           true,
+		},
+		redactFields: []string{"password", "secret", "token", "access_token", "refresh_token", "api_key"},
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	r.capacity = max(r.capacity, 1)
+	quoted := make([]string, 0, len(r.redactFields))
+	for _, val := range r.redactFields {
+		quoted = append(quoted, regexp.QuoteMeta(val))
+	}
+	r.fieldPattern = regexp.MustCompile(`(?i)"(?:` + strings.Join(quoted, "|") + `)"\s*:\s*`)
+	return r
+}
+
+// Records 按时间顺序返回保存的记录
+func (s *Recorder) Records() []*RecordedExchange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.records) < s.capacity {
+		return slices.Clone(s.records)
+	}
+	return append(slices.Clone(s.records[s.next:]), s.records[:s.next]...)
+}
+
+// Len 当前保存的记录数
+func (s *Recorder) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.records)
+}
+
+// Clear 清空记录
+func (s *Recorder) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = nil
+	s.next = 0
+}
+
+func (s *Recorder) add(record *RecordedExchange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.records) < s.capacity {
+		s.records = append(s.records, record)
+		return
+	}
+	s.records[s.next] = record
+	s.next = (s.next + 1) % s.capacity
+}
+
+func (s *Recorder) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	recorder := trackRoutePattern(ctx)
+	record := &RecordedExchange{
+		StartedAt:     time.Now(),
+		RequestID:     GetRequestID(ctx.Context()),
+		ClientIP:      clientIP(ctx.Context(), req),
+		Method:        req.Method,
+		URL:           requestScheme(ctx.Context(), req) + "://" + requestHost(ctx.Context(), req) + req.URL.RequestURI(),
+		Proto:         req.Proto,
+		RequestHeader: req.Header.Clone(),
+	}
+	record.RequestBody, record.RequestBodyTruncated = s.captureRequestBody(req)
+
+	rw := &recordWriter{ResponseWriter: res, maxSize: s.maxBodySize}
+	restore, ok := replaceResponseWriter(ctx, rw)
+	if !ok {
+		ctx.Next()
+		return
+	}
+	defer func() {
+		restore()
+
+		record.Duration = time.Since(record.StartedAt)
+		record.Route = recorder.pattern
+		if record.Route == "" {
+			record.Route = GetRoutePattern(ctx.Context())
+		}
+		record.Status = rw.status
+		record.ResponseHeader = rw.header
+		record.ResponseBody = rw.body.Bytes()
+		record.ResponseBodyTruncated = rw.truncated
+		record.ResponseSize = rw.size
+		if record.Status == 0 {
+			// handler panic时由外层的Recovery输出500
+			record.Status = http.StatusInternalServerError
+		}
+
+		for _, filter := range s.filters {
+			if !filter(record) {
+				return
+			}
+		}
+		s.redact(record)
+		s.add(record)
+	}()
+
+	ctx.Next()
+}
+
+// captureRequestBody 预读最多maxBodySize字节, 再把预读内容拼回请求体, 不缓冲整个请求体
+func (s *Recorder) captureRequestBody(req *http.Request) ([]byte, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, false
+	}
+
+	content, _ := io.ReadAll(io.LimitReader(req.Body, s.maxBodySize+1))
+	req.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(content), req.Body), Closer: req.Body}
+	if int64(len(content)) > s.maxBodySize {
+		return slices.Clone(content[:s.maxBodySize]), true
+	}
+	return slices.Clone(content), false
+}
+
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+// redact 在保存前脱敏请求头、查询参数、表单和JSON字段
+func (s *Recorder) redact(record *RecordedExchange) {
+	for _, header := range []http.Header{record.RequestHeader, record.ResponseHeader} {
+		for key, values := range header {
+			if s.redactHeader[key] {
+				header[key] = slices.Repeat([]string{RedactedValue}, len(values))
+			}
+		}
+	}
+
+	if urlVal, urlErr := url.Parse(record.URL); urlErr == nil && urlVal.RawQuery != "" {
+		urlVal.RawQuery = s.redactValues(urlVal.RawQuery)
+		record.URL = urlVal.String()
+	}
+
+	record.RequestBody, record.RequestBodyUnredacted = s.redactBody(record.RequestHeader, record.RequestBody)
+	record.ResponseBody, record.ResponseBodyUnredacted = s.redactBody(record.ResponseHeader, record.ResponseBody)
+
+	if s.redactor != nil {
+		s.redactor(record)
+	}
+}
+
+// redactBody 脱敏未压缩的表单、JSON和纯文本, 其他格式无法脱敏, 返回true并按配置丢弃
+func (s *Recorder) redactBody(header http.Header, body []byte) ([]byte, bool) {
+	if len(body) == 0 {
+		return body, false
+	}
+
+	if encoding := strings.TrimSpace(header.Get("Content-Encoding")); encoding == "" || strings.EqualFold(encoding, "identity") {
+		mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+		switch {
+		case mediaType == "application/x-www-form-urlencoded":
+			return []byte(s.redactValues(string(body))), false
+		case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"), mediaType == "text/plain",
+			mediaType == "" && utf8.Valid(body):
+			return s.redactJSONFields(body), false
+		}
+	}
+
+	if s.keepUnredacted {
+		return body, true
+	}
+	return nil, true
+}
+
+// redactValues 逐个处理 key=value, 不依赖整体解析, 截断处的不完整转义也不会导致跳过脱敏
+func (s *Recorder) redactValues(rawQuery string) string {
+	pairs := strings.Split(rawQuery, "&")
+	for idx, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		if unescaped, unescapeErr := url.QueryUnescape(key); unescapeErr == nil {
+			key = unescaped
+		}
+		if slices.ContainsFunc(s.redactFields, func(val string) bool { return strings.EqualFold(val, key) }) {
+			pairs[idx] = url.QueryEscape(key) + "=" + url.QueryEscape(RedactedValue)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// redactJSONFields 把敏感字段的值(字符串、数字、数组或对象)替换为占位符,
+// 值在截断处没有结束时一直替换到末尾
+func (s *Recorder) redactJSONFields(body []byte) []byte {
+	locs := s.fieldPattern.FindAllIndex(body, -1)
+	if len(locs) == 0 {
+		return body
+	}
+
+	var buf bytes.Buffer
+	last := 0
+	for _, loc := range locs {
+		if loc[0] < last {
+			// 位于已替换的值内部
+			continue
+		}
+		buf.Write(body[last:loc[1]])
+		buf.WriteString(`"` + RedactedValue + `"`)
+		last = jsonValueEnd(body, loc[1])
+	}
+	buf.Write(body[last:])
+	return buf.Bytes()
+}
+
+// jsonValueEnd 返回从start开始的JSON值的结束位置, 值没有结束时返回len(data)
+func jsonValueEnd(data []byte, start int) int {
+	depth := 0
+	inString := false
+	for idx := start; idx < len(data); idx++ {
+		ch := data[idx]
+		if inString {
+			switch ch {
+			case '\\':
+				idx++
+			case '"':
+				inString = false
+				if depth == 0 {
+					return idx + 1
+				}
+			}
+			continue
+		}
+
+		switch ch {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth == 0 {
+				return idx
+			}
+			if depth--; depth == 0 {
+				return idx + 1
+			}
+		case ',', ' ', '\t', '\r', '\n':
+			if depth == 0 {
+				return idx
+			}
+		}
+	}
+	return len(data)
+}
+
+// recordWriter 透传响应, 同时保存状态码、响应头和最多maxSize字节的响应体
+type recordWriter struct {
+	http.ResponseWriter
+	header    http.Header
+	body      bytes.Buffer
+	status    int
+	size      int64
+	maxSize   int64
+	truncated bool
+}
+
+func (w *recordWriter) WriteHeader(code int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = code
+	w.header = w.ResponseWriter.Header().Clone()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recordWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if remain := w.maxSize - int64(w.body.Len()); remain < int64(len(data)) {
+		w.body.Write(data[:max(remain, 0)])
+		w.truncated = true
+	} else {
+		w.body.Write(data)
+	}
+
+	size, err := w.ResponseWriter.Write(data)
+	w.size += int64(size)
+	return size, err
+}
+
+func (w *recordWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *recordWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// CreateRecorderRoute 创建导出记录的管理路由, GET 返回HAR文件, DELETE 清空记录; 需要自行挂载鉴权中间件
+func CreateRecorderRoute(uriPattern, method string, recorder *Recorder) Route {
+	return CreateRoute(uriPattern, method, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodDelete {
+			recorder.Clear()
+			res.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		res.Header().Set("Content-Disposition", `attachment; filename="recordings.har"`)
+		res.Header().Set("Cache-Control", "no-store")
+		if err := WriteHAR(res, recorder.Records()); err != nil {
+			RenderError(ctx, res, req, http.StatusInternalServerError, err)
+		}
+	})
+}
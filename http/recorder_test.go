@@ -0,0 +1,157 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func echoHandler(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+	content, _ := io.ReadAll(req.Body)
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Set-Cookie", "session=abc")
+	if bytes.Contains(content, []byte("fail")) {
+		res.WriteHeader(http.StatusBadRequest)
+	}
+	_, _ = res.Write(content)
+}
+
+func TestRecorderCaptureAndRedact(t *testing.T) {
+	rec := NewRecorder(WithRecorderCapacity(2), WithRecorderMaxBodySize(64))
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/orders?token=t1&page=2", strings.NewReader(`{"password":"p@ss","item":"book"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	w := serveWithMiddleware(rec, echoHandler, req)
+	if w.Body.String() != `{"password":"p@ss","item":"book"}` {
+		t.Fatalf("recorder should not alter the response, got %q", w.Body.String())
+	}
+
+	records := rec.Records()
+	if len(records) != 1 {
+		t.Fatalf("expected one record, got %d", len(records))
+	}
+	record := records[0]
+	if record.Status != http.StatusOK || record.Route != "/orders" || record.RequestHeader.Get("Authorization") != RedactedValue ||
+		record.ResponseHeader.Get("Set-Cookie") != RedactedValue || strings.Contains(record.URL, "t1") || !strings.Contains(record.URL, "page=2") {
+		t.Fatalf("unexpected record %+v", record)
+	}
+	if string(record.RequestBody) != `{"password":"[REDACTED]","item":"book"}` || string(record.ResponseBody) != string(record.RequestBody) {
+		t.Fatalf("body should be redacted, got %q %q", record.RequestBody, record.ResponseBody)
+	}
+
+	large := strings.Repeat("x", 100)
+	serveWithMiddleware(rec, echoHandler, httptest.NewRequest(http.MethodPost, "http://example.com/large", strings.NewReader(large)))
+	serveWithMiddleware(rec, echoHandler, httptest.NewRequest(http.MethodPost, "http://example.com/third", nil))
+	records = rec.Records()
+	if len(records) != 2 || records[0].Route != "/large" || records[1].Route != "/third" {
+		t.Fatal("ring buffer should keep the latest records in order")
+	}
+	if !records[0].RequestBodyTruncated || len(records[0].RequestBody) != 64 || !records[0].ResponseBodyTruncated || records[0].ResponseSize != 100 {
+		t.Fatalf("large bodies should be truncated, got %+v", records[0])
+	}
+}
+
+func TestRecorderRedactsTruncatedAndNonStringSecrets(t *testing.T) {
+	rec := NewRecorder(WithRecorderMaxBodySize(40))
+	cases := []struct {
+		body, contentType, want string
+	}{
+		{`{"pin":1234,"secret":["a","b"],"id":7}`, "application/json", `{"pin":1234,"secret":"[REDACTED]","id":7}`},
+		{`{"token":{"v":"x"},"api_key":987,"n":1}`, "application/json", `{"token":"[REDACTED]","api_key":"[REDACTED]","n":1}`},
+		{`{"item":"book","password":"correct horse battery staple"}`, "application/json", `{"item":"book","password":"[REDACTED]"`},
+		{`{"item":"book","token":["abcdefghijklmnopqrstuvwxyz"]}`, "application/json", `{"item":"book","token":"[REDACTED]"`},
+		{`page=1&password=%41%42%43%44%45%46%47%48%49%4A%4B%4C`, "application/x-www-form-urlencoded", `page=1&password=%5BREDACTED%5D`},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/login", strings.NewReader(c.body))
+		req.Header.Set("Content-Type", c.contentType)
+		serveWithMiddleware(rec, echoHandler, req)
+
+		records := rec.Records()
+		if got := string(records[len(records)-1].RequestBody); got != c.want {
+			t.Errorf("body %q redacted to %q, want %q", c.body, got, c.want)
+		}
+	}
+}
+
+func TestRecorderDropsUnredactableBodies(t *testing.T) {
+	rec := NewRecorder()
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	_ = writer.WriteField("password", "hunter2")
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/login", bytes.NewReader(form.Bytes()))
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	serveWithMiddleware(rec, echoHandler, req)
+
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, _ = gw.Write([]byte(`{"password":"hunter2"}`))
+	_ = gw.Close()
+	req = httptest.NewRequest(http.MethodPost, "http://example.com/login", bytes.NewReader(gzipped.Bytes()))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	serveWithMiddleware(rec, echoHandler, req)
+
+	for _, record := range rec.Records() {
+		if record.RequestBody != nil || !record.RequestBodyUnredacted {
+			t.Errorf("unredactable body should be dropped and marked, got %q %v", record.RequestBody, record.RequestBodyUnredacted)
+		}
+	}
+}
+
+func TestRecorderFilters(t *testing.T) {
+	rec := NewRecorder(WithRecorderFilter(RecordStatus(400, 599)), WithRecorderFilter(RecordHeader("X-Debug", "")))
+
+	send := func(body string, debug bool) {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/orders", strings.NewReader(body))
+		if debug {
+			req.Header.Set("X-Debug", "1")
+		}
+		serveWithMiddleware(rec, echoHandler, req)
+	}
+	send("ok", true)
+	send("fail", false)
+	send("fail", true)
+	if rec.Len() != 1 {
+		t.Fatalf("only matching exchanges should be kept, got %d", rec.Len())
+	}
+}
+
+func TestRecorderHARAndReplay(t *testing.T) {
+	rec := NewRecorder(WithRecorderKeepUnredactedBodies(true))
+	serveWithMiddleware(rec, echoHandler, httptest.NewRequest(http.MethodPost, "http://example.com/orders", strings.NewReader("fail")))
+	serveWithMiddleware(rec, echoHandler, httptest.NewRequest(http.MethodPost, "http://example.com/orders", bytes.NewReader([]byte{0xff, 0x00})))
+
+	w := httptest.NewRecorder()
+	route := CreateRecorderRoute("/debug/recordings", GET, rec)
+	route.Handler()(context.Background(), w, httptest.NewRequest(http.MethodGet, "/debug/recordings", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"version": "1.2"`) {
+		t.Fatalf("unexpected har response %d %q", w.Code, w.Body.String())
+	}
+
+	records, readErr := ReadHAR(w.Body)
+	if readErr != nil || len(records) != 2 || !bytes.Equal(records[1].RequestBody, []byte{0xff, 0x00}) || !records[1].RequestBodyUnredacted {
+		t.Fatalf("har should round trip, got %v %+v", readErr, records)
+	}
+
+	registry := NewRouteRegistry()
+	registry.AddHandler("/orders", POST, echoHandler)
+	results, replayErr := ReplayRecords(context.Background(), registry, records)
+	if replayErr != nil || len(results) != 2 {
+		t.Fatalf("replay failed: %v", replayErr)
+	}
+	if !results[0].StatusMatched() || results[0].Status != http.StatusBadRequest || string(results[0].Body) != "fail" {
+		t.Fatalf("unexpected replay %d %q", results[0].Status, results[0].Body)
+	}
+	if results[1].Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("replay should keep response headers, got %v", results[1].Header)
+	}
+}
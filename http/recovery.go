@@ -2,7 +2,11 @@ package http
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"log/slog"
 	"net/http"
 	"os"
@@ -35,11 +39,15 @@ pre {
 </head><body>
 <h1>PANIC</h1>
 <pre style="font-weight: bold;">%s</pre>
+<pre>request id: %s</pre>
 <pre>%s</pre>
 </body>
 </html>`
 )
 
+// maxStackFrames 堆栈最多记录的帧数
+const maxStackFrames = 64
+
 var (
 	dunno     = []byte("???")
 	centerDot = []byte("·")
@@ -48,28 +56,37 @@ var (
 )
 
 // stack returns a nicely formated stack frame, skipping skip frames
-func stack(skip int) []byte {
+//
+// withSource 为true时读取源文件输出对应的代码行, 只应在开发环境使用.
+func stack(skip int, withSource bool) []byte {
+	pcs := make([]uintptr, maxStackFrames)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(skip+1, pcs)])
+
 	buf := new(bytes.Buffer) // the returned data
 	// As we loop, we open files and read them. These variables record the currently
 	// loaded file.
 	var lines [][]byte
 	var lastFile string
-	for i := skip; ; i++ { // Skip the expected number of frames
-		pc, file, line, ok := runtime.Caller(i)
-		if !ok {
-			break
-		}
-		// Print this much at least.  If we can't find the source, it won't show.
-		fmt.Fprintf(buf, "%s:%d (0x%x)\n", file, line, pc)
-		if file != lastFile {
-			data, err := os.ReadFile(file)
-			if err != nil {
-				continue
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(buf, "%s:%d (0x%x)\n", frame.File, frame.Line, frame.PC)
+		if withSource {
+			if frame.File != lastFile {
+				data, err := os.ReadFile(frame.File)
+				if err == nil {
+					lines = bytes.Split(data, []byte{'\n'})
+				} else {
+					lines = nil
+				}
+				lastFile = frame.File
 			}
-			lines = bytes.Split(data, []byte{'\n'})
-			lastFile = file
+			fmt.Fprintf(buf, "\t%s: %s\n", function(frame.Function), source(lines, frame.Line))
+		} else {
+			fmt.Fprintf(buf, "\t%s\n", function(frame.Function))
+		}
+		if !more {
+			break
 		}
-		fmt.Fprintf(buf, "\t%s: %s\n", function(pc), source(lines, line))
 	}
 	return buf.Bytes()
 }
@@ -83,13 +100,12 @@ func source(lines [][]byte, n int) []byte {
 	return bytes.TrimSpace(lines[n])
 }
 
-// function returns, if possible, the name of the function containing the PC.
-func function(pc uintptr) []byte {
-	fn := runtime.FuncForPC(pc)
-	if fn == nil {
+// function returns, if possible, the short name of the function.
+func function(fullName string) []byte {
+	if fullName == "" {
 		return dunno
 	}
-	name := []byte(fn.Name())
+	name := []byte(fullName)
 	// The name includes the path name to the package, which is unnecessary
 	// since the file name is already included.  Plus, it has center dots.
 	// That is, we see
@@ -108,32 +124,181 @@ func function(pc uintptr) []byte {
 	return name
 }
 
-type recovery struct {
+// PanicInfo 一次panic的详情, 传给渲染器和上报函数
+type PanicInfo struct {
+	Value     any
+	Stack     []byte
+	RequestID string
+	Method    string
+	Path      string
+	Route     string
+}
+
+// Error 返回panic值的文本
+func (s *PanicInfo) Error() string {
+	return fmt.Sprint(s.Value)
+}
+
+// PanicRenderer 输出panic时的响应, 只在响应尚未写出时调用
+type PanicRenderer func(ctx context.Context, res http.ResponseWriter, req *http.Request, info *PanicInfo)
+
+// PanicReporter 上报panic详情, 如发送到错误跟踪系统; 同步调用, 耗时操作需自行异步处理
+type PanicReporter func(ctx context.Context, req *http.Request, info *PanicInfo)
+
+// Recovery 捕获panic的中间件, 输出500响应并调用上报函数
+//
+// 默认在 Dev 环境输出带源码堆栈的HTML页面, 其他情况通过 RenderError 输出不含panic详情的错误,
+// 响应体都带请求ID. http.ErrAbortHandler 表示handler主动中断响应, 不记录堆栈也不上报.
+type Recovery struct {
+	renderer   PanicRenderer
+	reporters  []PanicReporter
+	repanic    bool
+	withSource bool
+}
+
+// RecoveryOption configures a Recovery
+type RecoveryOption func(*Recovery)
+
+// WithPanicRenderer sets the response renderer, e.g. JSONPanicRenderer or ProblemPanicRenderer for API routes
+func WithPanicRenderer(renderer PanicRenderer) RecoveryOption {
+	return func(r *Recovery) {
+		r.renderer = renderer
+	}
+}
+
+// WithPanicReporter adds a reporter called for every recovered panic
+func WithPanicReporter(reporter PanicReporter) RecoveryOption {
+	return func(r *Recovery) {
+		r.reporters = append(r.reporters, reporter)
+	}
+}
+
+// WithRepanicAbort re-panics http.ErrAbortHandler so net/http aborts the connection
+func WithRepanicAbort(repanic bool) RecoveryOption {
+	return func(r *Recovery) {
+		r.repanic = repanic
+	}
+}
+
+// WithPanicSource reads source files to show code lines in the stack, default only in Dev
+func WithPanicSource(withSource bool) RecoveryOption {
+	return func(r *Recovery) {
+		r.withSource = withSource
+	}
+}
+
+// NewRecovery creates a new Recovery with optional configuration
+func NewRecovery(opts ...RecoveryOption) *Recovery {
+	r := &Recovery{
+		renderer:   defaultPanicRenderer,
+		withSource: Env == Dev,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.renderer == nil {
+		r.renderer = defaultPanicRenderer
+	}
+	return r
 }
 
-// Recovery returns a middleware that recovers from any panics and writes a 500 if there was one.
-// While Martini is in development mode, Recovery will also output the panic as HTML.
-func (s *recovery) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+func (s *Recovery) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	recorder := trackRoutePattern(ctx)
 	defer func() {
-		if err := recover(); err != nil {
-			stack := stack(3)
-			slog.Error("panic recovered", "err", err, "stack", string(stack))
-
-			// respond with panic message while in development mode
-			var body []byte
-			if Env == Dev {
-				res.Header().Set("Content-Type", "text/html")
-				body = []byte(fmt.Sprintf(panicHTML, err, err, stack))
-			} else {
-				body = []byte("500 Internal Server Error")
+		val := recover()
+		if val == nil {
+			return
+		}
+
+		// 内层中间件替换的ResponseWriter没有机会恢复, 这里恢复为本层的ResponseWriter
+		if updater, ok := ctx.(ResponseWriterUpdater); ok {
+			updater.UpdateResponseWriter(res)
+		}
+
+		info := &PanicInfo{
+			Value:     val,
+			RequestID: GetRequestID(ctx.Context()),
+			Method:    req.Method,
+			Path:      req.URL.Path,
+			Route:     recorder.pattern,
+		}
+		if info.Route == "" {
+			info.Route = GetRoutePattern(ctx.Context())
+		}
+		if err, ok := val.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+			if s.repanic {
+				panic(val)
 			}
+			slog.DebugContext(ctx.Context(), "handler aborted", "path", req.URL.Path)
+		} else {
+			info.Stack = stack(3, s.withSource)
+			slog.ErrorContext(ctx.Context(), "panic recovered", "err", val, "stack", string(info.Stack))
 
-			res.WriteHeader(http.StatusInternalServerError)
-			if nil != body {
-				_, _ = res.Write(body)
+			for _, reporter := range s.reporters {
+				s.report(ctx.Context(), reporter, req, info)
 			}
 		}
+
+		if rw, ok := res.(ResponseWriter); ok && rw.Written() {
+			return
+		}
+		s.renderer(ctx.Context(), res, req, info)
 	}()
 
 	ctx.Next()
 }
+
+// report 调用上报函数, 上报函数自身的panic不影响响应
+func (s *Recovery) report(ctx context.Context, reporter PanicReporter, req *http.Request, info *PanicInfo) {
+	defer func() {
+		if val := recover(); val != nil {
+			slog.ErrorContext(ctx, "panic reporter failed", "err", val)
+		}
+	}()
+
+	reporter(ctx, req, info)
+}
+
+type panicBody struct {
+	Status    int    `json:"status"`
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// JSONPanicRenderer 输出 {"status", "error", "request_id"} JSON, 不包含panic详情
+func JSONPanicRenderer(_ context.Context, res http.ResponseWriter, _ *http.Request, info *PanicInfo) {
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+	res.WriteHeader(http.StatusInternalServerError)
+	_ = json.NewEncoder(res).Encode(&panicBody{
+		Status:    http.StatusInternalServerError,
+		Error:     http.StatusText(http.StatusInternalServerError),
+		RequestID: info.RequestID,
+	})
+}
+
+// ProblemPanicRenderer 按 RFC 9457 输出 application/problem+json, title 按请求语言本地化, 不包含panic详情
+func ProblemPanicRenderer(ctx context.Context, res http.ResponseWriter, _ *http.Request, info *PanicInfo) {
+	writeProblem(res, &problemDetails{
+		Type:      "about:blank",
+		Title:     ProblemTitle(ctx, http.StatusInternalServerError),
+		Status:    http.StatusInternalServerError,
+		Instance:  info.Path,
+		RequestID: info.RequestID,
+	})
+}
+
+// defaultPanicRenderer Dev 环境对非JSON客户端输出带堆栈的HTML, 其余交给 RenderError, 不暴露panic详情
+func defaultPanicRenderer(ctx context.Context, res http.ResponseWriter, req *http.Request, info *PanicInfo) {
+	if Env != Dev || acceptsJSON(req) {
+		RenderError(ctx, res, req, http.StatusInternalServerError, nil)
+		return
+	}
+
+	message := html.EscapeString(info.Error())
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	res.WriteHeader(http.StatusInternalServerError)
+	_, _ = fmt.Fprintf(res, panicHTML, message, message, html.EscapeString(info.RequestID), html.EscapeString(string(info.Stack)))
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func panicHandler(val any) RouteHandleFunc {
+	return func(context.Context, http.ResponseWriter, *http.Request) {
+		panic(val)
+	}
+}
+
+func TestRecoveryRendererAndReporter(t *testing.T) {
+	var reported *PanicInfo
+	svr := NewHTTPServer(WithRecovery(
+		WithPanicRenderer(ProblemPanicRenderer),
+		WithPanicReporter(func(_ context.Context, _ *http.Request, info *PanicInfo) { reported = info }),
+		WithPanicReporter(func(context.Context, *http.Request, *PanicInfo) { panic("reporter bug") }),
+	))
+	registry := NewRouteRegistry()
+	registry.AddHandler("/orders/:id", GET, panicHandler("boom"))
+	svr.Bind(registry)
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	svr.(http.Handler).ServeHTTP(w, req)
+
+	problem := map[string]any{}
+	_ = json.NewDecoder(w.Body).Decode(&problem)
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Type") != "application/problem+json" ||
+		problem["request_id"] != "req-1" || problem["instance"] != "/orders/1" {
+		t.Fatalf("unexpected response %d %v %v", w.Code, w.Header(), problem)
+	}
+	if reported == nil || reported.Error() != "boom" || reported.RequestID != "req-1" || reported.Route != "/orders/:id" ||
+		!strings.Contains(string(reported.Stack), "recovery_test.go") {
+		t.Fatalf("unexpected report %+v", reported)
+	}
+}
+
+func TestRecoveryDefaultRenderer(t *testing.T) {
+	rc := NewRecovery(WithPanicSource(false))
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+	req.Header.Set("Accept", "application/json")
+	w := serveWithMiddleware(rc, panicHandler("secret detail"), req)
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "secret detail") ||
+		!strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("unexpected json response %d %q", w.Code, w.Body.String())
+	}
+
+	stackVal := string(stack(0, false))
+	if !strings.Contains(stackVal, "TestRecoveryDefaultRenderer") || strings.Contains(stackVal, "stack(0, false)") {
+		t.Fatalf("stack without source should only list frames, got %q", stackVal)
+	}
+	if !strings.Contains(string(stack(0, true)), "stack(0, true)") {
+		t.Fatal("stack with source should include code lines")
+	}
+}
+
+func TestRecoveryAbortHandler(t *testing.T) {
+	reported := false
+	rc := NewRecovery(WithPanicReporter(func(context.Context, *http.Request, *PanicInfo) { reported = true }))
+	req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+	abortHandler := func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		_, _ = res.Write([]byte("partial"))
+		panic(http.ErrAbortHandler)
+	}
+	if w := serveWithMiddleware(rc, abortHandler, req); w.Code != http.StatusOK || w.Body.String() != "partial" || reported {
+		t.Fatalf("aborted handler should not be rendered or reported, got %d %q", w.Code, w.Body.String())
+	}
+
+	defer func() {
+		if val := recover(); val != http.ErrAbortHandler {
+			t.Fatalf("expected repanic of ErrAbortHandler, got %v", val)
+		}
+	}()
+	serveWithMiddleware(NewRecovery(WithRepanicAbort(true)), panicHandler(http.ErrAbortHandler), req)
+}
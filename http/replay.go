@@ -0,0 +1,80 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+)
+
+// ReplayResult 一条记录的重放结果
+type ReplayResult struct {
+	Record *RecordedExchange
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// StatusMatched 重放的状态码与记录一致
+func (s *ReplayResult) StatusMatched() bool {
+	return s.Status == s.Record.Status
+}
+
+// replayWriter 在内存中收集重放的响应
+type replayWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *replayWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *replayWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *replayWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(data)
+}
+
+// ReplayRecords 把记录的请求依次交给registry处理, 用于在测试中复现问题
+//
+// middlewares 在路由之前执行, 如 NewRequestID(); 脱敏的内容按占位符原样发送, 需要时先修改记录,
+// 被截断的请求体只重放已保存的部分.
+func ReplayRecords(ctx context.Context, registry RouteRegistry, records []*RecordedExchange, middlewares ...MiddleWareHandler) ([]*ReplayResult, error) {
+	chains := NewMiddleWareChains()
+	for _, val := range middlewares {
+		chains.Append(val)
+	}
+
+	results := make([]*ReplayResult, 0, len(records))
+	for _, record := range records {
+		req, reqErr := http.NewRequestWithContext(ctx, record.Method, record.URL, bytes.NewReader(record.RequestBody))
+		if reqErr != nil {
+			return results, reqErr
+		}
+		req.Header = record.RequestHeader.Clone()
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		req.ContentLength = int64(len(record.RequestBody))
+		if record.ClientIP != "" {
+			req.RemoteAddr = net.JoinHostPort(record.ClientIP, "0")
+		}
+
+		res := &replayWriter{header: http.Header{}}
+		NewRequestContext(chains.GetHandlers(), registry, ctx, res, req).Run()
+		if res.status == 0 {
+			res.status = http.StatusOK
+		}
+		results = append(results, &ReplayResult{Record: record, Status: res.status, Header: res.header, Body: res.body.Bytes()})
+	}
+	return results, nil
+}
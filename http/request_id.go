@@ -0,0 +1,167 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/muidea/magicCommon/foundation/helper"
+	"github.com/muidea/magicCommon/foundation/util"
+)
+
+// RequestIDHeader 请求ID头
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+// RequestIDKey context中保存请求ID的key
+type RequestIDKey struct{}
+
+// WithRequestID 返回携带请求ID的context
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, RequestIDKey{}, requestID)
+}
+
+// requestIDHeaderKey context中保存请求ID头名称的key, 由 RequestID 中间件按 WithRequestIDHeader 写入
+type requestIDHeaderKey struct{}
+
+// GetRequestIDHeader 获取当前请求使用的请求ID头, 未经过 RequestID 中间件时返回 RequestIDHeader
+func GetRequestIDHeader(ctx context.Context) string {
+	if header, ok := helper.GetValueFromContext[string](ctx, requestIDHeaderKey{}); ok && header != "" {
+		return header
+	}
+	return RequestIDHeader
+}
+
+// GetRequestID 获取context中的请求ID, 不存在时返回空
+func GetRequestID(ctx context.Context) string {
+	requestID, _ := helper.GetValueFromContext[string](ctx, RequestIDKey{})
+	return requestID
+}
+
+// RequestID 请求ID中间件, 接受或生成请求ID, 写入context并在响应中回显
+type RequestID struct {
+	header    string
+	generator func() string
+	trust     bool
+}
+
+// RequestIDOption configures a RequestID
+type RequestIDOption func(*RequestID)
+
+// WithRequestIDHeader sets the header used to read and echo the request ID
+func WithRequestIDHeader(header string) RequestIDOption {
+	return func(r *RequestID) {
+		r.header = header
+	}
+}
+
+// WithRequestIDGenerator sets the function used to generate new request IDs
+func WithRequestIDGenerator(generator func() string) RequestIDOption {
+	return func(r *RequestID) {
+		r.generator = generator
+	}
+}
+
+// WithRequestIDTrust sets whether an incoming request ID is accepted
+func WithRequestIDTrust(trust bool) RequestIDOption {
+	return func(r *RequestID) {
+		r.trust = trust
+	}
+}
+
+// NewRequestID creates a new RequestID with optional configuration
+func NewRequestID(opts ...RequestIDOption) *RequestID {
+	r := &RequestID{
+		header:    RequestIDHeader,
+		generator: util.NewUUID,
+		trust:     true,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+func (s *RequestID) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	requestID := ""
+	if s.trust {
+		requestID = req.Header.Get(s.header)
+	}
+	if !validRequestID(requestID) {
+		requestID = s.generator()
+	}
+
+	// 同步写回请求头, 代理转发时会带给上游
+	req.Header.Set(s.header, requestID)
+	res.Header().Set(s.header, requestID)
+
+	curCtx := ctx.Context()
+	ctx.Update(WithRequestID(context.WithValue(curCtx, requestIDHeaderKey{}, s.header), requestID))
+	ctx.Next()
+	ctx.Update(curCtx)
+}
+
+// validRequestID 只接受长度有限的可见ASCII字符, 避免日志注入
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for idx := 0; idx < len(requestID); idx++ {
+		if requestID[idx] < 0x21 || requestID[idx] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// PropagateRequestID 按当前请求使用的请求ID头, 把context中的请求ID写入发往上游的请求头, 已存在时保持不变
+func PropagateRequestID(ctx context.Context, header http.Header) {
+	name := GetRequestIDHeader(ctx)
+	if header.Get(name) != "" {
+		return
+	}
+	if requestID := GetRequestID(ctx); requestID != "" {
+		header.Set(name, requestID)
+	}
+}
+
+type requestIDHandler struct {
+	next slog.Handler
+}
+
+// NewRequestIDHandler 包装slog.Handler, 对携带请求ID的context输出request_id字段
+//
+//	slog.SetDefault(slog.New(engine.NewRequestIDHandler(slog.NewTextHandler(os.Stderr, nil))))
+func NewRequestIDHandler(next slog.Handler) slog.Handler {
+	return &requestIDHandler{next: next}
+}
+
+func (h *requestIDHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *requestIDHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := GetRequestID(ctx); requestID != "" {
+		exist := false
+		record.Attrs(func(attr slog.Attr) bool {
+			exist = attr.Key == "request_id"
+			return !exist
+		})
+		if !exist {
+			record = record.Clone()
+			record.AddAttrs(slog.String("request_id", requestID))
+		}
+	}
+	return h.next.Handle(ctx, record)
+}
+
+func (h *requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &requestIDHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *requestIDHandler) WithGroup(name string) slog.Handler {
+	return &requestIDHandler{next: h.next.WithGroup(name)}
+}
@@ -0,0 +1,133 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDGeneratesAndEchoes(t *testing.T) {
+	var gotID string
+	req := httptest.NewRequest(http.MethodGet, "/id", nil)
+	w := serveWithMiddleware(NewRequestID(), func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		gotID = GetRequestID(ctx)
+		res.WriteHeader(http.StatusOK)
+	}, req)
+
+	if gotID == "" {
+		t.Fatal("expected generated request id in context")
+	}
+	if w.Header().Get(RequestIDHeader) != gotID {
+		t.Fatalf("echoed id = %q, want %q", w.Header().Get(RequestIDHeader), gotID)
+	}
+}
+
+func TestRequestIDAcceptsValidIncomingID(t *testing.T) {
+	var gotID string
+	handler := func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		gotID = GetRequestID(ctx)
+		res.WriteHeader(http.StatusOK)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/id", nil)
+	req.Header.Set(RequestIDHeader, "trace-123")
+	serveWithMiddleware(NewRequestID(), handler, req)
+	if gotID != "trace-123" {
+		t.Fatalf("request id = %q, want incoming id", gotID)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/id", nil)
+	req.Header.Set(RequestIDHeader, "bad id\nwith newline")
+	serveWithMiddleware(NewRequestID(), handler, req)
+	if gotID == "" || strings.Contains(gotID, " ") {
+		t.Fatalf("invalid incoming id should be replaced, got %q", gotID)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/id", nil)
+	req.Header.Set(RequestIDHeader, "trace-123")
+	serveWithMiddleware(NewRequestID(WithRequestIDTrust(false), WithRequestIDGenerator(func() string { return "local" })), handler, req)
+	if gotID != "local" {
+		t.Fatalf("untrusted incoming id should be replaced, got %q", gotID)
+	}
+}
+
+func TestRequestIDHandlerAddsAttr(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewRequestIDHandler(slog.NewTextHandler(&buf, nil)))
+
+	log.InfoContext(WithRequestID(context.Background(), "abc"), "hello")
+	if !strings.Contains(buf.String(), "request_id=abc") {
+		t.Fatalf("log line missing request id: %q", buf.String())
+	}
+
+	buf.Reset()
+	log.InfoContext(WithRequestID(context.Background(), "abc"), "hello", "request_id", "abc")
+	if strings.Count(buf.String(), "request_id=") != 1 {
+		t.Fatalf("request id should not be duplicated: %q", buf.String())
+	}
+}
+
+func TestProxyRouteForwardsRequestID(t *testing.T) {
+	var gotID string
+	oldTransport := http.DefaultTransport
+	http.DefaultTransport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		gotID = req.Header.Get(RequestIDHeader)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader("ok")),
+		}, nil
+	})
+	defer func() {
+		http.DefaultTransport = oldTransport
+	}()
+
+	route := CreateProxyRoute("/gateway", http.MethodGet, "https://backend.example/target", true)
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/gateway", nil)
+	route.Handler()(WithRequestID(context.Background(), "proxy-id"), NewResponseWriter(httptest.NewRecorder()), req)
+	if gotID != "proxy-id" {
+		t.Fatalf("upstream request id = %q, want %q", gotID, "proxy-id")
+	}
+}
+
+func TestProxyForwardsConfiguredRequestIDHeader(t *testing.T) {
+	var upstreamHeader http.Header
+	oldTransport := http.DefaultTransport
+	http.DefaultTransport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		upstreamHeader = req.Header.Clone()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader("ok")),
+		}, nil
+	})
+	defer func() {
+		http.DefaultTransport = oldTransport
+	}()
+
+	proxyHandler := func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		if GetRequestIDHeader(ctx) != "X-Correlation-ID" {
+			t.Errorf("request id header = %q", GetRequestIDHeader(ctx))
+		}
+		outReq := req.Clone(ctx)
+		outReq.Header.Del("X-Correlation-ID")
+		if err := ProxyHTTPContext(ctx, res, outReq, "https://backend.example/target", nil); err != nil {
+			t.Errorf("ProxyHTTP failed: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/gateway", nil)
+	req.Header.Set("X-Correlation-ID", "corr-1")
+	serveWithMiddleware(NewRequestID(WithRequestIDHeader("X-Correlation-ID")), proxyHandler, req)
+	if upstreamHeader.Get("X-Correlation-ID") != "corr-1" || upstreamHeader.Get(RequestIDHeader) != "" {
+		t.Fatalf("upstream should receive only the configured header, got %v", upstreamHeader)
+	}
+	if GetRequestIDHeader(context.Background()) != RequestIDHeader {
+		t.Fatal("default request id header should be X-Request-ID")
+	}
+}
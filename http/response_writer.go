@@ -52,5 +52,12 @@ func (rw *responseWriter) Written() bool {
 }
 
 func (rw *responseWriter) Flush() {
-	rw.responseWriter.(http.Flusher).Flush()
+	if flusher, ok := rw.responseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap 返回底层的http.ResponseWriter, 供http.ResponseController使用
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.responseWriter
 }
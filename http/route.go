@@ -6,8 +6,11 @@ import (
 	"log/slog"
 	"net/http"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
+
+	"github.com/muidea/magicCommon/foundation/helper"
 )
 
 // 基本HTTP行为定义
@@ -26,8 +29,39 @@ const (
 	DynamicRawUriPattern = "X-Mp-Engine-Dynamic-Raw-Uri-Pattern"
 )
 
+// RawUriPattern 路由匹配后, context中保存命中路由的uri规则(包含ApiVersion前缀)
 type RawUriPattern struct{}
 
+// GetRoutePattern 获取当前请求命中的路由规则, 未匹配路由时返回空
+func GetRoutePattern(ctx context.Context) string {
+	pattern, _ := helper.GetValueFromContext[string](ctx, RawUriPattern{})
+	return pattern
+}
+
+type routePatternRecorderKey struct{}
+
+// routePatternRecorder 让路由匹配之前的全局中间件在ctx.Next()返回后拿到命中的路由规则
+type routePatternRecorder struct {
+	pattern string
+}
+
+// trackRoutePattern 在context中登记recorder, 已登记时复用
+func trackRoutePattern(ctx RequestContext) *routePatternRecorder {
+	if recorder, ok := helper.GetValueFromContext[*routePatternRecorder](ctx.Context(), routePatternRecorderKey{}); ok {
+		return recorder
+	}
+
+	recorder := &routePatternRecorder{}
+	ctx.Update(context.WithValue(ctx.Context(), routePatternRecorderKey{}, recorder))
+	return recorder
+}
+
+func recordRoutePattern(ctx context.Context, pattern string) {
+	if recorder, ok := helper.GetValueFromContext[*routePatternRecorder](ctx, routePatternRecorderKey{}); ok {
+		recorder.pattern = pattern
+	}
+}
+
 // Route 路由接口
 type Route interface {
 	// Method 路由行为GET/PUT/POST/DELETE
@@ -85,7 +119,8 @@ func CreateRoute(uriPattern, method string, handler func(context.Context, http.R
 
 // PatternFilter route filter
 type PatternFilter struct {
-	regex *regexp.Regexp
+	pattern string
+	regex   *regexp.Regexp
 }
 
 var routeReg1 = regexp.MustCompile(`:[^/#?()\.\\]+`)
@@ -93,7 +128,7 @@ var routeReg2 = regexp.MustCompile(`\*\*`)
 
 // NewPatternFilter new route filter
 func NewPatternFilter(routeUriPattern string) *PatternFilter {
-	filter := &PatternFilter{}
+	filter := &PatternFilter{pattern: routeUriPattern}
 	pattern := routeReg1.ReplaceAllStringFunc(routeUriPattern, func(m string) string {
 		return fmt.Sprintf(`(?P<%s>[^/#?]+)`, m[1:])
 	})
@@ -282,7 +317,9 @@ func (s *routeRegistry) Handle(ctx context.Context, res http.ResponseWriter, req
 	var routeCtx RequestContext
 	for _, val := range routeSlice {
 		if val.match(req.URL.Path) {
-			routeCtx = NewRouteContext(ctx, val.middlewareList, val.route, res, req)
+			recordRoutePattern(ctx, val.patternFilter.pattern)
+			patternCtx := context.WithValue(ctx, RawUriPattern{}, val.patternFilter.pattern)
+			routeCtx = NewRouteContext(patternCtx, val.middlewareList, val.route, res, req)
 			break
 		}
 	}
@@ -292,8 +329,28 @@ func (s *routeRegistry) Handle(ctx context.Context, res http.ResponseWriter, req
 		return
 	}
 
-	http.NotFound(res, req)
-	//http.Redirect(res, req, "/404.html", http.StatusMovedPermanently)
+	if allowMethods := s.allowedMethods(req.URL.Path); len(allowMethods) > 0 {
+		res.Header().Set("Allow", strings.Join(allowMethods, ", "))
+		RenderError(ctx, res, req, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		return
+	}
+
+	RenderError(ctx, res, req, http.StatusNotFound, ErrURLNotFound)
+}
+
+// allowedMethods 返回可以匹配uriPath的其他方法, 用于405响应的Allow头
+func (s *routeRegistry) allowedMethods(uriPath string) []string {
+	s.routesLock.RLock()
+	defer s.routesLock.RUnlock()
+
+	var methods []string
+	for method, routeSlice := range s.routes {
+		if slices.ContainsFunc(*routeSlice, func(val *routeItem) bool { return val.match(uriPath) }) {
+			methods = append(methods, method)
+		}
+	}
+	slices.Sort(methods)
+	return methods
 }
 
 func (s *routeRegistry) ExistRoute(rt Route) bool {
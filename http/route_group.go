@@ -0,0 +1,160 @@
+package http
+
+import (
+	"path"
+	"strings"
+	"sync"
+)
+
+// RouteGroup 路由分组, 组内路由共享uri前缀和中间件
+type RouteGroup interface {
+	// Prefix 分组uri前缀
+	Prefix() string
+	// Use 增加分组中间件, 只对之后注册的路由生效
+	Use(handler MiddleWareHandler)
+	// Group 创建子分组, 继承当前分组的前缀和中间件
+	Group(prefix string, filters ...MiddleWareHandler) RouteGroup
+	// AddRoute 增加路由
+	AddRoute(rt Route, filters ...MiddleWareHandler)
+	// AddHandler 增加Handler
+	AddHandler(uriPattern, method string, handler RouteHandleFunc, filters ...MiddleWareHandleFunc)
+	// RemoveRoute 清除路由
+	RemoveRoute(rt Route)
+}
+
+type groupRoute struct {
+	Route
+	pattern string
+}
+
+func (s *groupRoute) Pattern() string {
+	return s.pattern
+}
+
+type routeGroup struct {
+	registry       RouteRegistry
+	parent         *routeGroup
+	prefix         string
+	middlewareList []MiddleWareHandler
+	middlewareLock sync.RWMutex
+
+	// versions 组内(含子分组)路由注册时的ApiVersion
+	versions    map[string]struct{}
+	versionLock sync.RWMutex
+}
+
+// NewRouteGroup 新建路由分组, 组内路由注册到registry
+func NewRouteGroup(registry RouteRegistry, prefix string, filters ...MiddleWareHandler) RouteGroup {
+	return &routeGroup{
+		registry:       registry,
+		prefix:         joinGroupPrefix("", prefix),
+		middlewareList: append([]MiddleWareHandler{}, filters...),
+	}
+}
+
+func joinGroupPrefix(parent, prefix string) string {
+	ret := path.Join("/", parent, prefix)
+	if ret == "/" {
+		return ""
+	}
+	return ret
+}
+
+func (s *routeGroup) Prefix() string {
+	return s.prefix
+}
+
+func (s *routeGroup) Use(handler MiddleWareHandler) {
+	s.middlewareLock.Lock()
+	defer s.middlewareLock.Unlock()
+
+	s.middlewareList = append(s.middlewareList, handler)
+}
+
+func (s *routeGroup) getMiddlewares() []MiddleWareHandler {
+	s.middlewareLock.RLock()
+	defer s.middlewareLock.RUnlock()
+
+	return append([]MiddleWareHandler{}, s.middlewareList...)
+}
+
+func (s *routeGroup) Group(prefix string, filters ...MiddleWareHandler) RouteGroup {
+	middlewareList := append(s.getMiddlewares(), filters...)
+	return &routeGroup{
+		registry:       s.registry,
+		parent:         s,
+		prefix:         joinGroupPrefix(s.prefix, prefix),
+		middlewareList: middlewareList,
+	}
+}
+
+func (s *routeGroup) groupPattern(uriPattern string) string {
+	if s.prefix == "" {
+		return uriPattern
+	}
+	if uriPattern == "" || uriPattern == "/" {
+		return s.prefix
+	}
+	if !strings.HasPrefix(uriPattern, "/") {
+		uriPattern = "/" + uriPattern
+	}
+	return s.prefix + uriPattern
+}
+
+func (s *routeGroup) AddRoute(rt Route, filters ...MiddleWareHandler) {
+	s.recordVersion(s.registry.GetApiVersion())
+	middlewareList := append(s.getMiddlewares(), filters...)
+	s.registry.AddRoute(&groupRoute{Route: rt, pattern: s.groupPattern(rt.Pattern())}, middlewareList...)
+}
+
+func (s *routeGroup) AddHandler(uriPattern, method string, handler RouteHandleFunc, filters ...MiddleWareHandleFunc) {
+	middleWareList := make([]MiddleWareHandler, len(filters))
+	for idx := range filters {
+		middleWareList[idx] = &anonymousMiddleWareHandler{
+			handleFunc: filters[idx],
+		}
+	}
+
+	s.AddRoute(CreateRoute(uriPattern, method, handler), middleWareList...)
+}
+
+func (s *routeGroup) RemoveRoute(rt Route) {
+	s.registry.RemoveHandler(s.groupPattern(rt.Pattern()), rt.Method())
+}
+
+// recordVersion 记录路由注册时的ApiVersion, 同时记录到上级分组
+func (s *routeGroup) recordVersion(version string) {
+	for cur := s; cur != nil; cur = cur.parent {
+		cur.versionLock.Lock()
+		if cur.versions == nil {
+			cur.versions = map[string]struct{}{}
+		}
+		cur.versions[version] = struct{}{}
+		cur.versionLock.Unlock()
+	}
+}
+
+// routePrefixes 组内路由实际匹配的uri前缀, registry 会在路由规则前加上注册时的ApiVersion
+func (s *routeGroup) routePrefixes() []string {
+	s.versionLock.RLock()
+	defer s.versionLock.RUnlock()
+
+	ret := make([]string, 0, len(s.versions))
+	for version := range s.versions {
+		ret = append(ret, version+s.prefix)
+	}
+	return ret
+}
+
+// groupRoutePrefixes 返回分组路由实际匹配的uri前缀(包含ApiVersion), 其他 RouteGroup 实现使用 Prefix()
+func groupRoutePrefixes(groups []RouteGroup) []string {
+	var ret []string
+	for _, val := range groups {
+		if group, ok := val.(*routeGroup); ok {
+			ret = append(ret, group.routePrefixes()...)
+			continue
+		}
+		ret = append(ret, val.Prefix())
+	}
+	return ret
+}
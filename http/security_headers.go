@@ -0,0 +1,340 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/muidea/magicCommon/foundation/helper"
+)
+
+// CSPNoncePlaceholder CSP策略中的占位符, 每个请求替换为 'nonce-<随机值>'
+const CSPNoncePlaceholder = "{nonce}"
+
+const maxCSPReportSize = 64 << 10
+
+// SecurityPreset 预置的安全头配置
+type SecurityPreset int
+
+const (
+	// SecurityPresetStrict 面向页面的严格配置, CSP要求脚本和样式带nonce
+	SecurityPresetStrict SecurityPreset = iota
+	// SecurityPresetAPI 面向JSON接口的配置, 禁止加载任何资源和被嵌入
+	SecurityPresetAPI
+)
+
+// CSPNonceKey context中保存当前请求CSP nonce的key
+type CSPNonceKey struct{}
+
+// GetCSPNonce 获取当前请求的CSP nonce, 模板中用于 <script nonce="...">
+func GetCSPNonce(ctx context.Context) string {
+	nonce, _ := helper.GetValueFromContext[string](ctx, CSPNonceKey{})
+	return nonce
+}
+
+// SecurityHeaders 安全响应头中间件
+//
+// 未配置的头会被删除, 因此分组上的实例可以完整覆盖全局实例的配置.
+type SecurityHeaders struct {
+	hstsMaxAge            time.Duration
+	hstsIncludeSubdomains bool
+	hstsPreload           bool
+	noSniff               bool
+	frameOptions          string
+	referrerPolicy        string
+	permissionsPolicy     string
+	crossOriginOpener     string
+	csp                   string
+	cspReportOnly         bool
+	cspReportURI          string
+}
+
+// SecurityHeadersOption configures a SecurityHeaders
+type SecurityHeadersOption func(*SecurityHeaders)
+
+// WithSecurityPreset replaces the whole configuration with a preset, pass it before other options
+func WithSecurityPreset(preset SecurityPreset) SecurityHeadersOption {
+	return func(s *SecurityHeaders) {
+		switch preset {
+		case SecurityPresetAPI:
+			*s = SecurityHeaders{
+				hstsMaxAge:            365 * 24 * time.Hour,
+				hstsIncludeSubdomains: true,
+				noSniff:               true,
+				frameOptions:          "DENY",
+				referrerPolicy:        "no-referrer",
+				csp:                   "default-src 'none'; frame-ancestors 'none'",
+			}
+		default:
+			*s = SecurityHeaders{
+				hstsMaxAge:            2 * 365 * 24 * time.Hour,
+				hstsIncludeSubdomains: true,
+				noSniff:               true,
+				frameOptions:          "DENY",
+				referrerPolicy:        "no-referrer",
+				permissionsPolicy:     "camera=(), microphone=(), geolocation=(), payment=()",
+				crossOriginOpener:     "same-origin",
+				csp: "default-src 'self'; script-src 'self' " + CSPNoncePlaceholder + "; style-src 'self' " + CSPNoncePlaceholder +
+					"; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'",
+			}
+		}
+	}
+}
+
+// WithHSTS sets Strict-Transport-Security, maxAge 0 disables it
+func WithHSTS(maxAge time.Duration, includeSubdomains, preload bool) SecurityHeadersOption {
+	return func(s *SecurityHeaders) {
+		s.hstsMaxAge = maxAge
+		s.hstsIncludeSubdomains = includeSubdomains
+		s.hstsPreload = preload
+	}
+}
+
+// WithContentTypeNosniff sets whether X-Content-Type-Options: nosniff is sent
+func WithContentTypeNosniff(enable bool) SecurityHeadersOption {
+	return func(s *SecurityHeaders) {
+		s.noSniff = enable
+	}
+}
+
+// WithFrameOptions sets X-Frame-Options (DENY/SAMEORIGIN), empty disables it; use frame-ancestors in CSP for finer control
+func WithFrameOptions(val string) SecurityHeadersOption {
+	return func(s *SecurityHeaders) {
+		s.frameOptions = val
+	}
+}
+
+// WithReferrerPolicy sets Referrer-Policy, empty disables it
+func WithReferrerPolicy(val string) SecurityHeadersOption {
+	return func(s *SecurityHeaders) {
+		s.referrerPolicy = val
+	}
+}
+
+// WithPermissionsPolicy sets Permissions-Policy, empty disables it
+func WithPermissionsPolicy(val string) SecurityHeadersOption {
+	return func(s *SecurityHeaders) {
+		s.permissionsPolicy = val
+	}
+}
+
+// WithCrossOriginOpenerPolicy sets Cross-Origin-Opener-Policy, empty disables it
+func WithCrossOriginOpenerPolicy(val string) SecurityHeadersOption {
+	return func(s *SecurityHeaders) {
+		s.crossOriginOpener = val
+	}
+}
+
+// WithCSP sets the Content-Security-Policy, CSPNoncePlaceholder is replaced by a per-request nonce
+func WithCSP(policy string) SecurityHeadersOption {
+	return func(s *SecurityHeaders) {
+		s.csp = policy
+	}
+}
+
+// WithCSPReportOnly sends the policy as Content-Security-Policy-Report-Only
+func WithCSPReportOnly(reportOnly bool) SecurityHeadersOption {
+	return func(s *SecurityHeaders) {
+		s.cspReportOnly = reportOnly
+	}
+}
+
+// WithCSPReportURI appends a report-uri directive, see CreateCSPReportRoute
+func WithCSPReportURI(uri string) SecurityHeadersOption {
+	return func(s *SecurityHeaders) {
+		s.cspReportURI = uri
+	}
+}
+
+// NewSecurityHeaders creates a new SecurityHeaders, default nosniff, SAMEORIGIN framing,
+// strict-origin-when-cross-origin referrer and 180 days HSTS
+func NewSecurityHeaders(opts ...SecurityHeadersOption) *SecurityHeaders {
+	s := &SecurityHeaders{
+		hstsMaxAge:     180 * 24 * time.Hour,
+		noSniff:        true,
+		frameOptions:   "SAMEORIGIN",
+		referrerPolicy: "strict-origin-when-cross-origin",
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *SecurityHeaders) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	header := res.Header()
+	setOrDelHeader(header, "Strict-Transport-Security", s.hstsValue())
+	if s.noSniff {
+		header.Set("X-Content-Type-Options", "nosniff")
+	} else {
+		header.Del("X-Content-Type-Options")
+	}
+	setOrDelHeader(header, "X-Frame-Options", s.frameOptions)
+	setOrDelHeader(header, "Referrer-Policy", s.referrerPolicy)
+	setOrDelHeader(header, "Permissions-Policy", s.permissionsPolicy)
+	setOrDelHeader(header, "Cross-Origin-Opener-Policy", s.crossOriginOpener)
+
+	header.Del("Content-Security-Policy")
+	header.Del("Content-Security-Policy-Report-Only")
+	if s.csp != "" {
+		policy := s.csp
+		if strings.Contains(policy, CSPNoncePlaceholder) {
+			nonce := newCSPNonce()
+			policy = strings.ReplaceAll(policy, CSPNoncePlaceholder, "'nonce-"+nonce+"'")
+			ctx.Update(context.WithValue(ctx.Context(), CSPNonceKey{}, nonce))
+		}
+		if s.cspReportURI != "" {
+			policy = strings.TrimRight(strings.TrimSpace(policy), ";") + "; report-uri " + s.cspReportURI
+		}
+
+		name := "Content-Security-Policy"
+		if s.cspReportOnly {
+			name = "Content-Security-Policy-Report-Only"
+		}
+		header.Set(name, policy)
+	}
+
+	ctx.Next()
+}
+
+func (s *SecurityHeaders) hstsValue() string {
+	if s.hstsMaxAge <= 0 {
+		return ""
+	}
+
+	val := fmt.Sprintf("max-age=%d", int64(s.hstsMaxAge/time.Second))
+	if s.hstsIncludeSubdomains {
+		val += "; includeSubDomains"
+	}
+	if s.hstsPreload {
+		val += "; preload"
+	}
+	return val
+}
+
+func setOrDelHeader(header http.Header, name, val string) {
+	if val == "" {
+		header.Del(name)
+		return
+	}
+	header.Set(name, val)
+}
+
+func newCSPNonce() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// CSPReport CSP违规报告, 兼容report-uri和Reporting API两种格式
+type CSPReport struct {
+	DocumentURI        string `json:"document-uri"`
+	Referrer           string `json:"referrer,omitempty"`
+	BlockedURI         string `json:"blocked-uri"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effective-directive,omitempty"`
+	OriginalPolicy     string `json:"original-policy,omitempty"`
+	Disposition        string `json:"disposition,omitempty"`
+	SourceFile         string `json:"source-file,omitempty"`
+	LineNumber         int    `json:"line-number,omitempty"`
+	StatusCode         int    `json:"status-code,omitempty"`
+}
+
+// reportingAPIBody Reporting API (application/reports+json) 中csp-violation的body
+type reportingAPIBody struct {
+	DocumentURL        string `json:"documentURL"`
+	Referrer           string `json:"referrer"`
+	BlockedURL         string `json:"blockedURL"`
+	EffectiveDirective string `json:"effectiveDirective"`
+	OriginalPolicy     string `json:"originalPolicy"`
+	Disposition        string `json:"disposition"`
+	SourceFile         string `json:"sourceFile"`
+	LineNumber         int    `json:"lineNumber"`
+	StatusCode         int    `json:"statusCode"`
+}
+
+// CSPReportHandler 处理一条CSP违规报告
+type CSPReportHandler func(ctx context.Context, report *CSPReport)
+
+// CreateCSPReportRoute 创建接收CSP违规报告的POST路由, handler为nil时记录警告日志
+func CreateCSPReportRoute(uriPattern string, handler CSPReportHandler) Route {
+	if handler == nil {
+		handler = func(ctx context.Context, report *CSPReport) {
+			slog.WarnContext(ctx, "csp violation",
+				"document", report.DocumentURI,
+				"blocked", report.BlockedURI,
+				"directive", report.ViolatedDirective,
+				"disposition", report.Disposition)
+		}
+	}
+
+	return CreateRoute(uriPattern, POST, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		content, contentErr := io.ReadAll(io.LimitReader(req.Body, maxCSPReportSize))
+		if contentErr != nil {
+			RenderError(ctx, res, req, http.StatusBadRequest, contentErr)
+			return
+		}
+
+		reports, reportsErr := parseCSPReports(req.Header.Get("Content-Type"), content)
+		if reportsErr != nil {
+			RenderError(ctx, res, req, http.StatusBadRequest, reportsErr)
+			return
+		}
+		for _, val := range reports {
+			handler(ctx, val)
+		}
+		res.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func parseCSPReports(contentType string, content []byte) ([]*CSPReport, error) {
+	if strings.HasPrefix(contentType, "application/reports+json") {
+		items := []struct {
+			Type string           `json:"type"`
+			Body reportingAPIBody `json:"body"`
+		}{}
+		if err := json.Unmarshal(content, &items); err != nil {
+			return nil, err
+		}
+
+		reports := []*CSPReport{}
+		for _, val := range items {
+			if val.Type != "csp-violation" {
+				continue
+			}
+			reports = append(reports, &CSPReport{
+				DocumentURI:        val.Body.DocumentURL,
+				Referrer:           val.Body.Referrer,
+				BlockedURI:         val.Body.BlockedURL,
+				ViolatedDirective:  val.Body.EffectiveDirective,
+				EffectiveDirective: val.Body.EffectiveDirective,
+				OriginalPolicy:     val.Body.OriginalPolicy,
+				Disposition:        val.Body.Disposition,
+				SourceFile:         val.Body.SourceFile,
+				LineNumber:         val.Body.LineNumber,
+				StatusCode:         val.Body.StatusCode,
+			})
+		}
+		return reports, nil
+	}
+
+	wrapper := struct {
+		Report *CSPReport `json:"csp-report"`
+	}{}
+	if err := json.Unmarshal(content, &wrapper); err != nil {
+		return nil, err
+	}
+	if wrapper.Report == nil {
+		return nil, fmt.Errorf("missing csp-report")
+	}
+	return []*CSPReport{wrapper.Report}, nil
+}
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSecurityHeadersStrictPresetNonce(t *testing.T) {
+	var nonce string
+	handler := func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		nonce = GetCSPNonce(ctx)
+		_, _ = res.Write([]byte("ok"))
+	}
+
+	w := serveWithMiddleware(NewSecurityHeaders(WithSecurityPreset(SecurityPresetStrict), WithCSPReportURI("/csp-report")),
+		handler, httptest.NewRequest(http.MethodGet, "/", nil))
+	csp := w.Header().Get("Content-Security-Policy")
+	if nonce == "" || !strings.Contains(csp, "script-src 'self' 'nonce-"+nonce+"'") || !strings.HasSuffix(csp, "; report-uri /csp-report") {
+		t.Fatalf("nonce = %q, csp = %q", nonce, csp)
+	}
+	if w.Header().Get("X-Frame-Options") != "DENY" || w.Header().Get("Strict-Transport-Security") != "max-age=63072000; includeSubDomains" {
+		t.Fatalf("unexpected headers: %v", w.Header())
+	}
+
+	first := nonce
+	serveWithMiddleware(NewSecurityHeaders(WithSecurityPreset(SecurityPresetStrict)), handler, httptest.NewRequest(http.MethodGet, "/", nil))
+	if nonce == first {
+		t.Fatal("nonce must differ per request")
+	}
+}
+
+func TestSecurityHeadersGroupOverride(t *testing.T) {
+	registry := NewRouteRegistry()
+	api := NewRouteGroup(registry, "/api", NewSecurityHeaders(WithSecurityPreset(SecurityPresetAPI), WithCSPReportOnly(true)))
+	api.AddHandler("/items", GET, okHandler)
+
+	chains := NewMiddleWareChains()
+	chains.Append(NewSecurityHeaders(WithPermissionsPolicy("camera=()"), WithCSP("default-src 'self'")))
+
+	w := httptest.NewRecorder()
+	NewRequestContext(chains.GetHandlers(), registry, context.Background(), w, httptest.NewRequest(http.MethodGet, "/api/items", nil)).Run()
+	if w.Header().Get("Permissions-Policy") != "" || w.Header().Get("Content-Security-Policy") != "" {
+		t.Fatalf("group preset should replace global headers: %v", w.Header())
+	}
+	if w.Header().Get("Content-Security-Policy-Report-Only") != "default-src 'none'; frame-ancestors 'none'" {
+		t.Fatalf("report only csp = %q", w.Header().Get("Content-Security-Policy-Report-Only"))
+	}
+}
+
+func TestCSPReportRoute(t *testing.T) {
+	var reports []*CSPReport
+	route := CreateCSPReportRoute("/csp-report", func(ctx context.Context, report *CSPReport) {
+		reports = append(reports, report)
+	})
+
+	bodies := map[string]string{
+		"application/csp-report":   `{"csp-report":{"document-uri":"https://example.com/","blocked-uri":"inline","violated-directive":"script-src"}}`,
+		"application/reports+json": `[{"type":"csp-violation","body":{"documentURL":"https://example.com/","blockedURL":"eval","effectiveDirective":"script-src"}},{"type":"deprecation","body":{}}]`,
+	}
+	for contentType, body := range bodies {
+		req := httptest.NewRequest(http.MethodPost, "/csp-report", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		route.Handler()(context.Background(), w, req)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("%s: status = %d", contentType, w.Code)
+		}
+	}
+
+	if len(reports) != 2 || reports[0].ViolatedDirective != "script-src" || reports[1].ViolatedDirective != "script-src" {
+		t.Fatalf("unexpected reports: %+v", reports)
+	}
+}
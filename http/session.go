@@ -0,0 +1,404 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/muidea/magicCommon/foundation/helper"
+)
+
+const flashKey = "_flash"
+
+// SessionData 会话在存储中的内容
+//
+// 值会被JSON编码后保存, 读取时数字统一为float64, 结构体变为map[string]any.
+type SessionData struct {
+	ID         string         `json:"id"`
+	Values     map[string]any `json:"values,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	LastAccess time.Time      `json:"last_access"`
+}
+
+// SessionStore 会话存储
+type SessionStore interface {
+	// Load 根据cookie值加载会话, 不存在时返回nil
+	Load(cookieValue string) (*SessionData, error)
+	// Save 保存会话, 返回写入cookie的值
+	Save(data *SessionData) (string, error)
+	// Delete 删除会话
+	Delete(id string) error
+}
+
+// Session 单个请求使用的会话
+type Session struct {
+	data      SessionData
+	isNew     bool
+	dirty     bool
+	destroyed bool
+	// staleIDs 轮换前的会话ID, 保存时从存储中删除
+	staleIDs []string
+	mu       sync.Mutex
+}
+
+func newSessionID() string {
+	buf := make([]byte, 32)
+	_, _ = rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func newSession(now time.Time) *Session {
+	return &Session{
+		data: SessionData{
+			ID:         newSessionID(),
+			Values:     map[string]any{},
+			CreatedAt:  now,
+			LastAccess: now,
+		},
+		isNew: true,
+	}
+}
+
+// ID 当前会话ID
+func (s *Session) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.data.ID
+}
+
+// IsNew 本次请求新建的会话
+func (s *Session) IsNew() bool {
+	return s.isNew
+}
+
+// Get 读取值
+func (s *Session) Get(key string) (any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	val, ok := s.data.Values[key]
+	return val, ok
+}
+
+// Set 设置值
+func (s *Session) Set(key string, val any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Values[key] = val
+	s.dirty = true
+}
+
+// Delete 删除值
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.Values[key]; ok {
+		delete(s.data.Values, key)
+		s.dirty = true
+	}
+}
+
+// AddFlash 添加一次性消息, 读取后即删除
+func (s *Session) AddFlash(key string, val any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flashes, _ := s.data.Values[flashKey].(map[string]any)
+	if flashes == nil {
+		flashes = map[string]any{}
+	}
+	items, _ := flashes[key].([]any)
+	flashes[key] = append(items, val)
+	s.data.Values[flashKey] = flashes
+	s.dirty = true
+}
+
+// Flashes 取出并删除一次性消息
+func (s *Session) Flashes(key string) []any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flashes, _ := s.data.Values[flashKey].(map[string]any)
+	items, ok := flashes[key].([]any)
+	if !ok {
+		return nil
+	}
+
+	delete(flashes, key)
+	if len(flashes) == 0 {
+		delete(s.data.Values, flashKey)
+	}
+	s.dirty = true
+	return items
+}
+
+// RenewID 更换会话ID并保留数据, 登录等权限变化后必须调用以防止会话固定攻击
+func (s *Session) RenewID() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isNew {
+		s.staleIDs = append(s.staleIDs, s.data.ID)
+	}
+	s.data.ID = newSessionID()
+	s.dirty = true
+}
+
+// Destroy 删除会话并清除cookie
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.destroyed = true
+	s.data.Values = map[string]any{}
+}
+
+// SessionKey context中保存会话的key
+type SessionKey struct{}
+
+// GetSession 获取context中的会话
+func GetSession(ctx context.Context) (*Session, bool) {
+	session, ok := helper.GetValueFromContext[*Session](ctx, SessionKey{})
+	return session, ok && session != nil
+}
+
+// Sessions 会话中间件, 每个请求加载会话并在响应头写出前保存
+type Sessions struct {
+	store           SessionStore
+	cookieName      string
+	cookiePath      string
+	domain          string
+	secure          bool
+	httpOnly        bool
+	sameSite        http.SameSite
+	idleTimeout     time.Duration
+	absoluteTimeout time.Duration
+	now             func() time.Time
+}
+
+// SessionsOption configures a Sessions
+type SessionsOption func(*Sessions)
+
+// WithSessionCookieName sets the cookie name, default "session"
+func WithSessionCookieName(name string) SessionsOption {
+	return func(s *Sessions) {
+		s.cookieName = name
+	}
+}
+
+// WithSessionCookiePath sets the cookie Path attribute, default "/"
+func WithSessionCookiePath(path string) SessionsOption {
+	return func(s *Sessions) {
+		s.cookiePath = path
+	}
+}
+
+// WithSessionDomain sets the cookie Domain attribute
+func WithSessionDomain(domain string) SessionsOption {
+	return func(s *Sessions) {
+		s.domain = domain
+	}
+}
+
+// WithSessionSecure sets the cookie Secure attribute
+func WithSessionSecure(secure bool) SessionsOption {
+	return func(s *Sessions) {
+		s.secure = secure
+	}
+}
+
+// WithSessionHttpOnly sets the cookie HttpOnly attribute, default true
+func WithSessionHttpOnly(httpOnly bool) SessionsOption {
+	return func(s *Sessions) {
+		s.httpOnly = httpOnly
+	}
+}
+
+// WithSessionSameSite sets the cookie SameSite attribute, default Lax
+func WithSessionSameSite(sameSite http.SameSite) SessionsOption {
+	return func(s *Sessions) {
+		s.sameSite = sameSite
+	}
+}
+
+// WithSessionIdleTimeout expires sessions not accessed within timeout, default 30 minutes
+func WithSessionIdleTimeout(timeout time.Duration) SessionsOption {
+	return func(s *Sessions) {
+		s.idleTimeout = timeout
+	}
+}
+
+// WithSessionAbsoluteTimeout expires sessions older than timeout regardless of activity, default 24 hours
+func WithSessionAbsoluteTimeout(timeout time.Duration) SessionsOption {
+	return func(s *Sessions) {
+		s.absoluteTimeout = timeout
+	}
+}
+
+// NewSessions creates a new Sessions backed by store
+func NewSessions(store SessionStore, opts ...SessionsOption) *Sessions {
+	s := &Sessions{
+		store:           store,
+		cookieName:      "session",
+		cookiePath:      "/",
+		httpOnly:        true,
+		sameSite:        http.SameSiteLaxMode,
+		idleTimeout:     30 * time.Minute,
+		absoluteTimeout: 24 * time.Hour,
+		now:             time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *Sessions) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	session := s.load(ctx.Context(), req)
+	ctx.Update(context.WithValue(ctx.Context(), SessionKey{}, session))
+
+	sw := &sessionWriter{ResponseWriter: res, commit: func() {
+		s.save(ctx.Context(), res, session)
+	}}
+	if restore, ok := replaceResponseWriter(ctx, sw); ok {
+		defer restore()
+	}
+
+	ctx.Next()
+	sw.commitOnce()
+}
+
+func (s *Sessions) load(ctx context.Context, req *http.Request) *Session {
+	now := s.now()
+	cookie, cookieErr := req.Cookie(s.cookieName)
+	if cookieErr != nil || cookie.Value == "" {
+		return newSession(now)
+	}
+
+	data, dataErr := s.store.Load(cookie.Value)
+	if dataErr != nil {
+		slog.WarnContext(ctx, "load session failed", "err", dataErr)
+	}
+	if data == nil {
+		return newSession(now)
+	}
+
+	if s.expired(data, now) {
+		if err := s.store.Delete(data.ID); err != nil {
+			slog.WarnContext(ctx, "delete expired session failed", "err", err)
+		}
+		return newSession(now)
+	}
+
+	if data.Values == nil {
+		data.Values = map[string]any{}
+	}
+	data.LastAccess = now
+	return &Session{data: *data}
+}
+
+func (s *Sessions) expired(data *SessionData, now time.Time) bool {
+	if s.idleTimeout > 0 && now.Sub(data.LastAccess) > s.idleTimeout {
+		return true
+	}
+	return s.absoluteTimeout > 0 && now.Sub(data.CreatedAt) > s.absoluteTimeout
+}
+
+func (s *Sessions) save(ctx context.Context, res http.ResponseWriter, session *Session) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	for _, val := range session.staleIDs {
+		if err := s.store.Delete(val); err != nil {
+			slog.WarnContext(ctx, "delete renewed session failed", "err", err)
+		}
+	}
+	session.staleIDs = nil
+
+	if session.destroyed {
+		if !session.isNew {
+			if err := s.store.Delete(session.data.ID); err != nil {
+				slog.WarnContext(ctx, "delete session failed", "err", err)
+			}
+			http.SetCookie(res, s.cookie("", -1))
+		}
+		return
+	}
+
+	// 新会话没有写入任何数据时不下发cookie
+	if session.isNew && !session.dirty {
+		return
+	}
+
+	value, valueErr := s.store.Save(&session.data)
+	if valueErr != nil {
+		slog.ErrorContext(ctx, "save session failed", "err", valueErr)
+		return
+	}
+
+	maxAge := s.idleTimeout
+	if s.absoluteTimeout > 0 {
+		if remain := session.data.CreatedAt.Add(s.absoluteTimeout).Sub(s.now()); maxAge <= 0 || remain < maxAge {
+			maxAge = remain
+		}
+	}
+	http.SetCookie(res, s.cookie(value, int(maxAge/time.Second)))
+}
+
+func (s *Sessions) cookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     s.cookieName,
+		Value:    value,
+		Path:     s.cookiePath,
+		Domain:   s.domain,
+		MaxAge:   maxAge,
+		Secure:   s.secure,
+		HttpOnly: s.httpOnly,
+		SameSite: s.sameSite,
+	}
+}
+
+// sessionWriter 在响应头写出之前保存会话, 以便写入Set-Cookie
+type sessionWriter struct {
+	http.ResponseWriter
+	commit    func()
+	committed bool
+}
+
+func (w *sessionWriter) commitOnce() {
+	if w.committed {
+		return
+	}
+	w.committed = true
+	w.commit()
+}
+
+func (w *sessionWriter) WriteHeader(code int) {
+	w.commitOnce()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *sessionWriter) Write(data []byte) (int, error) {
+	w.commitOnce()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *sessionWriter) Flush() {
+	w.commitOnce()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *sessionWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
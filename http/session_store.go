@@ -0,0 +1,206 @@
+package http
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxSessionCookieSize 浏览器对单个cookie的通用上限
+const maxSessionCookieSize = 4096
+
+// ErrSessionTooLarge is returned when an encoded cookie session exceeds the browser cookie limit
+var ErrSessionTooLarge = errors.New("http: session data too large for cookie")
+
+// CookieSessionStore 会话数据经AES-GCM加密和认证后直接保存在cookie中
+type CookieSessionStore struct {
+	aeads []cipher.AEAD
+}
+
+// NewCookieSessionStore 使用16/24/32字节的密钥创建存储
+//
+// 第一个密钥用于加密, 全部密钥都可用于解密, 轮换密钥时把新密钥放在最前面.
+func NewCookieSessionStore(keys ...[]byte) (*CookieSessionStore, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("cookie session store requires at least one key")
+	}
+
+	s := &CookieSessionStore{}
+	for _, key := range keys {
+		block, blockErr := aes.NewCipher(key)
+		if blockErr != nil {
+			return nil, blockErr
+		}
+		aead, aeadErr := cipher.NewGCM(block)
+		if aeadErr != nil {
+			return nil, aeadErr
+		}
+		s.aeads = append(s.aeads, aead)
+	}
+	return s, nil
+}
+
+func (s *CookieSessionStore) Load(cookieValue string) (*SessionData, error) {
+	sealed, sealedErr := base64.RawURLEncoding.DecodeString(cookieValue)
+	if sealedErr != nil {
+		return nil, sealedErr
+	}
+
+	for _, aead := range s.aeads {
+		if len(sealed) < aead.NonceSize() {
+			continue
+		}
+		plain, plainErr := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+		if plainErr != nil {
+			continue
+		}
+
+		data := &SessionData{}
+		if err := json.Unmarshal(plain, data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+
+	// 被篡改或使用已下线密钥的cookie按新会话处理
+	return nil, nil
+}
+
+func (s *CookieSessionStore) Save(data *SessionData) (string, error) {
+	plain, plainErr := json.Marshal(data)
+	if plainErr != nil {
+		return "", plainErr
+	}
+
+	aead := s.aeads[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	value := base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, nil))
+	if len(value) > maxSessionCookieSize {
+		return "", ErrSessionTooLarge
+	}
+	return value, nil
+}
+
+// Delete cookie存储没有服务端状态, 由中间件清除cookie
+func (s *CookieSessionStore) Delete(string) error {
+	return nil
+}
+
+type memorySessionItem struct {
+	content  []byte
+	expireAt time.Time
+}
+
+// MemorySessionStore 内存会话存储, 后台定期清理过期会话
+type MemorySessionStore struct {
+	ttl      time.Duration
+	sessions map[string]*memorySessionItem
+	mu       sync.Mutex
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewMemorySessionStore 新建内存会话存储, ttl为会话最后一次保存后的保留时长
+func NewMemorySessionStore(ttl, gcInterval time.Duration) *MemorySessionStore {
+	if ttl <= 0 {
+		ttl = 30 * time.Minute
+	}
+	if gcInterval <= 0 {
+		gcInterval = time.Minute
+	}
+
+	s := &MemorySessionStore{
+		ttl:      ttl,
+		sessions: map[string]*memorySessionItem{},
+		stopChan: make(chan struct{}),
+	}
+	go s.gc(gcInterval)
+	return s
+}
+
+func (s *MemorySessionStore) gc(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case now := <-ticker.C:
+			s.evict(now)
+		}
+	}
+}
+
+func (s *MemorySessionStore) evict(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, item := range s.sessions {
+		if now.After(item.expireAt) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// Close 停止后台清理
+func (s *MemorySessionStore) Close() {
+	s.stopOnce.Do(func() {
+		close(s.stopChan)
+	})
+}
+
+// Len 当前保存的会话数
+func (s *MemorySessionStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.sessions)
+}
+
+func (s *MemorySessionStore) Load(cookieValue string) (*SessionData, error) {
+	s.mu.Lock()
+	item, ok := s.sessions[cookieValue]
+	s.mu.Unlock()
+	if !ok || time.Now().After(item.expireAt) {
+		return nil, nil
+	}
+
+	// 每次解码出新的副本, 避免并发请求共享同一个map
+	data := &SessionData{}
+	if err := json.Unmarshal(item.content, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *MemorySessionStore) Save(data *SessionData) (string, error) {
+	content, contentErr := json.Marshal(data)
+	if contentErr != nil {
+		return "", contentErr
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[data.ID] = &memorySessionItem{content: content, expireAt: time.Now().Add(s.ttl)}
+	return data.ID, nil
+}
+
+func (s *MemorySessionStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+	return nil
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serveSession(sessions *Sessions, cookie *http.Cookie, handler func(session *Session)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	return serveWithMiddleware(sessions, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		session, _ := GetSession(ctx)
+		handler(session)
+		_, _ = res.Write([]byte("ok"))
+	}, req)
+}
+
+func sessionCookie(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, val := range w.Result().Cookies() {
+		if val.Name == "session" {
+			return val
+		}
+	}
+	t.Fatalf("no session cookie in %v", w.Header())
+	return nil
+}
+
+func TestSessionsWithMemoryStore(t *testing.T) {
+	store := NewMemorySessionStore(time.Hour, time.Hour)
+	defer store.Close()
+	sessions := NewSessions(store, WithSessionSecure(true), WithSessionSameSite(http.SameSiteStrictMode), WithSessionDomain("example.com"))
+
+	w := serveSession(sessions, nil, func(session *Session) {})
+	if len(w.Result().Cookies()) != 0 {
+		t.Fatal("untouched new session should not set a cookie")
+	}
+
+	w = serveSession(sessions, nil, func(session *Session) {
+		session.Set("user", "alice")
+		session.AddFlash("notice", "welcome")
+	})
+	cookie := sessionCookie(t, w)
+	if !cookie.Secure || !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode || cookie.Domain != "example.com" {
+		t.Fatalf("unexpected cookie attributes: %+v", cookie)
+	}
+
+	var flashes []any
+	var user any
+	w = serveSession(sessions, cookie, func(session *Session) {
+		user, _ = session.Get("user")
+		flashes = session.Flashes("notice")
+		session.RenewID()
+	})
+	if user != "alice" || len(flashes) != 1 || flashes[0] != "welcome" {
+		t.Fatalf("user = %v, flashes = %v", user, flashes)
+	}
+	renewed := sessionCookie(t, w)
+	if renewed.Value == cookie.Value || store.Len() != 1 {
+		t.Fatalf("renew should replace the stored session, len = %d", store.Len())
+	}
+
+	serveSession(sessions, cookie, func(session *Session) {
+		if !session.IsNew() {
+			t.Error("old session id must not be accepted after renew")
+		}
+	})
+	serveSession(sessions, renewed, func(session *Session) {
+		if len(session.Flashes("notice")) != 0 {
+			t.Error("flash should be consumed")
+		}
+		session.Destroy()
+	})
+	if store.Len() != 0 {
+		t.Fatalf("destroyed session should be deleted, len = %d", store.Len())
+	}
+}
+
+func TestSessionsWithCookieStoreAndTimeouts(t *testing.T) {
+	oldKey := []byte(strings.Repeat("o", 32))
+	oldStore, _ := NewCookieSessionStore(oldKey)
+	store, err := NewCookieSessionStore([]byte(strings.Repeat("n", 32)), oldKey)
+	if err != nil {
+		t.Fatalf("NewCookieSessionStore failed: %v", err)
+	}
+
+	now := time.Now()
+	sessions := NewSessions(oldStore, WithSessionIdleTimeout(time.Minute), WithSessionAbsoluteTimeout(time.Hour))
+	sessions.now = func() time.Time { return now }
+	cookie := sessionCookie(t, serveSession(sessions, nil, func(session *Session) { session.Set("n", 1) }))
+	if strings.Contains(cookie.Value, `"n"`) || cookie.MaxAge != 60 {
+		t.Fatalf("cookie should be encrypted with idle max-age: %+v", cookie)
+	}
+
+	sessions.store = store
+	var value any
+	sessionCookie(t, serveSession(sessions, cookie, func(session *Session) { value, _ = session.Get("n") }))
+	if value != float64(1) {
+		t.Fatalf("session written with a rotated key should load, got %v", value)
+	}
+
+	tampered := *cookie
+	tampered.Value = cookie.Value[:len(cookie.Value)-4] + "AAAA"
+	serveSession(sessions, &tampered, func(session *Session) {
+		if !session.IsNew() {
+			t.Error("tampered cookie must start a new session")
+		}
+	})
+
+	now = now.Add(2 * time.Minute)
+	serveSession(sessions, cookie, func(session *Session) {
+		if !session.IsNew() {
+			t.Error("idle session should expire")
+		}
+	})
+}
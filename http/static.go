@@ -2,6 +2,8 @@ package http
 
 import (
 	"context"
+	"errors"
+	"io/fs"
 	"log/slog"
 	"net/http"
 	"net/url"
@@ -176,7 +178,7 @@ func StaticHandler(ctx context.Context, res http.ResponseWriter, req *http.Reque
 	uriFilePath := req.URL.Path
 
 	if !strings.HasPrefix(uriFilePath, opt.PrefixUri) {
-		res.WriteHeader(http.StatusNotFound)
+		RenderError(ctx, res, req, http.StatusNotFound, ErrURLNotFound)
 		return
 	}
 
@@ -184,7 +186,11 @@ func StaticHandler(ctx context.Context, res http.ResponseWriter, req *http.Reque
 
 	err := serveStaticFile(dir, opt, uriFilePath, res, req, false)
 	if err != nil {
-		slog.Warn("failed to serve static file", "path", uriFilePath, "err", err)
-		res.WriteHeader(http.StatusInternalServerError)
+		slog.WarnContext(ctx, "failed to serve static file", "path", uriFilePath, "err", err)
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, ErrURLNotFound) {
+			RenderError(ctx, res, req, http.StatusNotFound, ErrStaticFileNotFound)
+			return
+		}
+		RenderError(ctx, res, req, http.StatusInternalServerError, nil)
 	}
 }
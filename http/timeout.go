@@ -0,0 +1,252 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/muidea/magicCommon/foundation/helper"
+)
+
+// ErrRequestTimeout is the cancel cause of a request context whose timeout expired
+var ErrRequestTimeout = errors.New("http: request timeout")
+
+type timeoutRecorderKey struct{}
+
+// timeoutRecorder 让Timeout之前的中间件在ctx.Next()返回后得知请求是否超时
+type timeoutRecorder struct {
+	timedOut atomic.Bool
+}
+
+// trackRequestTimeout 在context中登记recorder, 已登记时复用
+func trackRequestTimeout(ctx RequestContext) *timeoutRecorder {
+	if recorder, ok := helper.GetValueFromContext[*timeoutRecorder](ctx.Context(), timeoutRecorderKey{}); ok {
+		return recorder
+	}
+
+	recorder := &timeoutRecorder{}
+	ctx.Update(context.WithValue(ctx.Context(), timeoutRecorderKey{}, recorder))
+	return recorder
+}
+
+// IsRequestTimeout 判断错误或context是否由请求超时导致
+func IsRequestTimeout(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrRequestTimeout)
+}
+
+// Timeout 请求超时中间件
+//
+// 为后续中间件和RouteHandleFunc的context设置deadline, 超时且尚未写出响应时返回503(可配置为504),
+// 之后handler的写入被丢弃. handler需要检查ctx.Done()及时退出.
+type Timeout struct {
+	timeout        time.Duration
+	status         int
+	exemptPrefixes []string
+	exemptGroups   []RouteGroup
+	skipper        func(req *http.Request) bool
+}
+
+// TimeoutOption configures a Timeout
+type TimeoutOption func(*Timeout)
+
+// WithTimeoutStatus sets the status returned on timeout, http.StatusServiceUnavailable or http.StatusGatewayTimeout
+func WithTimeoutStatus(status int) TimeoutOption {
+	return func(t *Timeout) {
+		t.status = status
+	}
+}
+
+// WithTimeoutExemptPaths skips requests whose path equals or is under one of the prefixes, e.g. SSE or uploads
+func WithTimeoutExemptPaths(prefixes ...string) TimeoutOption {
+	return func(t *Timeout) {
+		t.exemptPrefixes = append(t.exemptPrefixes, prefixes...)
+	}
+}
+
+// WithTimeoutExemptGroups skips routes registered in the groups, the groups may use their own Timeout
+func WithTimeoutExemptGroups(groups ...RouteGroup) TimeoutOption {
+	return func(t *Timeout) {
+		t.exemptGroups = append(t.exemptGroups, groups...)
+	}
+}
+
+// WithTimeoutSkipper skips requests for which skipper returns true
+func WithTimeoutSkipper(skipper func(req *http.Request) bool) TimeoutOption {
+	return func(t *Timeout) {
+		t.skipper = skipper
+	}
+}
+
+// NewTimeout creates a new Timeout, timeout <= 0 disables it
+func NewTimeout(timeout time.Duration, opts ...TimeoutOption) *Timeout {
+	t := &Timeout{
+		timeout: timeout,
+		status:  http.StatusServiceUnavailable,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+func (s *Timeout) skip(req *http.Request) bool {
+	if s.timeout <= 0 {
+		return true
+	}
+	if s.skipper != nil && s.skipper(req) {
+		return true
+	}
+	return matchPathPrefix(req.URL.Path, s.exemptPrefixes) || matchPathPrefix(req.URL.Path, groupRoutePrefixes(s.exemptGroups))
+}
+
+func (s *Timeout) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	if s.skip(req) {
+		ctx.Next()
+		return
+	}
+
+	parentCtx := ctx.Context()
+	timeoutCtx, cancel := context.WithTimeoutCause(parentCtx, s.timeout, ErrRequestTimeout)
+	defer cancel()
+
+	tw := &timeoutWriter{
+		ResponseWriter: res,
+		header:         res.Header().Clone(),
+		ctx:            timeoutCtx,
+		render: func(rw http.ResponseWriter) {
+			RenderError(parentCtx, rw, req, s.status, ErrRequestTimeout)
+		},
+	}
+	stop := context.AfterFunc(timeoutCtx, tw.timeout)
+	defer stop()
+
+	ctx.Update(timeoutCtx)
+	restore, ok := replaceResponseWriter(ctx, tw)
+	if !ok {
+		ctx.Next()
+		return
+	}
+	ctx.Next()
+	restore()
+
+	if tw.finish() {
+		// 由logger输出超时日志
+		if recorder, ok := helper.GetValueFromContext[*timeoutRecorder](parentCtx, timeoutRecorderKey{}); ok {
+			recorder.timedOut.Store(true)
+		}
+	}
+}
+
+// timeoutWriter 超时前转发写入, 超时后输出错误响应并丢弃handler的写入
+//
+// handler使用独立的header, 避免与超时回调并发读写同一个map.
+type timeoutWriter struct {
+	http.ResponseWriter
+	header      http.Header
+	ctx         context.Context
+	render      func(rw http.ResponseWriter)
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+	done        bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+// expiredLocked 已超时时输出超时响应, 覆盖超时回调尚未执行而handler已返回的情况
+func (w *timeoutWriter) expiredLocked() bool {
+	if !w.timedOut && !w.wroteHeader && IsRequestTimeout(w.ctx) {
+		w.timeoutLocked()
+	}
+	return w.timedOut
+}
+
+func (w *timeoutWriter) writeHeaderLocked(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	dst := w.ResponseWriter.Header()
+	clear(dst)
+	for key, val := range w.header {
+		dst[key] = val
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.expiredLocked() {
+		return
+	}
+	w.writeHeaderLocked(code)
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.expiredLocked() {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.writeHeaderLocked(http.StatusOK)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.expiredLocked() {
+		return
+	}
+	w.writeHeaderLocked(http.StatusOK)
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *timeoutWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// timeout 超时回调, 尚未写出响应时输出超时响应
+func (w *timeoutWriter) timeout() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.done || w.wroteHeader || !IsRequestTimeout(w.ctx) {
+		return
+	}
+	w.timeoutLocked()
+}
+
+func (w *timeoutWriter) timeoutLocked() {
+	w.timedOut = true
+	w.render(w.ResponseWriter)
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// finish 结束转发, 返回是否已输出超时响应
+func (w *timeoutWriter) finish() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.done {
+		w.done = true
+		w.expiredLocked()
+	}
+	return w.timedOut
+}
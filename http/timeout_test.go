@@ -0,0 +1,134 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutExpired(t *testing.T) {
+	timeout := NewTimeout(20 * time.Millisecond)
+
+	var writeErr error
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/slow", nil)
+	w := serveWithMiddleware(timeout, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("handler context should carry a deadline")
+		}
+		<-ctx.Done()
+		if !IsRequestTimeout(ctx) {
+			t.Error("context should be canceled by request timeout")
+		}
+		_, writeErr = res.Write([]byte("late"))
+	}, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected status %d", w.Code)
+	}
+	if !errors.Is(writeErr, http.ErrHandlerTimeout) {
+		t.Fatalf("late write should fail, err:%v", writeErr)
+	}
+	if body := w.Body.String(); body == "" || body == "late" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestTimeoutWithinDeadline(t *testing.T) {
+	timeout := NewTimeout(time.Second, WithTimeoutStatus(http.StatusGatewayTimeout))
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/fast", nil)
+	w := serveWithMiddleware(timeout, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("X-Handler", "yes")
+		res.WriteHeader(http.StatusCreated)
+		_, _ = res.Write([]byte("ok"))
+	}, req)
+
+	if w.Code != http.StatusCreated || w.Body.String() != "ok" || w.Header().Get("X-Handler") != "yes" {
+		t.Fatalf("unexpected response %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+}
+
+func TestTimeoutStatusAndWrittenBeforeDeadline(t *testing.T) {
+	timeout := NewTimeout(20*time.Millisecond, WithTimeoutStatus(http.StatusGatewayTimeout))
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/slow", nil)
+	w := serveWithMiddleware(timeout, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		<-ctx.Done()
+	}, req)
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("unexpected status %d", w.Code)
+	}
+
+	// 已经开始输出的响应不会被替换
+	w = serveWithMiddleware(timeout, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		_, _ = res.Write([]byte("partial"))
+		<-ctx.Done()
+	}, req)
+	if w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestTimeoutExemptPaths(t *testing.T) {
+	timeout := NewTimeout(time.Millisecond, WithTimeoutExemptPaths("/events"))
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/events/stream", nil)
+	w := serveWithMiddleware(timeout, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		if _, ok := ctx.Deadline(); ok {
+			t.Error("exempt route should not carry a deadline")
+		}
+		_, _ = res.Write([]byte("ok"))
+	}, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", w.Code)
+	}
+}
+
+func TestTimeoutExemptGroupWithApiVersion(t *testing.T) {
+	registry := NewRouteRegistry()
+	registry.SetApiVersion("/api/v1")
+	events := NewRouteGroup(registry, "/events")
+
+	deadline := false
+	events.AddHandler("/stream", GET, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		_, deadline = ctx.Deadline()
+	})
+
+	chains := NewMiddleWareChains()
+	chains.Append(NewTimeout(time.Second, WithTimeoutExemptGroups(events)))
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/events/stream", nil)
+	NewRequestContext(chains.GetHandlers(), registry, context.Background(), httptest.NewRecorder(), req).Run()
+	if deadline {
+		t.Fatal("exempt group route under the api version should not carry a deadline")
+	}
+}
+
+type timeoutMarker struct {
+	recorder *timeoutRecorder
+}
+
+func (s *timeoutMarker) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	s.recorder = trackRequestTimeout(ctx)
+	ctx.Next()
+}
+
+func TestTimeoutMarksLogger(t *testing.T) {
+	marker := &timeoutMarker{}
+
+	registry := NewRouteRegistry()
+	registry.AddHandler("/slow", GET, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		<-ctx.Done()
+	})
+	chains := NewMiddleWareChains()
+	chains.Append(marker)
+	chains.Append(NewTimeout(10 * time.Millisecond))
+
+	w := httptest.NewRecorder()
+	NewRequestContext(chains.GetHandlers(), registry, context.Background(), w, httptest.NewRequest(http.MethodGet, "/slow", nil)).Run()
+	if w.Code != http.StatusServiceUnavailable || marker.recorder == nil || !marker.recorder.timedOut.Load() {
+		t.Fatalf("timeout should be recorded, status %d", w.Code)
+	}
+}
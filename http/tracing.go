@@ -0,0 +1,107 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/muidea/magicEngine/trace"
+)
+
+// Tracing 链路追踪中间件, 解析traceparent/tracestate并为每个请求创建server span
+type Tracing struct {
+	tracer *trace.Tracer
+}
+
+// TracingOption configures a Tracing
+type TracingOption func(*Tracing)
+
+// WithTracingTracer sets the tracer used for server spans, default trace.GetTracer()
+func WithTracingTracer(tracer *trace.Tracer) TracingOption {
+	return func(t *Tracing) {
+		t.tracer = tracer
+	}
+}
+
+// NewTracing creates a new Tracing with optional configuration
+func NewTracing(opts ...TracingOption) *Tracing {
+	t := &Tracing{}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+func (s *Tracing) MiddleWareHandle(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+	tracer := s.tracer
+	if tracer == nil {
+		tracer = trace.GetTracer()
+	}
+
+	reqCtx := ctx.Context()
+	if sc, ok := trace.Extract(req.Header); ok {
+		reqCtx = trace.ContextWithRemoteSpanContext(reqCtx, sc)
+	}
+	spanCtx, span := tracer.Start(reqCtx, req.Method, trace.SpanKindServer)
+	defer span.End()
+
+	ctx.Update(spanCtx)
+	recorder := trackRoutePattern(ctx)
+	rw := res.(ResponseWriter)
+	res.Header().Set(trace.TraceParentHeader, span.SpanContext().TraceParent())
+
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.target", req.URL.RequestURI())
+	if requestID := GetRequestID(spanCtx); requestID != "" {
+		span.SetAttribute("request_id", requestID)
+	}
+
+	ctx.Next()
+
+	if recorder.pattern != "" {
+		span.SetName(req.Method + " " + recorder.pattern)
+		span.SetAttribute("http.route", recorder.pattern)
+	}
+	span.SetAttribute("http.status_code", rw.Status())
+	if rw.Status() >= http.StatusInternalServerError {
+		span.SetStatus(trace.StatusError, http.StatusText(rw.Status()))
+	}
+}
+
+// startMiddlewareSpan 请求已有span时为路由中间件创建子span
+func startMiddlewareSpan(ctx context.Context, handler MiddleWareHandler) (context.Context, *trace.Span) {
+	if trace.SpanFromContext(ctx) == nil {
+		return ctx, nil
+	}
+
+	name := "middleware"
+	if _, ok := handler.(*anonymousMiddleWareHandler); !ok {
+		name = "middleware " + strings.TrimPrefix(fmt.Sprintf("%T", handler), "*")
+	}
+	return trace.StartChild(ctx, name, trace.SpanKindInternal)
+}
+
+// startProxySpan 为代理请求创建client span
+func startProxySpan(ctx context.Context, req *http.Request, host string) (context.Context, *trace.Span) {
+	spanCtx, span := trace.StartChild(ctx, "proxy "+req.Method+" "+host, trace.SpanKindClient)
+	if span != nil {
+		span.SetAttribute("http.method", req.Method)
+		span.SetAttribute("net.peer.name", host)
+	}
+	return spanCtx, span
+}
+
+func endProxySpan(span *trace.Span, res http.ResponseWriter) {
+	if span == nil {
+		return
+	}
+	if rw, ok := res.(ResponseWriter); ok {
+		span.SetAttribute("http.status_code", rw.Status())
+		if rw.Status() >= http.StatusInternalServerError {
+			span.SetStatus(trace.StatusError, http.StatusText(rw.Status()))
+		}
+	}
+	span.End()
+}
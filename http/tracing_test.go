@@ -0,0 +1,131 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/muidea/magicEngine/trace"
+)
+
+func TestTracingContinuesRemoteTraceAndCreatesChildSpans(t *testing.T) {
+	var upstreamTraceParent string
+	oldTransport := http.DefaultTransport
+	http.DefaultTransport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		upstreamTraceParent = req.Header.Get(trace.TraceParentHeader)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader("proxied")),
+		}, nil
+	})
+	defer func() {
+		http.DefaultTransport = oldTransport
+	}()
+
+	exporter := trace.NewMemoryExporter()
+	registry := NewRouteRegistry()
+	registry.AddRoute(CreateProxyRoute("/api/:id", GET, "https://backend.example/target", false),
+		&anonymousMiddleWareHandler{handleFunc: func(ctx RequestContext, res http.ResponseWriter, req *http.Request) {
+			ctx.Next()
+		}})
+
+	chains := NewMiddleWareChains()
+	chains.Append(NewTracing(WithTracingTracer(trace.NewTracer(exporter))))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/7", nil)
+	req.Header.Set(trace.TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(trace.TraceStateHeader, "vendor=abc")
+	w := httptest.NewRecorder()
+	NewRequestContext(chains.GetHandlers(), registry, context.Background(), w, req).Run()
+
+	spans := exporter.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("spans = %d, want 3: %+v", len(spans), spans)
+	}
+	proxySpan, middlewareSpan, serverSpan := spans[0], spans[1], spans[2]
+
+	if serverSpan.Name != "GET /api/:id" || serverSpan.Kind != trace.SpanKindServer {
+		t.Fatalf("unexpected server span: %+v", serverSpan)
+	}
+	if serverSpan.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || serverSpan.ParentSpanID.String() != "00f067aa0ba902b7" {
+		t.Fatalf("server span should continue remote trace: %+v", serverSpan)
+	}
+	if serverSpan.TraceState != "vendor=abc" {
+		t.Fatalf("trace state = %q", serverSpan.TraceState)
+	}
+	if middlewareSpan.ParentSpanID != serverSpan.SpanID || middlewareSpan.Name != "middleware" {
+		t.Fatalf("unexpected middleware span: %+v", middlewareSpan)
+	}
+	if proxySpan.ParentSpanID != middlewareSpan.SpanID || proxySpan.Kind != trace.SpanKindClient {
+		t.Fatalf("unexpected proxy span: %+v", proxySpan)
+	}
+	if want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + proxySpan.SpanID.String() + "-01"; upstreamTraceParent != want {
+		t.Fatalf("upstream traceparent = %q, want %q", upstreamTraceParent, want)
+	}
+	if !strings.Contains(w.Header().Get(trace.TraceParentHeader), serverSpan.SpanID.String()) {
+		t.Fatalf("response traceparent = %q", w.Header().Get(trace.TraceParentHeader))
+	}
+}
+
+func TestProxyHTTPContextForwardsTraceParentAndRequestID(t *testing.T) {
+	var upstreamHeader http.Header
+	oldTransport := http.DefaultTransport
+	http.DefaultTransport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		upstreamHeader = req.Header.Clone()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader("proxied")),
+		}, nil
+	})
+	defer func() {
+		http.DefaultTransport = oldTransport
+	}()
+
+	exporter := trace.NewMemoryExporter()
+	chains := NewMiddleWareChains()
+	chains.Append(NewRequestID())
+	chains.Append(NewTracing(WithTracingTracer(trace.NewTracer(exporter))))
+
+	registry := NewRouteRegistry()
+	registry.AddHandler("/gateway", GET, func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		if err := ProxyHTTPContext(ctx, res, req, "https://backend.example/target", nil); err != nil {
+			t.Errorf("ProxyHTTP failed: %v", err)
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/gateway", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	w := httptest.NewRecorder()
+	NewRequestContext(chains.GetHandlers(), registry, context.Background(), w, req).Run()
+
+	spans := exporter.Spans()
+	if len(spans) != 2 || spans[0].Kind != trace.SpanKindClient {
+		t.Fatalf("expected a client span under the server span: %+v", spans)
+	}
+	if want := "00-" + spans[0].TraceID.String() + "-" + spans[0].SpanID.String() + "-01"; upstreamHeader.Get(trace.TraceParentHeader) != want {
+		t.Fatalf("upstream traceparent = %q, want %q", upstreamHeader.Get(trace.TraceParentHeader), want)
+	}
+	if upstreamHeader.Get(RequestIDHeader) != "req-42" {
+		t.Fatalf("upstream request id = %q", upstreamHeader.Get(RequestIDHeader))
+	}
+}
+
+func TestTracingStartsNewTraceOnInvalidTraceParent(t *testing.T) {
+	exporter := trace.NewMemoryExporter()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(trace.TraceParentHeader, "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+
+	serveWithMiddleware(NewTracing(WithTracingTracer(trace.NewTracer(exporter))), func(ctx context.Context, res http.ResponseWriter, req *http.Request) {
+		_, _ = res.Write([]byte("ok"))
+	}, req)
+
+	spans := exporter.Spans()
+	if len(spans) != 1 || spans[0].ParentSpanID.IsValid() || !spans[0].TraceID.IsValid() {
+		t.Fatalf("expected a new root span, got %+v", spans)
+	}
+}
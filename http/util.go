@@ -1,8 +1,22 @@
 package http
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 func panicInfo(info string) {
 	msg := fmt.Sprintf("[%s] %s\n", serverName, info)
 	panic(msg)
 }
+
+// matchPathPrefix 判断path是否等于某个前缀或位于其下
+func matchPathPrefix(path string, prefixes []string) bool {
+	for _, val := range prefixes {
+		val = strings.TrimRight(val, "/")
+		if val == "" || path == val || strings.HasPrefix(path, val+"/") {
+			return true
+		}
+	}
+	return false
+}
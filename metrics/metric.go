@@ -0,0 +1,422 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefBuckets 默认的耗时直方图分桶(秒)
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// SizeBuckets 默认的大小直方图分桶(字节)
+var SizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// Opts 指标基本信息
+type Opts struct {
+	Name string
+	Help string
+}
+
+// atomicFloat 基于CAS的float64原子值
+type atomicFloat struct {
+	bits uint64
+}
+
+func (f *atomicFloat) Add(val float64) {
+	for {
+		oldBits := atomic.LoadUint64(&f.bits)
+		newBits := math.Float64bits(math.Float64frombits(oldBits) + val)
+		if atomic.CompareAndSwapUint64(&f.bits, oldBits, newBits) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) Set(val float64) {
+	atomic.StoreUint64(&f.bits, math.Float64bits(val))
+}
+
+func (f *atomicFloat) Load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&f.bits))
+}
+
+// Counter 单调递增计数器
+type Counter struct {
+	opts  Opts
+	value atomicFloat
+}
+
+// Inc 加一
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+// Add 增加val, val必须非负
+func (c *Counter) Add(val float64) {
+	if val < 0 {
+		return
+	}
+	c.value.Add(val)
+}
+
+// Value 当前值
+func (c *Counter) Value() float64 {
+	return c.value.Load()
+}
+
+func (c *Counter) Name() string {
+	return c.opts.Name
+}
+
+func (c *Counter) Expose(buf *bytes.Buffer) {
+	writeHeader(buf, c.opts, typeCounter)
+	writeSample(buf, c.opts.Name, nil, nil, c.Value())
+}
+
+// Gauge 可增可减的瞬时值
+type Gauge struct {
+	opts  Opts
+	value atomicFloat
+}
+
+func (g *Gauge) Set(val float64) {
+	g.value.Set(val)
+}
+
+func (g *Gauge) Inc() {
+	g.value.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.value.Add(-1)
+}
+
+func (g *Gauge) Add(val float64) {
+	g.value.Add(val)
+}
+
+func (g *Gauge) Value() float64 {
+	return g.value.Load()
+}
+
+func (g *Gauge) Name() string {
+	return g.opts.Name
+}
+
+func (g *Gauge) Expose(buf *bytes.Buffer) {
+	writeHeader(buf, g.opts, typeGauge)
+	writeSample(buf, g.opts.Name, nil, nil, g.Value())
+}
+
+// Histogram 分桶统计观测值
+type Histogram struct {
+	opts        Opts
+	upperBounds []float64
+	counts      []uint64
+	count       uint64
+	sum         atomicFloat
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	upperBounds := append([]float64(nil), buckets...)
+	sort.Float64s(upperBounds)
+	return &Histogram{
+		upperBounds: upperBounds,
+		counts:      make([]uint64, len(upperBounds)),
+	}
+}
+
+// Observe 记录一次观测值
+func (h *Histogram) Observe(val float64) {
+	idx := sort.SearchFloat64s(h.upperBounds, val)
+	if idx < len(h.counts) {
+		atomic.AddUint64(&h.counts[idx], 1)
+	}
+	atomic.AddUint64(&h.count, 1)
+	h.sum.Add(val)
+}
+
+// Count 观测次数
+func (h *Histogram) Count() uint64 {
+	return atomic.LoadUint64(&h.count)
+}
+
+// Sum 观测值总和
+func (h *Histogram) Sum() float64 {
+	return h.sum.Load()
+}
+
+func (h *Histogram) Name() string {
+	return h.opts.Name
+}
+
+func (h *Histogram) Expose(buf *bytes.Buffer) {
+	writeHeader(buf, h.opts, typeHistogram)
+	h.write(buf, h.opts.Name, nil, nil)
+}
+
+func (h *Histogram) write(buf *bytes.Buffer, name string, labelNames, labelValues []string) {
+	bucketNames := append(append([]string(nil), labelNames...), "le")
+	bucketValues := append(append([]string(nil), labelValues...), "")
+	var cumulative uint64
+	for idx, bound := range h.upperBounds {
+		cumulative += atomic.LoadUint64(&h.counts[idx])
+		bucketValues[len(bucketValues)-1] = formatFloat(bound)
+		writeSample(buf, name+"_bucket", bucketNames, bucketValues, float64(cumulative))
+	}
+	count := h.Count()
+	bucketValues[len(bucketValues)-1] = "+Inf"
+	writeSample(buf, name+"_bucket", bucketNames, bucketValues, float64(count))
+	writeSample(buf, name+"_sum", labelNames, labelValues, h.Sum())
+	writeSample(buf, name+"_count", labelNames, labelValues, float64(count))
+}
+
+// metricVec 按标签值区分的一组指标
+type metricVec[T any] struct {
+	opts       Opts
+	metricType string
+	labelNames []string
+	newMetric  func() *T
+	children   map[string]*vecChild[T]
+	mu         sync.RWMutex
+}
+
+type vecChild[T any] struct {
+	labelValues []string
+	metric      *T
+}
+
+func (v *metricVec[T]) with(labelValues ...string) *T {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.opts.Name, len(v.labelNames), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	v.mu.RLock()
+	child, ok := v.children[key]
+	v.mu.RUnlock()
+	if ok {
+		return child.metric
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if child, ok = v.children[key]; ok {
+		return child.metric
+	}
+	child = &vecChild[T]{labelValues: append([]string(nil), labelValues...), metric: v.newMetric()}
+	v.children[key] = child
+	return child.metric
+}
+
+func (v *metricVec[T]) sortedChildren() []*vecChild[T] {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	keys := make([]string, 0, len(v.children))
+	for key := range v.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	ret := make([]*vecChild[T], 0, len(keys))
+	for _, key := range keys {
+		ret = append(ret, v.children[key])
+	}
+	return ret
+}
+
+func (v *metricVec[T]) Name() string {
+	return v.opts.Name
+}
+
+// CounterVec 带标签的计数器
+type CounterVec struct {
+	metricVec[Counter]
+}
+
+// NewCounterVec 新建带标签的计数器
+func NewCounterVec(opts Opts, labelNames ...string) *CounterVec {
+	return &CounterVec{metricVec[Counter]{
+		opts:       opts,
+		metricType: typeCounter,
+		labelNames: labelNames,
+		newMetric:  func() *Counter { return &Counter{} },
+		children:   map[string]*vecChild[Counter]{},
+	}}
+}
+
+// With 按标签值获取计数器
+func (v *CounterVec) With(labelValues ...string) *Counter {
+	return v.with(labelValues...)
+}
+
+func (v *CounterVec) Expose(buf *bytes.Buffer) {
+	writeHeader(buf, v.opts, typeCounter)
+	for _, child := range v.sortedChildren() {
+		writeSample(buf, v.opts.Name, v.labelNames, child.labelValues, child.metric.Value())
+	}
+}
+
+// GaugeVec 带标签的瞬时值
+type GaugeVec struct {
+	metricVec[Gauge]
+}
+
+// NewGaugeVec 新建带标签的瞬时值
+func NewGaugeVec(opts Opts, labelNames ...string) *GaugeVec {
+	return &GaugeVec{metricVec[Gauge]{
+		opts:       opts,
+		metricType: typeGauge,
+		labelNames: labelNames,
+		newMetric:  func() *Gauge { return &Gauge{} },
+		children:   map[string]*vecChild[Gauge]{},
+	}}
+}
+
+// With 按标签值获取瞬时值
+func (v *GaugeVec) With(labelValues ...string) *Gauge {
+	return v.with(labelValues...)
+}
+
+func (v *GaugeVec) Expose(buf *bytes.Buffer) {
+	writeHeader(buf, v.opts, typeGauge)
+	for _, child := range v.sortedChildren() {
+		writeSample(buf, v.opts.Name, v.labelNames, child.labelValues, child.metric.Value())
+	}
+}
+
+// HistogramVec 带标签的直方图
+type HistogramVec struct {
+	metricVec[Histogram]
+}
+
+// NewHistogramVec 新建带标签的直方图, buckets为空时使用DefBuckets
+func NewHistogramVec(opts Opts, buckets []float64, labelNames ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	return &HistogramVec{metricVec[Histogram]{
+		opts:       opts,
+		metricType: typeHistogram,
+		labelNames: labelNames,
+		newMetric:  func() *Histogram { return newHistogram(buckets) },
+		children:   map[string]*vecChild[Histogram]{},
+	}}
+}
+
+// With 按标签值获取直方图
+func (v *HistogramVec) With(labelValues ...string) *Histogram {
+	return v.with(labelValues...)
+}
+
+func (v *HistogramVec) Expose(buf *bytes.Buffer) {
+	writeHeader(buf, v.opts, typeHistogram)
+	for _, child := range v.sortedChildren() {
+		child.metric.write(buf, v.opts.Name, v.labelNames, child.labelValues)
+	}
+}
+
+// NewCounter 新建无标签计数器
+func NewCounter(opts Opts) *Counter {
+	return &Counter{opts: opts}
+}
+
+// NewGauge 新建无标签瞬时值
+func NewGauge(opts Opts) *Gauge {
+	return &Gauge{opts: opts}
+}
+
+// NewHistogram 新建无标签直方图, buckets为空时使用DefBuckets
+func NewHistogram(opts Opts, buckets []float64) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	h := newHistogram(buckets)
+	h.opts = opts
+	return h
+}
+
+// GaugeFunc 输出时调用函数获取当前值
+type GaugeFunc struct {
+	opts     Opts
+	function func() float64
+}
+
+// NewGaugeFunc 新建由函数提供值的瞬时值
+func NewGaugeFunc(opts Opts, function func() float64) *GaugeFunc {
+	return &GaugeFunc{opts: opts, function: function}
+}
+
+func (g *GaugeFunc) Name() string {
+	return g.opts.Name
+}
+
+func (g *GaugeFunc) Expose(buf *bytes.Buffer) {
+	writeHeader(buf, g.opts, typeGauge)
+	writeSample(buf, g.opts.Name, nil, nil, g.function())
+}
+
+func writeHeader(buf *bytes.Buffer, opts Opts, metricType string) {
+	if opts.Help != "" {
+		fmt.Fprintf(buf, "# HELP %s %s\n", opts.Name, escapeHelp(opts.Help))
+	}
+	fmt.Fprintf(buf, "# TYPE %s %s\n", opts.Name, metricType)
+}
+
+func writeSample(buf *bytes.Buffer, name string, labelNames, labelValues []string, value float64) {
+	buf.WriteString(name)
+	if len(labelNames) > 0 {
+		buf.WriteByte('{')
+		for idx, labelName := range labelNames {
+			if idx > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(labelName)
+			buf.WriteString(`="`)
+			buf.WriteString(escapeLabel(labelValues[idx]))
+			buf.WriteByte('"')
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(formatFloat(value))
+	buf.WriteByte('\n')
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(val string) string {
+	return helpReplacer.Replace(val)
+}
+
+func escapeLabel(val string) string {
+	return labelReplacer.Replace(val)
+}
+
+func formatFloat(val float64) string {
+	switch {
+	case math.IsInf(val, 1):
+		return "+Inf"
+	case math.IsInf(val, -1):
+		return "-Inf"
+	case math.IsNaN(val):
+		return "NaN"
+	}
+	return strconv.FormatFloat(val, 'g', -1, 64)
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryExposition(t *testing.T) {
+	registry := NewRegistry()
+	requests := NewCounterVec(Opts{Name: "app_requests_total", Help: "Total requests."}, "method", "path")
+	inFlight := NewGauge(Opts{Name: "app_in_flight"})
+	latency := NewHistogram(Opts{Name: "app_latency_seconds"}, []float64{0.1, 1})
+	registry.MustRegister(requests, inFlight, latency, NewGaugeFunc(Opts{Name: "app_up"}, func() float64 { return 1 }))
+
+	requests.With("GET", `/a"b`).Inc()
+	requests.With("GET", `/a"b`).Add(2)
+	inFlight.Inc()
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	latency.Observe(5)
+
+	w := httptest.NewRecorder()
+	registry.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+
+	expected := []string{
+		"# HELP app_requests_total Total requests.\n# TYPE app_requests_total counter\n",
+		`app_requests_total{method="GET",path="/a\"b"} 3` + "\n",
+		"app_in_flight 1\n",
+		"app_up 1\n",
+		`app_latency_seconds_bucket{le="0.1"} 1` + "\n",
+		`app_latency_seconds_bucket{le="1"} 2` + "\n",
+		`app_latency_seconds_bucket{le="+Inf"} 3` + "\n",
+		"app_latency_seconds_sum 5.55\n",
+		"app_latency_seconds_count 3\n",
+	}
+	for _, val := range expected {
+		if !strings.Contains(body, val) {
+			t.Errorf("exposition missing %q in:\n%s", val, body)
+		}
+	}
+	if w.Header().Get("Content-Type") != ContentType {
+		t.Fatalf("content type = %q", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestRegistryRejectsDuplicate(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.Register(NewGauge(Opts{Name: "dup"})); err != nil {
+		t.Fatalf("first register failed: %v", err)
+	}
+	if err := registry.Register(NewCounter(Opts{Name: "dup"})); err == nil {
+		t.Fatal("duplicate register should fail")
+	}
+}
+
+func TestVecLabelCountMismatchPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic on label count mismatch")
+		}
+	}()
+	NewCounterVec(Opts{Name: "c"}, "a", "b").With("only-one")
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// ContentType 文本暴露格式的Content-Type
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Collector 指标族, 负责输出自己的文本暴露格式
+type Collector interface {
+	Name() string
+	Expose(buf *bytes.Buffer)
+}
+
+// Registry 指标注册表
+type Registry struct {
+	collectors map[string]Collector
+	mu         sync.RWMutex
+}
+
+// NewRegistry 新建指标注册表
+func NewRegistry() *Registry {
+	return &Registry{collectors: map[string]Collector{}}
+}
+
+var defaultRegistry = NewRegistry()
+
+// Default 返回默认注册表, 内置的HTTP/SSE/TCP指标都注册在这里
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Register 注册指标族, 同名指标已存在时返回错误
+func (s *Registry) Register(collector Collector) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.collectors[collector.Name()]; ok {
+		return fmt.Errorf("metrics: duplicate metric %s", collector.Name())
+	}
+	s.collectors[collector.Name()] = collector
+	return nil
+}
+
+// MustRegister 注册指标族, 失败时panic
+func (s *Registry) MustRegister(collectors ...Collector) {
+	for _, val := range collectors {
+		if err := s.Register(val); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Unregister 注销指标族
+func (s *Registry) Unregister(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.collectors, name)
+}
+
+// WriteTo 按名称顺序输出全部指标
+func (s *Registry) WriteTo(w io.Writer) (int64, error) {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.collectors))
+	for name := range s.collectors {
+		names = append(names, name)
+	}
+	collectors := make([]Collector, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		collectors = append(collectors, s.collectors[name])
+	}
+	s.mu.RUnlock()
+
+	var buf bytes.Buffer
+	for _, val := range collectors {
+		val.Expose(&buf)
+	}
+	return buf.WriteTo(w)
+}
+
+// ServeHTTP 输出文本暴露格式, 可以直接挂载为http.Handler
+func (s *Registry) ServeHTTP(res http.ResponseWriter, _ *http.Request) {
+	res.Header().Set("Content-Type", ContentType)
+	res.WriteHeader(http.StatusOK)
+	_, _ = s.WriteTo(res)
+}
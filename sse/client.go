@@ -13,6 +13,9 @@ import (
 	"time"
 
 	"log/slog"
+
+	engine "github.com/muidea/magicEngine/http"
+	"github.com/muidea/magicEngine/trace"
 )
 
 type Client struct {
@@ -84,6 +87,11 @@ func (s *Client) Get(ctx context.Context, header url.Values, sink StreamSinker)
 		for k, v := range header {
 			requestVal.Header.Set(k, v[0])
 		}
+		engine.PropagateRequestID(ctx, requestVal.Header)
+		span := startSpan(ctx, requestVal)
+		defer func() {
+			endSpan(span, err)
+		}()
 		requestVal.Header.Set("Accept", sseStream)
 		requestVal.Header.Set("Cache-Control", "no-cache")
 		if s.lastEventID != "" {
@@ -172,6 +180,11 @@ func (s *Client) Post(ctx context.Context, byteVal []byte, header url.Values, si
 		for k, v := range header {
 			requestVal.Header.Set(k, v[0])
 		}
+		engine.PropagateRequestID(ctx, requestVal.Header)
+		span := startSpan(ctx, requestVal)
+		defer func() {
+			endSpan(span, err)
+		}()
 		requestVal.Header.Set("Accept", sseStream)
 		requestVal.Header.Set("Cache-Control", "no-cache")
 		if s.lastEventID != "" {
@@ -314,3 +327,22 @@ func (s *Client) handleRetry(retryCount int) (ret int, err error) {
 	time.Sleep(waitTime)
 	return retryCount + 1, nil
 }
+
+// startSpan 调用方context中有span时为SSE请求创建client span, 并传播traceparent
+func startSpan(ctx context.Context, req *http.Request) *trace.Span {
+	spanCtx, span := trace.StartChild(ctx, "sse "+req.Method+" "+req.URL.Host, trace.SpanKindClient)
+	trace.Inject(spanCtx, req.Header)
+	if span != nil {
+		span.SetAttribute("http.method", req.Method)
+		span.SetAttribute("http.url", req.URL.String())
+	}
+	return span
+}
+
+func endSpan(span *trace.Span, err error) {
+	if span == nil {
+		return
+	}
+	span.RecordError(err)
+	span.End()
+}
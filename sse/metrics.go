@@ -0,0 +1,24 @@
+package sse
+
+import (
+	"github.com/muidea/magicEngine/metrics"
+)
+
+var (
+	activeHolders = metrics.NewGauge(metrics.Opts{
+		Name: "sse_active_holders",
+		Help: "Number of SSE holders registered in HolderRegistry.",
+	})
+	eventsSent = metrics.NewCounter(metrics.Opts{
+		Name: "sse_events_sent_total",
+		Help: "Total number of SSE events written to clients.",
+	})
+	heartbeatFailures = metrics.NewCounter(metrics.Opts{
+		Name: "sse_heartbeat_failures_total",
+		Help: "Total number of failed SSE heartbeats.",
+	})
+)
+
+func init() {
+	metrics.Default().MustRegister(activeHolders, eventsSent, heartbeatFailures)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"log/slog"
@@ -76,6 +77,7 @@ func (s *Holder) OnRecv(event string, data []byte) {
 		slog.Error("write data failed", "err", err)
 		return
 	}
+	eventsSent.Inc()
 
 	flusherVal, flusherOK := s.httpResponseWriter.(http.Flusher)
 	if flusherOK {
@@ -103,6 +105,7 @@ func (s *Holder) heartbeat() (err error) {
 	s.httpResponseWriter.Header().Set("Content-Type", sseStream)
 	_, err = s.httpResponseWriter.Write([]byte(": ping\n\n"))
 	if err != nil {
+		heartbeatFailures.Inc()
 		slog.Error("write heartbeat failed", "err", err)
 		return
 	}
@@ -216,6 +219,8 @@ func NewHolder(res http.ResponseWriter, req *http.Request) *Holder {
 type HolderRegistry struct {
 	holderMap sync.Map
 	mu        sync.Mutex
+	holders   atomic.Int64
+	draining  atomic.Bool
 }
 
 func CreateHolderRegistry() *HolderRegistry {
@@ -235,6 +240,8 @@ func (s *HolderRegistry) NewHolder(res http.ResponseWriter, req *http.Request) *
 	}
 
 	s.holderMap.Store(holder.sseID, holder)
+	s.holders.Add(1)
+	activeHolders.Inc()
 	return holder
 }
 
@@ -254,5 +261,23 @@ func (s *HolderRegistry) GetHolder(res http.ResponseWriter, req *http.Request) *
 }
 
 func (s *HolderRegistry) OnClose(id string) {
-	s.holderMap.Delete(id)
+	if _, ok := s.holderMap.LoadAndDelete(id); ok {
+		s.holders.Add(-1)
+		activeHolders.Dec()
+	}
+}
+
+// Drain 标记进入排空状态, 之后Draining返回true, 已建立的连接由调用方自行结束
+func (s *HolderRegistry) Drain() {
+	s.draining.Store(true)
+}
+
+// Draining 是否处于排空状态, 用于就绪检查
+func (s *HolderRegistry) Draining() bool {
+	return s.draining.Load()
+}
+
+// Len 当前活跃的Holder数量
+func (s *HolderRegistry) Len() int {
+	return int(s.holders.Load())
 }
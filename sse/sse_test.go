@@ -11,6 +11,9 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	engine "github.com/muidea/magicEngine/http"
+	"github.com/muidea/magicEngine/trace"
 )
 
 type sinkRecorder struct {
@@ -113,3 +116,49 @@ func TestHolderHeartbeatWritesCompleteFrame(t *testing.T) {
 		t.Fatalf("unexpected heartbeat frame: %q", res.Body.String())
 	}
 }
+
+func TestSetRequestIDFromContext(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	engine.PropagateRequestID(engine.WithRequestID(context.Background(), "sse-id"), req.Header)
+	if req.Header.Get(engine.RequestIDHeader) != "sse-id" {
+		t.Fatalf("request id = %q, want %q", req.Header.Get(engine.RequestIDHeader), "sse-id")
+	}
+
+	req.Header.Set(engine.RequestIDHeader, "explicit")
+	engine.PropagateRequestID(engine.WithRequestID(context.Background(), "sse-id"), req.Header)
+	if req.Header.Get(engine.RequestIDHeader) != "explicit" {
+		t.Fatal("explicit request id header should be kept")
+	}
+}
+
+func TestStartSpanPropagatesTraceParent(t *testing.T) {
+	exporter := trace.NewMemoryExporter()
+	ctx, root := trace.NewTracer(exporter).Start(context.Background(), "root", trace.SpanKindServer)
+
+	req := httptest.NewRequest(http.MethodGet, "http://sse.example/events", nil)
+	span := startSpan(ctx, req)
+	endSpan(span, errors.New("stream closed"))
+	root.End()
+
+	spans := exporter.Spans()
+	if len(spans) != 2 || spans[0].ParentSpanID != root.SpanContext().SpanID || spans[0].Status != trace.StatusError {
+		t.Fatalf("unexpected spans: %+v", spans)
+	}
+	if !strings.Contains(req.Header.Get(trace.TraceParentHeader), spans[0].SpanID.String()) {
+		t.Fatalf("traceparent = %q", req.Header.Get(trace.TraceParentHeader))
+	}
+}
+
+func TestHolderRegistryDrain(t *testing.T) {
+	registry := CreateHolderRegistry()
+	holder := registry.NewHolder(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/events", nil))
+	if registry.Len() != 1 || registry.Draining() {
+		t.Fatalf("unexpected registry state %d %v", registry.Len(), registry.Draining())
+	}
+
+	registry.Drain()
+	holder.OnClose()
+	if registry.Len() != 0 || !registry.Draining() {
+		t.Fatalf("unexpected registry state after drain %d %v", registry.Len(), registry.Draining())
+	}
+}
@@ -58,8 +58,10 @@ func (s *SimpleEndpointManger) OnConnect(ep Endpoint) {
 		return
 	}
 
-	s.endpointMap.Store(ep.String(), ep)
-	s.executePtr.Run(func() {
+	if _, loaded := s.endpointMap.LoadOrStore(ep.String(), ep); !loaded {
+		activeEndpoints.Inc()
+	}
+	runTracked(s.executePtr, func() {
 		s.observer.OnConnect(ep)
 	})
 }
@@ -69,8 +71,10 @@ func (s *SimpleEndpointManger) OnDisConnect(ep Endpoint) {
 		return
 	}
 
-	s.endpointMap.Delete(ep.String())
-	s.executePtr.Run(func() {
+	if _, ok := s.endpointMap.LoadAndDelete(ep.String()); ok {
+		activeEndpoints.Dec()
+	}
+	runTracked(s.executePtr, func() {
 		s.observer.OnDisConnect(ep)
 	})
 }
@@ -80,7 +84,7 @@ func (s *SimpleEndpointManger) OnRecvData(ep Endpoint, data []byte) {
 		return
 	}
 
-	s.executePtr.Run(func() {
+	runTracked(s.executePtr, func() {
 		s.observer.OnRecvData(ep, data)
 	})
 }
@@ -122,6 +126,7 @@ func (s *endpointImpl) SendData(data []byte) (err error) {
 		}
 
 		offSet += sendSize
+		sentBytes.Add(float64(sendSize))
 		if offSet >= totalSize {
 			break
 		}
@@ -159,6 +164,7 @@ func (s *endpointImpl) RecvData() (err error) {
 			return readErr
 		}
 
+		receivedBytes.Add(float64(readSize))
 		if s.observer != nil && readSize > 0 {
 			s.observer.OnRecvData(s, buffer[:readSize])
 		}
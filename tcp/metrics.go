@@ -0,0 +1,38 @@
+package tcp
+
+import (
+	"github.com/muidea/magicCommon/execute"
+	"github.com/muidea/magicEngine/metrics"
+)
+
+var (
+	activeEndpoints = metrics.NewGauge(metrics.Opts{
+		Name: "tcp_active_endpoints",
+		Help: "Number of endpoints tracked by SimpleEndpointManger.",
+	})
+	receivedBytes = metrics.NewCounter(metrics.Opts{
+		Name: "tcp_received_bytes_total",
+		Help: "Total number of bytes read from TCP endpoints.",
+	})
+	sentBytes = metrics.NewCounter(metrics.Opts{
+		Name: "tcp_sent_bytes_total",
+		Help: "Total number of bytes written to TCP endpoints.",
+	})
+	executeQueueDepth = metrics.NewGauge(metrics.Opts{
+		Name: "tcp_execute_queue_depth",
+		Help: "Number of observer callbacks queued or running in execute.Execute.",
+	})
+)
+
+func init() {
+	metrics.Default().MustRegister(activeEndpoints, receivedBytes, sentBytes, executeQueueDepth)
+}
+
+// runTracked 提交任务并统计排队中和执行中的任务数
+func runTracked(executePtr *execute.Execute, funcPtr func()) {
+	executeQueueDepth.Inc()
+	executePtr.Run(func() {
+		defer executeQueueDepth.Dec()
+		funcPtr()
+	})
+}
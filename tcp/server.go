@@ -1,7 +1,11 @@
 package tcp
 
 import (
+	"context"
+	"errors"
 	"net"
+	"sync"
+	"sync/atomic"
 
 	"log/slog"
 
@@ -12,6 +16,14 @@ type Server interface {
 	Run(bindAddr string) error
 }
 
+// GracefulServer Server 的可选接口, NewServer 返回的 Server 都实现
+type GracefulServer interface {
+	// Shutdown 停止接受新连接并等待已派发的连接处理结束, ctx结束时提前返回
+	Shutdown(ctx context.Context) error
+	// Draining 调用Shutdown后返回true, 用于就绪检查
+	Draining() bool
+}
+
 type ServerSink interface {
 	OnNewConnect(conn net.Conn)
 }
@@ -19,6 +31,12 @@ type ServerSink interface {
 type serverImpl struct {
 	executePtr *execute.Execute
 	serverSink ServerSink
+
+	// listenerLock 同时保护draining的设置和activeGroup.Add, 保证Shutdown开始Wait后不再Add
+	listenerLock sync.Mutex
+	listener     net.Listener
+	draining     atomic.Bool
+	activeGroup  sync.WaitGroup
 }
 
 func NewServer(sink ServerSink, executePtr *execute.Execute) Server {
@@ -39,16 +57,39 @@ func (s *serverImpl) Run(bindAddr string) (err error) {
 		_ = listenerVal.Close()
 	}()
 
+	s.listenerLock.Lock()
+	s.listener = listenerVal
+	s.listenerLock.Unlock()
+	if s.draining.Load() {
+		return
+	}
+
 	slog.Info("TCP server started", "addr", bindAddr)
 	for {
 		connVal, connErr := listenerVal.Accept()
 		if connErr != nil {
+			if s.draining.Load() || errors.Is(connErr, net.ErrClosed) {
+				slog.Info("TCP server stopped", "addr", bindAddr)
+				return
+			}
 			slog.Error("accept new connection failed", "err", connErr)
 			continue
 		}
 
+		s.listenerLock.Lock()
+		if s.draining.Load() {
+			s.listenerLock.Unlock()
+			// Shutdown开始后才完成Accept的连接不再派发
+			_ = connVal.Close()
+			slog.Info("TCP server stopped", "addr", bindAddr)
+			return
+		}
+		s.activeGroup.Add(1)
+		s.listenerLock.Unlock()
+
 		slog.Info("accepted new connection", "from", connVal.RemoteAddr().String())
 		s.executePtr.Run(func() {
+			defer s.activeGroup.Done()
 			if s.serverSink == nil {
 				_ = connVal.Close()
 				return
@@ -58,3 +99,29 @@ func (s *serverImpl) Run(bindAddr string) (err error) {
 		})
 	}
 }
+
+func (s *serverImpl) Shutdown(ctx context.Context) error {
+	s.listenerLock.Lock()
+	s.draining.Store(true)
+	if s.listener != nil {
+		_ = s.listener.Close()
+	}
+	s.listenerLock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.activeGroup.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *serverImpl) Draining() bool {
+	return s.draining.Load()
+}
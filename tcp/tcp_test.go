@@ -2,10 +2,12 @@ package tcp
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"net"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -182,3 +184,86 @@ func TestSimpleEndpointManagerTracksLifecycle(t *testing.T) {
 		t.Fatalf("unexpected payloads: %#v", observer.payloads)
 	}
 }
+
+func TestServerShutdownStopsRun(t *testing.T) {
+	execVal := execute.NewExecute(10)
+	svr := NewServer(nil, &execVal)
+	done := make(chan error, 1)
+	go func() {
+		done <- svr.Run("127.0.0.1:0")
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	if err := svr.(GracefulServer).Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	if !svr.(GracefulServer).Draining() {
+		t.Fatal("server should be draining after shutdown")
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("run should stop cleanly, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("run did not stop after shutdown")
+	}
+}
+
+type countingSink struct {
+	dispatched atomic.Int32
+}
+
+func (s *countingSink) OnNewConnect(conn net.Conn) {
+	s.dispatched.Add(1)
+	_ = conn.Close()
+}
+
+func TestServerShutdownDoesNotDispatchLateConnections(t *testing.T) {
+	probe, probeErr := net.Listen("tcp", "127.0.0.1:0")
+	if probeErr != nil {
+		t.Fatalf("listen failed: %v", probeErr)
+	}
+	addr := probe.Addr().String()
+	_ = probe.Close()
+
+	execVal := execute.NewExecute(10)
+	sink := &countingSink{}
+	svr := NewServer(sink, &execVal)
+	go func() {
+		_ = svr.Run(addr)
+	}()
+
+	stop := make(chan struct{})
+	var dialers sync.WaitGroup
+	for idx := 0; idx < 4; idx++ {
+		dialers.Add(1)
+		go func() {
+			defer dialers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if conn, err := net.DialTimeout("tcp", addr, 50*time.Millisecond); err == nil {
+					_ = conn.Close()
+				}
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if err := svr.(GracefulServer).Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	dispatched := sink.dispatched.Load()
+	time.Sleep(20 * time.Millisecond)
+	close(stop)
+	dialers.Wait()
+
+	if after := sink.dispatched.Load(); after != dispatched {
+		t.Fatalf("connections dispatched after shutdown returned: %d -> %d", dispatched, after)
+	}
+}
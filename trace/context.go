@@ -0,0 +1,154 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// TraceParentHeader W3C Trace Context traceparent头
+	TraceParentHeader = "traceparent"
+	// TraceStateHeader W3C Trace Context tracestate头
+	TraceStateHeader = "tracestate"
+)
+
+// FlagSampled traceparent中的采样标记
+const FlagSampled byte = 0x01
+
+// ErrInvalidTraceParent traceparent格式非法
+var ErrInvalidTraceParent = errors.New("invalid traceparent")
+
+// TraceID 16字节的trace标识
+type TraceID [16]byte
+
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (t TraceID) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// SpanID 8字节的span标识
+type SpanID [8]byte
+
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func (s SpanID) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// SpanContext 跨进程传播的span信息
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      byte
+	TraceState string
+	Remote     bool
+}
+
+func (s SpanContext) IsValid() bool {
+	return s.TraceID.IsValid() && s.SpanID.IsValid()
+}
+
+func (s SpanContext) IsSampled() bool {
+	return s.Flags&FlagSampled != 0
+}
+
+// TraceParent 按version 00格式输出traceparent
+func (s SpanContext) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", s.TraceID, s.SpanID, s.Flags)
+}
+
+// ParseTraceParent 解析traceparent, 未知version按00的前四段解析
+func ParseTraceParent(val string) (SpanContext, error) {
+	val = strings.TrimSpace(val)
+	parts := strings.Split(val, "-")
+	if len(parts) < 4 {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+
+	version, versionErr := decodeHex(parts[0], 1)
+	if versionErr != nil || version[0] == 0xff || (version[0] == 0 && len(parts) != 4) {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+
+	sc := SpanContext{Remote: true}
+	traceID, traceErr := decodeHex(parts[1], len(sc.TraceID))
+	spanID, spanErr := decodeHex(parts[2], len(sc.SpanID))
+	flags, flagsErr := decodeHex(parts[3], 1)
+	if traceErr != nil || spanErr != nil || flagsErr != nil {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+	return sc, nil
+}
+
+// decodeHex 只接受小写十六进制
+func decodeHex(val string, size int) ([]byte, error) {
+	if len(val) != size*2 || strings.ToLower(val) != val {
+		return nil, ErrInvalidTraceParent
+	}
+	return hex.DecodeString(val)
+}
+
+// Extract 从请求头中解析上游的SpanContext
+func Extract(header http.Header) (SpanContext, bool) {
+	sc, err := ParseTraceParent(header.Get(TraceParentHeader))
+	if err != nil {
+		return SpanContext{}, false
+	}
+
+	sc.TraceState = strings.Join(header.Values(TraceStateHeader), ",")
+	return sc, true
+}
+
+// Inject 把ctx中当前span的traceparent/tracestate写入请求头
+func Inject(ctx context.Context, header http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+
+	header.Set(TraceParentHeader, sc.TraceParent())
+	if sc.TraceState != "" {
+		header.Set(TraceStateHeader, sc.TraceState)
+	} else {
+		header.Del(TraceStateHeader)
+	}
+}
+
+func newTraceID() (ret TraceID) {
+	for !ret.IsValid() {
+		_, _ = rand.Read(ret[:])
+	}
+	return
+}
+
+func newSpanID() (ret SpanID) {
+	for !ret.IsValid() {
+		_, _ = rand.Read(ret[:])
+	}
+	return
+}
@@ -0,0 +1,96 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Exporter span输出接口, 对接具体的追踪后端
+type Exporter interface {
+	Export(span *SpanData) error
+	Close() error
+}
+
+// MemoryExporter 把span保存在内存中, 用于测试
+type MemoryExporter struct {
+	spans []SpanData
+	mu    sync.Mutex
+}
+
+// NewMemoryExporter 新建内存Exporter
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+func (s *MemoryExporter) Export(span *SpanData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.spans = append(s.spans, *span)
+	return nil
+}
+
+func (s *MemoryExporter) Close() error {
+	return nil
+}
+
+// Spans 返回已结束span的副本, 按结束顺序排列
+func (s *MemoryExporter) Spans() []SpanData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]SpanData(nil), s.spans...)
+}
+
+// Reset 清空已记录的span
+func (s *MemoryExporter) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.spans = nil
+}
+
+// JSONLinesExporter 每个span输出一行JSON
+type JSONLinesExporter struct {
+	writer  io.Writer
+	encoder *json.Encoder
+	mu      sync.Mutex
+}
+
+// NewJSONLinesExporter 输出到writer, writer实现io.Closer时Close会一并关闭
+func NewJSONLinesExporter(writer io.Writer) *JSONLinesExporter {
+	return &JSONLinesExporter{writer: writer, encoder: json.NewEncoder(writer)}
+}
+
+// NewFileExporter 以追加方式打开文件输出JSON lines
+func NewFileExporter(filePath string) (*JSONLinesExporter, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return nil, err
+	}
+
+	fileVal, fileErr := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if fileErr != nil {
+		return nil, fileErr
+	}
+	return NewJSONLinesExporter(fileVal), nil
+}
+
+func (s *JSONLinesExporter) Export(span *SpanData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.encoder.Encode(span)
+}
+
+func (s *JSONLinesExporter) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if closer, ok := s.writer.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
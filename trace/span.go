@@ -0,0 +1,136 @@
+package trace
+
+import (
+	"sync"
+	"time"
+)
+
+// SpanKind span类型
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = iota
+	SpanKindServer
+	SpanKindClient
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+func (k SpanKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// StatusCode span状态
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = iota
+	StatusOK
+	StatusError
+)
+
+func (c StatusCode) String() string {
+	switch c {
+	case StatusOK:
+		return "ok"
+	case StatusError:
+		return "error"
+	default:
+		return "unset"
+	}
+}
+
+func (c StatusCode) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// SpanData 结束后的span快照, 交给Exporter输出
+type SpanData struct {
+	Name          string         `json:"name"`
+	Kind          SpanKind       `json:"kind"`
+	TraceID       TraceID        `json:"trace_id"`
+	SpanID        SpanID         `json:"span_id"`
+	ParentSpanID  SpanID         `json:"parent_span_id,omitzero"`
+	TraceState    string         `json:"trace_state,omitempty"`
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       time.Time      `json:"end_time"`
+	Attributes    map[string]any `json:"attributes,omitempty"`
+	Status        StatusCode     `json:"status"`
+	StatusMessage string         `json:"status_message,omitempty"`
+}
+
+// Span 进行中的span
+type Span struct {
+	tracer      *Tracer
+	spanContext SpanContext
+	data        SpanData
+	ended       bool
+	mu          sync.Mutex
+}
+
+// SpanContext 返回用于传播的SpanContext
+func (s *Span) SpanContext() SpanContext {
+	return s.spanContext
+}
+
+// SetName 修改span名称, 比如路由匹配之后改成路由规则
+func (s *Span) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Name = name
+}
+
+// SetAttribute 设置属性
+func (s *Span) SetAttribute(key string, val any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.Attributes == nil {
+		s.data.Attributes = map[string]any{}
+	}
+	s.data.Attributes[key] = val
+}
+
+// SetStatus 设置状态
+func (s *Span) SetStatus(code StatusCode, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Status = code
+	s.data.StatusMessage = message
+}
+
+// RecordError 记录错误并把状态设为StatusError
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End 结束span, 已采样的span交给Exporter, 重复调用无效
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if s.spanContext.IsSampled() {
+		s.tracer.export(&data)
+	}
+}
@@ -0,0 +1,95 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	sc, err := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if !sc.IsSampled() || !sc.Remote {
+		t.Fatalf("unexpected span context: %+v", sc)
+	}
+	if sc.TraceParent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Fatalf("round trip = %q", sc.TraceParent())
+	}
+
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	}
+	for _, val := range invalid {
+		if _, err := ParseTraceParent(val); err == nil {
+			t.Errorf("ParseTraceParent(%q) should fail", val)
+		}
+	}
+
+	if _, err := ParseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"); err != nil {
+		t.Fatalf("future version with extra fields should parse: %v", err)
+	}
+}
+
+func TestChildSpanAndInject(t *testing.T) {
+	exporter := NewMemoryExporter()
+	tracer := NewTracer(exporter)
+
+	ctx, root := tracer.Start(context.Background(), "root", SpanKindServer)
+	if _, span := StartChild(context.Background(), "orphan", SpanKindInternal); span != nil {
+		t.Fatal("StartChild without parent should not create span")
+	}
+	childCtx, child := StartChild(ctx, "child", SpanKindClient)
+
+	header := http.Header{}
+	Inject(childCtx, header)
+	sc, ok := Extract(header)
+	if !ok || sc.TraceID != root.SpanContext().TraceID || sc.SpanID != child.SpanContext().SpanID {
+		t.Fatalf("unexpected propagated context: %+v", sc)
+	}
+
+	child.End()
+	child.End()
+	root.End()
+	spans := exporter.Spans()
+	if len(spans) != 2 || spans[0].ParentSpanID != root.SpanContext().SpanID {
+		t.Fatalf("unexpected spans: %+v", spans)
+	}
+}
+
+func TestUnsampledSpanIsNotExported(t *testing.T) {
+	exporter := NewMemoryExporter()
+	sc, _ := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+
+	_, span := NewTracer(exporter).Start(ContextWithRemoteSpanContext(context.Background(), sc), "skip", SpanKindServer)
+	span.End()
+	if len(exporter.Spans()) != 0 {
+		t.Fatal("unsampled span should not be exported")
+	}
+}
+
+func TestJSONLinesExporter(t *testing.T) {
+	var buf bytes.Buffer
+	_, span := NewTracer(NewJSONLinesExporter(&buf)).Start(context.Background(), "op", SpanKindInternal)
+	span.SetAttribute("key", "value")
+	span.End()
+
+	record := map[string]any{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("decode span failed: %v", err)
+	}
+	if record["name"] != "op" || record["kind"] != "internal" || record["trace_id"] != span.SpanContext().TraceID.String() {
+		t.Fatalf("unexpected record: %v", record)
+	}
+	if _, ok := record["parent_span_id"]; ok {
+		t.Fatalf("root span should omit parent_span_id: %v", record)
+	}
+}
@@ -0,0 +1,136 @@
+package trace
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/muidea/magicCommon/foundation/helper"
+)
+
+// SpanKey 当前span在context中的key
+type SpanKey struct{}
+
+// RemoteSpanContextKey 上游SpanContext在context中的key
+type RemoteSpanContextKey struct{}
+
+// Tracer 创建span并交给Exporter
+type Tracer struct {
+	exporter Exporter
+}
+
+// NewTracer 新建Tracer, exporter为nil时只传播不输出
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+func init() {
+	defaultTracer.Store(NewTracer(nil))
+}
+
+// SetTracer 设置全局Tracer, 代理和SSE客户端的子span都使用它
+func SetTracer(tracer *Tracer) {
+	if tracer == nil {
+		tracer = NewTracer(nil)
+	}
+	defaultTracer.Store(tracer)
+}
+
+// GetTracer 返回全局Tracer
+func GetTracer() *Tracer {
+	return defaultTracer.Load()
+}
+
+// Start 创建span, ctx中有当前span时作为其子span, 否则延续上游SpanContext或开始新的trace
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	span := &Span{
+		tracer: t,
+		data: SpanData{
+			Name:      name,
+			Kind:      kind,
+			StartTime: time.Now(),
+		},
+	}
+
+	parent, parentOK := parentSpanContext(ctx)
+	if parentOK {
+		span.spanContext = SpanContext{
+			TraceID:    parent.TraceID,
+			Flags:      parent.Flags,
+			TraceState: parent.TraceState,
+		}
+		span.data.ParentSpanID = parent.SpanID
+	} else {
+		span.spanContext = SpanContext{TraceID: newTraceID(), Flags: FlagSampled}
+	}
+	span.spanContext.SpanID = newSpanID()
+	span.data.TraceID = span.spanContext.TraceID
+	span.data.SpanID = span.spanContext.SpanID
+	span.data.TraceState = span.spanContext.TraceState
+
+	return ContextWithSpan(ctx, span), span
+}
+
+func (t *Tracer) export(data *SpanData) {
+	if t.exporter == nil {
+		return
+	}
+	if err := t.exporter.Export(data); err != nil {
+		slog.Error("export span failed", "span", data.Name, "err", err)
+	}
+}
+
+// Start 使用全局Tracer创建span
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	return GetTracer().Start(ctx, name, kind)
+}
+
+// StartChild 只在ctx中已有span时创建子span, 否则返回nil
+func StartChild(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.Start(ctx, name, kind)
+}
+
+// ContextWithSpan 把span设为ctx中的当前span
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, SpanKey{}, span)
+}
+
+// ContextWithRemoteSpanContext 保存上游传入的SpanContext
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, RemoteSpanContextKey{}, sc)
+}
+
+// SpanFromContext 获取ctx中的当前span
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := helper.GetValueFromContext[*Span](ctx, SpanKey{})
+	return span
+}
+
+// SpanContextFromContext 获取ctx中用于向下游传播的SpanContext
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := parentSpanContext(ctx)
+	return sc
+}
+
+func parentSpanContext(ctx context.Context) (SpanContext, bool) {
+	if ctx == nil {
+		return SpanContext{}, false
+	}
+	if span := SpanFromContext(ctx); span != nil {
+		return span.spanContext, true
+	}
+	if sc, ok := helper.GetValueFromContext[SpanContext](ctx, RemoteSpanContextKey{}); ok && sc.IsValid() {
+		return sc, true
+	}
+	return SpanContext{}, false
+}